@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/ast/astdot"
+	"monkey-lang.z9fr.xyz/internal/ast/astjson"
+	"monkey-lang.z9fr.xyz/internal/lexer"
+	"monkey-lang.z9fr.xyz/internal/parser"
+)
+
+func astCommand(args []string) int {
+	fs := flag.NewFlagSet("ast", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: monkey ast [-json | -dot | -tokens] [file]")
+		fs.PrintDefaults()
+	}
+	asJSON := fs.Bool("json", false, "print the AST as JSON")
+	dot := fs.Bool("dot", false, "print the AST as a Graphviz DOT graph")
+	tokens := fs.Bool("tokens", false, "print the lexer's tokens as JSON")
+	trace := fs.Bool("trace", false, "trace the parse functions to stderr")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return 2
+	}
+
+	name, src, err := readSource(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey ast: %s\n", err)
+		return 1
+	}
+
+	if *tokens {
+		out, err := astjson.MarshalTokens(lexer.New(src))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "monkey ast: %s\n", err)
+			return 1
+		}
+		fmt.Println(string(out))
+		return 0
+	}
+
+	var opts []parser.Option
+	if *trace {
+		opts = append(opts, parser.WithTrace(os.Stderr))
+	}
+
+	p := parser.New(lexer.New(src), opts...)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		printParserErrors(os.Stderr, name, p.Errors())
+		return 1
+	}
+
+	if *asJSON {
+		out, err := astjson.MarshalIndent(program, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "monkey ast: %s\n", err)
+			return 1
+		}
+		fmt.Println(string(out))
+		return 0
+	}
+
+	if *dot {
+		if err := astdot.Write(os.Stdout, program); err != nil {
+			fmt.Fprintf(os.Stderr, "monkey ast: %s\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	ast.Fprint(os.Stdout, program)
+	return 0
+}
+
+// readSource reads a file, or standard input when `filename` is empty or "-"
+func readSource(filename string) (name, src string, err error) {
+	if filename == "" || filename == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		return "<stdin>", string(data), err
+	}
+
+	data, err := os.ReadFile(filename)
+	return filename, string(data), err
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/ast/astbin"
+	"monkey-lang.z9fr.xyz/internal/format"
+	"monkey-lang.z9fr.xyz/internal/lexer"
+	"monkey-lang.z9fr.xyz/internal/parser"
+)
+
+func buildCommand(args []string) int {
+	fs := flag.NewFlagSet("build", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: monkey build [-o output] [-positions=false] <file>")
+		fs.PrintDefaults()
+	}
+	output := fs.String("o", "", "write to `file` instead of the input name with a .mkb extension")
+	positions := fs.Bool("positions", true, "keep source positions in the output")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	filename := fs.Arg(0)
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey build: %s\n", err)
+		return 1
+	}
+
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		printParserErrors(os.Stderr, filename, p.Errors())
+		return 1
+	}
+
+	data, err := astbin.Marshal(program, *positions)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey build: %s\n", err)
+		return 1
+	}
+
+	out := *output
+	if out == "" {
+		out = strings.TrimSuffix(filename, filepath.Ext(filename)) + ".mkb"
+	}
+
+	if err := os.WriteFile(out, data, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "monkey build: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+func dumpCommand(args []string) int {
+	fs := flag.NewFlagSet("dump", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: monkey dump [-source] <file.mkb>")
+		fs.PrintDefaults()
+	}
+	source := fs.Bool("source", false, "print the program as formatted source instead of a tree")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	filename := fs.Arg(0)
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey dump: %s\n", err)
+		return 1
+	}
+
+	f, err := astbin.Decode(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey dump: %s: %s\n", filename, err)
+		return 1
+	}
+
+	if *source {
+		if err := format.Node(os.Stdout, f.Program); err != nil {
+			fmt.Fprintf(os.Stderr, "monkey dump: %s\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	positions := "no"
+	if f.Positions {
+		positions = "yes"
+	}
+	fmt.Printf("format version %d, %d bytes, positions: %s\n", f.Version, len(data), positions)
+
+	fmt.Printf("strings (%d):\n", len(f.Strings))
+	for i, s := range f.Strings {
+		fmt.Printf("\t%d\t%q\n", i, s)
+	}
+
+	fmt.Println("program:")
+	if err := ast.Fprint(os.Stdout, f.Program); err != nil {
+		fmt.Fprintf(os.Stderr, "monkey dump: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
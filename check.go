@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"monkey-lang.z9fr.xyz/internal/lexer"
+	"monkey-lang.z9fr.xyz/internal/parser"
+	"monkey-lang.z9fr.xyz/internal/resolver"
+)
+
+func checkCommand(args []string) int {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprintln(fs.Output(), "usage: monkey check [files...]") }
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if fs.NArg() == 0 {
+		name, src, err := readSource("")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "monkey check: %s\n", err)
+			return 1
+		}
+		return check(name, src, os.Stderr)
+	}
+
+	status := 0
+	for _, filename := range fs.Args() {
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "monkey check: %s\n", err)
+			status = 1
+			continue
+		}
+		if code := check(filename, string(src), os.Stderr); code != 0 {
+			status = code
+		}
+	}
+
+	return status
+}
+
+// check parses and resolves `src` and writes what it finds to `out`. only
+// errors fail the check, warnings are printed but the exit code stays zero
+func check(name, src string, out io.Writer) int {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		printParserErrors(out, name, p.Errors())
+		return 1
+	}
+
+	result := resolver.Resolve(program, newEnvironment(io.Discard).Names()...)
+	for _, d := range result.Diagnostics {
+		fmt.Fprintf(out, "%s:%s\n", name, d)
+	}
+
+	if result.HasErrors() {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/dap"
+	"monkey-lang.z9fr.xyz/internal/object"
+)
+
+func dapCommand(args []string) int {
+	fs := flag.NewFlagSet("dap", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: monkey dap")
+		fmt.Fprintln(fs.Output(), "serves the Debug Adapter Protocol on stdin and stdout, for editors")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	if err := dap.Serve(os.Stdin, os.Stdout, launchDebug); err != nil {
+		fmt.Fprintf(os.Stderr, "monkey dap: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// launchDebug loads a program for the debug adapter, the way `monkey debug`
+// does. `puts` writes to `out`
+func launchDebug(path string, out io.Writer) (*ast.Program, *object.Environment, error) {
+	var problems bytes.Buffer
+
+	program, _, ok := loadDebugProgram(path, &problems)
+	if !ok {
+		return nil, nil, errors.New(strings.TrimSpace(problems.String()))
+	}
+
+	return program, newEnvironment(out), nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/ast/astbin"
+	"monkey-lang.z9fr.xyz/internal/debugger"
+	"monkey-lang.z9fr.xyz/internal/evaluator"
+	"monkey-lang.z9fr.xyz/internal/object"
+	"monkey-lang.z9fr.xyz/internal/resolver"
+)
+
+func debugCommand(args []string) int {
+	fs := flag.NewFlagSet("debug", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: monkey debug <file>")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	filename := fs.Arg(0)
+	program, src, ok := loadDebugProgram(filename, os.Stderr)
+	if !ok {
+		return 1
+	}
+
+	// the source is only shown, a binary program is run as it is
+	listing := string(src)
+	if astbin.IsProgramFile(src) {
+		listing = ""
+		if text, err := os.ReadFile(sourceFile(filename)); err == nil {
+			listing = string(text)
+		}
+	}
+
+	d := debugger.NewConsole(filename, listing, program, os.Stdin, os.Stdout)
+	result, err := d.Run(newEnvironment(os.Stdout))
+	if err == debugger.ErrQuit {
+		return 0
+	}
+
+	if errObj, ok := result.(*object.Error); ok {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", filename, errObj.Inspect())
+		return 1
+	}
+
+	return 0
+}
+
+// loadDebugProgram reads `filename` and gets it ready for the debugger, with
+// source positions and its macros expanded. it also returns what it ran from,
+// which is the source file for a binary program without positions. problems
+// are written to `errOut`
+func loadDebugProgram(filename string, errOut io.Writer) (*ast.Program, []byte, bool) {
+	src, err := os.ReadFile(filename)
+	if err == nil {
+		src, err = debugSource(filename, src, errOut)
+	}
+	if err != nil {
+		fmt.Fprintf(errOut, "monkey debug: %s\n", err)
+		return nil, nil, false
+	}
+
+	program, ok := loadProgram(filename, string(src), errOut)
+	if !ok {
+		return nil, nil, false
+	}
+
+	macroEnv := object.NewEnvironment()
+	evaluator.DefineMacros(program, macroEnv)
+	expanded, err := evaluator.ExpandMacros(program, macroEnv)
+	if err != nil {
+		fmt.Fprintf(errOut, "%s: %s\n", filename, err)
+		return nil, nil, false
+	}
+	program = expanded.(*ast.Program)
+	resolver.Annotate(program)
+
+	return program, src, true
+}
+
+// debugSource returns what to debug for the contents `src` of `filename`.
+// breakpoints need source positions, a binary program built without them is
+// replaced by its source file next to it
+func debugSource(filename string, src []byte, errOut io.Writer) ([]byte, error) {
+	if !astbin.IsProgramFile(src) {
+		return src, nil
+	}
+
+	f, err := astbin.Decode(src)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	if f.Positions {
+		return src, nil
+	}
+
+	text, err := os.ReadFile(sourceFile(filename))
+	if err != nil {
+		return nil, fmt.Errorf("%s has no source positions and its source can't be read (%w), rebuild it with monkey build -positions", filename, err)
+	}
+
+	fmt.Fprintf(errOut, "monkey debug: %s has no source positions, debugging %s instead\n", filename, sourceFile(filename))
+	return text, nil
+}
+
+// sourceFile is the name `monkey build` would have been given for `filename`
+func sourceFile(filename string) string {
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + ".mk"
+}
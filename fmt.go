@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"monkey-lang.z9fr.xyz/internal/format"
+)
+
+func fmtCommand(args []string) int {
+	fs := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: monkey fmt [-w] [files...]")
+		fs.PrintDefaults()
+	}
+	write := fs.Bool("w", false, "write result to the file instead of stdout")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if fs.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "monkey fmt: cannot use -w with standard input")
+			return 2
+		}
+
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "monkey fmt: %s\n", err)
+			return 1
+		}
+		return formatFile("<stdin>", src, false)
+	}
+
+	status := 0
+	for _, filename := range fs.Args() {
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "monkey fmt: %s\n", err)
+			status = 1
+			continue
+		}
+
+		if code := formatFile(filename, src, *write); code != 0 {
+			status = code
+		}
+	}
+
+	return status
+}
+
+func formatFile(filename string, src []byte, write bool) int {
+	out, err := format.Source(src)
+	if err != nil {
+		printParserErrors(os.Stderr, filename, strings.Split(err.Error(), "\n"))
+		return 1
+	}
+
+	if !write {
+		os.Stdout.Write(out)
+		return 0
+	}
+
+	if bytes.Equal(src, out) {
+		return 0
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey fmt: %s\n", err)
+		return 1
+	}
+
+	if err := os.WriteFile(filename, out, info.Mode().Perm()); err != nil {
+		fmt.Fprintf(os.Stderr, "monkey fmt: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
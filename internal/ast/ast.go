@@ -51,6 +51,13 @@ func (p *Program) String() string {
 type Identifier struct {
 	Token token.Token // the token.IDENT token
 	Value string
+
+	// the lexical address `resolver.Annotate` gives names bound in a function.
+	// when `Local` is set the value is in slot `Slot` of the function
+	// environment `Depth` levels out. other names are looked up by `Value`
+	Local bool
+	Depth int
+	Slot  int
 }
 
 func (i *Identifier) expressionNode()      {}
@@ -188,6 +195,7 @@ func (b *Boolean) String() string       { return b.Token.Literal }
 type BlockStatement struct {
 	Token      token.Token // the { token
 	Statements []Statement
+	Rbrace     token.Token // the closing }, only used for source positions
 }
 
 func (bs *BlockStatement) statementNode()       {}
@@ -231,6 +239,11 @@ type FunctionLiteral struct {
 	Token      token.Token     // The `fn` token
 	Parameters []*Identifier   // the `Parameters`
 	Body       *BlockStatement // else-condition
+
+	// the names of the function's slots, parameters first, filled in by
+	// `resolver.Annotate`. a call to a function without them gets a map based
+	// environment
+	Locals []string
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
@@ -256,6 +269,7 @@ type CallExpression struct {
 	Token     token.Token // The `(` token
 	Function  Expression  // Identifier or FunctionLiteral
 	Arguments []Expression
+	Rparen    token.Token // the closing ), only used for source positions
 }
 
 func (ce *CallExpression) expressionNode()      {}
@@ -275,3 +289,61 @@ func (ce *CallExpression) String() string {
 
 	return out.String()
 }
+
+type StringLiteral struct {
+	Token token.Token
+	Value string
+}
+
+func (sl *StringLiteral) expressionNode()      {}
+func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) String() string       { return sl.Token.Literal }
+
+// `FieldExpression` is `<expression>.<identifier>`, used to read fields of values
+// that are exposed from Go
+type FieldExpression struct {
+	Token  token.Token // The `.` token
+	Object Expression
+	Field  *Identifier
+}
+
+func (fe *FieldExpression) expressionNode()      {}
+func (fe *FieldExpression) TokenLiteral() string { return fe.Token.Literal }
+func (fe *FieldExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(fe.Object.String())
+	out.WriteString(".")
+	out.WriteString(fe.Field.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// `MacroLiteral` looks like a `FunctionLiteral` with `macro` in place of `fn`.
+// its body works on quoted AST nodes and runs before evaluation
+type MacroLiteral struct {
+	Token      token.Token // The `macro` token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (ml *MacroLiteral) expressionNode()      {}
+func (ml *MacroLiteral) TokenLiteral() string { return ml.Token.Literal }
+func (ml *MacroLiteral) String() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range ml.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString(ml.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") ")
+	out.WriteString(ml.Body.String())
+
+	return out.String()
+}
@@ -1,6 +1,7 @@
 package ast
 
 import (
+	"strings"
 	"testing"
 
 	"monkey-lang.z9fr.xyz/internal/token"
@@ -27,3 +28,47 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+func TestFprint(t *testing.T) {
+	program := &Program{
+		Statements: []Statement{
+			&ExpressionStatement{
+				Token: token.Token{Type: token.INT, Literal: "1"},
+				Expression: &InfixExpression{
+					Token:    token.Token{Type: token.PLUS, Literal: "+"},
+					Operator: "+",
+					Left: &IntegerLiteral{
+						Token: token.Token{Type: token.INT, Literal: "1"},
+						Value: 1,
+					},
+					Right: &CallExpression{
+						Token: token.Token{Type: token.LPAREN, Literal: "("},
+						Function: &Identifier{
+							Token: token.Token{Type: token.IDENT, Literal: "f"},
+							Value: "f",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	expected := `Program (1)
+  Statements:
+    ExpressionStatement (1)
+      Expression: InfixExpression (+)
+        Left: IntegerLiteral (1)
+        Right: CallExpression (()
+          Function: Identifier (f)
+          Arguments: []
+`
+
+	var out strings.Builder
+	if err := Fprint(&out, program); err != nil {
+		t.Fatalf("Fprint returned error: %s", err)
+	}
+
+	if out.String() != expected {
+		t.Errorf("Fprint wrong.\nexpected=\n%s\ngot=\n%s", expected, out.String())
+	}
+}
@@ -0,0 +1,315 @@
+package astbin
+
+/*
+astbin
+
+a compact binary encoding of a parsed program, so scripts can ship without
+being lexed and parsed again every time they start. a file is
+
+	magic      4 bytes  "\x7fMKY"
+	version    2 bytes  big endian, `Version`
+	flags      1 byte   bit 0: a position table is included
+	strings    uvarint count, then every string as uvarint length + bytes
+	nodes      the program, see below
+	positions  only with the flag: the position of every token, in the order
+	           the tokens appear in the node stream
+	checksum   4 bytes  big endian CRC-32 (IEEE) of everything before it
+
+every node is a tag byte followed by its token and its fields, children are
+nodes themselves and a missing child is tag 0. a token is the string index of
+its type and of its literal, strings always go through the string table.
+integers are varints, counts are uvarints.
+
+positions are stored as the difference to the previous token: a varint for
+the offset and the line and a uvarint for the column
+*/
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/token"
+)
+
+// Version is the format version this package writes and the only one it reads
+const Version = 1
+
+const magic = "\x7fMKY"
+
+const flagPositions = 1 << 0
+
+const headerLen = len(magic) + 2 + 1
+
+var (
+	// ErrNotProgram is returned for data that does not start with the magic
+	ErrNotProgram = errors.New("not a compiled monkey program")
+	// ErrCorrupt is returned for files that are damaged or truncated
+	ErrCorrupt = errors.New("corrupted program file")
+)
+
+// VersionError is returned for files written in a format version this build
+// does not read
+type VersionError struct {
+	Version int
+}
+
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("program file is format version %d, this build reads version %d, rebuild it", e.Version, Version)
+}
+
+// node tags, 0 is a missing node
+const (
+	tagNil byte = iota
+	tagProgram
+	tagLetStatement
+	tagReturnStatement
+	tagExpressionStatement
+	tagBlockStatement
+	tagIdentifier
+	tagIntegerLiteral
+	tagStringLiteral
+	tagBoolean
+	tagPrefixExpression
+	tagInfixExpression
+	tagIfExpression
+	tagFunctionLiteral
+	tagMacroLiteral
+	tagCallExpression
+	tagFieldExpression
+)
+
+// IsProgramFile reports whether `data` starts like a file written by Marshal
+func IsProgramFile(data []byte) bool {
+	return len(data) >= len(magic) && string(data[:len(magic)]) == magic
+}
+
+// Marshal encodes `program`. with `positions` the position of every token is
+// kept, which makes the file bigger but lets tools point in to the source
+func Marshal(program *ast.Program, positions bool) ([]byte, error) {
+	e := &encoder{index: map[string]int{}, positions: positions}
+	if err := e.node(program); err != nil {
+		return nil, err
+	}
+
+	var flags byte
+	if positions {
+		flags |= flagPositions
+	}
+
+	out := []byte(magic)
+	out = binary.BigEndian.AppendUint16(out, Version)
+	out = append(out, flags)
+
+	out = binary.AppendUvarint(out, uint64(len(e.strings)))
+	for _, s := range e.strings {
+		out = binary.AppendUvarint(out, uint64(len(s)))
+		out = append(out, s...)
+	}
+
+	out = append(out, e.nodes...)
+
+	if positions {
+		var prev token.Position
+		for _, pos := range e.tokens {
+			out = binary.AppendVarint(out, int64(pos.Offset-prev.Offset))
+			out = binary.AppendVarint(out, int64(pos.Line-prev.Line))
+			out = binary.AppendUvarint(out, uint64(pos.Column))
+			prev = pos
+		}
+	}
+
+	return binary.BigEndian.AppendUint32(out, crc32.ChecksumIEEE(out)), nil
+}
+
+type encoder struct {
+	strings []string
+	index   map[string]int
+
+	nodes []byte
+
+	positions bool
+	tokens    []token.Position
+}
+
+func (e *encoder) uvarint(v int) {
+	e.nodes = binary.AppendUvarint(e.nodes, uint64(v))
+}
+
+func (e *encoder) string(s string) {
+	i, ok := e.index[s]
+	if !ok {
+		i = len(e.strings)
+		e.index[s] = i
+		e.strings = append(e.strings, s)
+	}
+	e.uvarint(i)
+}
+
+func (e *encoder) token(t token.Token) {
+	e.string(string(t.Type))
+	e.string(t.Literal)
+	if e.positions {
+		e.tokens = append(e.tokens, t.Pos)
+	}
+}
+
+func (e *encoder) node(node ast.Node) error {
+	switch n := node.(type) {
+	case nil:
+		e.nodes = append(e.nodes, tagNil)
+		return nil
+
+	case *ast.Program:
+		e.nodes = append(e.nodes, tagProgram)
+		return e.statements(n.Statements)
+
+	case *ast.LetStatement:
+		e.nodes = append(e.nodes, tagLetStatement)
+		e.token(n.Token)
+		return e.children(n.Name, n.Value)
+
+	case *ast.ReturnStatement:
+		e.nodes = append(e.nodes, tagReturnStatement)
+		e.token(n.Token)
+		return e.children(n.ReturnValue)
+
+	case *ast.ExpressionStatement:
+		e.nodes = append(e.nodes, tagExpressionStatement)
+		e.token(n.Token)
+		return e.children(n.Expression)
+
+	case *ast.BlockStatement:
+		if n == nil {
+			return e.node(nil)
+		}
+		e.nodes = append(e.nodes, tagBlockStatement)
+		e.token(n.Token)
+		if err := e.statements(n.Statements); err != nil {
+			return err
+		}
+		e.token(n.Rbrace)
+		return nil
+
+	case *ast.Identifier:
+		if n == nil {
+			return e.node(nil)
+		}
+		e.nodes = append(e.nodes, tagIdentifier)
+		e.token(n.Token)
+		e.string(n.Value)
+		return nil
+
+	case *ast.IntegerLiteral:
+		e.nodes = append(e.nodes, tagIntegerLiteral)
+		e.token(n.Token)
+		e.nodes = binary.AppendVarint(e.nodes, n.Value)
+		return nil
+
+	case *ast.StringLiteral:
+		e.nodes = append(e.nodes, tagStringLiteral)
+		e.token(n.Token)
+		e.string(n.Value)
+		return nil
+
+	case *ast.Boolean:
+		e.nodes = append(e.nodes, tagBoolean)
+		e.token(n.Token)
+		if n.Value {
+			e.nodes = append(e.nodes, 1)
+		} else {
+			e.nodes = append(e.nodes, 0)
+		}
+		return nil
+
+	case *ast.PrefixExpression:
+		e.nodes = append(e.nodes, tagPrefixExpression)
+		e.token(n.Token)
+		e.string(n.Operator)
+		return e.children(n.Right)
+
+	case *ast.InfixExpression:
+		e.nodes = append(e.nodes, tagInfixExpression)
+		e.token(n.Token)
+		if err := e.children(n.Left); err != nil {
+			return err
+		}
+		e.string(n.Operator)
+		return e.children(n.Right)
+
+	case *ast.IfExpression:
+		e.nodes = append(e.nodes, tagIfExpression)
+		e.token(n.Token)
+		return e.children(n.Condition, n.Consequence, n.Alternative)
+
+	case *ast.FunctionLiteral:
+		e.nodes = append(e.nodes, tagFunctionLiteral)
+		e.token(n.Token)
+		if err := e.identifiers(n.Parameters); err != nil {
+			return err
+		}
+		return e.children(n.Body)
+
+	case *ast.MacroLiteral:
+		e.nodes = append(e.nodes, tagMacroLiteral)
+		e.token(n.Token)
+		if err := e.identifiers(n.Parameters); err != nil {
+			return err
+		}
+		return e.children(n.Body)
+
+	case *ast.CallExpression:
+		e.nodes = append(e.nodes, tagCallExpression)
+		e.token(n.Token)
+		if err := e.children(n.Function); err != nil {
+			return err
+		}
+		e.uvarint(len(n.Arguments))
+		for _, arg := range n.Arguments {
+			if err := e.node(arg); err != nil {
+				return err
+			}
+		}
+		e.token(n.Rparen)
+		return nil
+
+	case *ast.FieldExpression:
+		e.nodes = append(e.nodes, tagFieldExpression)
+		e.token(n.Token)
+		return e.children(n.Object, n.Field)
+	}
+
+	return fmt.Errorf("astbin: cannot encode %T", node)
+}
+
+// children encodes each of `nodes` in turn
+func (e *encoder) children(nodes ...ast.Node) error {
+	for _, n := range nodes {
+		if err := e.node(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *encoder) statements(list []ast.Statement) error {
+	e.uvarint(len(list))
+	for _, s := range list {
+		if err := e.node(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *encoder) identifiers(list []*ast.Identifier) error {
+	e.uvarint(len(list))
+	for _, ident := range list {
+		if err := e.node(ident); err != nil {
+			return err
+		}
+	}
+	return nil
+}
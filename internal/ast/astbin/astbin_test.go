@@ -0,0 +1,267 @@
+package astbin
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"reflect"
+	"strings"
+	"testing"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/evaluator"
+	"monkey-lang.z9fr.xyz/internal/lexer"
+	"monkey-lang.z9fr.xyz/internal/object"
+	"monkey-lang.z9fr.xyz/internal/parser"
+)
+
+const input = `
+let add = fn(a, b) { a + b; };
+let result = add(5, -10 * 2);
+if (!(result > 0)) { return "negative"; } else { result }
+let unless = macro(cond, then) { quote(if (!(unquote(cond))) { unquote(then) }) };
+p.name;
+f();
+007;
+true == false;
+`
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+func TestRoundTrip(t *testing.T) {
+	program := parse(t, input)
+
+	data, err := Marshal(program, true)
+	if err != nil {
+		t.Fatalf("Marshal: %s", err)
+	}
+
+	decoded, err := Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal: %s", err)
+	}
+
+	if !reflect.DeepEqual(decoded, program) {
+		t.Errorf("decoded program differs.\nwant=%s\ngot=%s", program, decoded)
+	}
+}
+
+func TestWithoutPositions(t *testing.T) {
+	program := parse(t, input)
+
+	data, err := Marshal(program, false)
+	if err != nil {
+		t.Fatalf("Marshal: %s", err)
+	}
+
+	withPositions, _ := Marshal(program, true)
+	if len(data) >= len(withPositions) {
+		t.Errorf("leaving out positions did not make the file smaller: %d >= %d", len(data), len(withPositions))
+	}
+
+	f, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode: %s", err)
+	}
+
+	if f.Version != Version || f.Positions {
+		t.Errorf("wrong header. version=%d, positions=%t", f.Version, f.Positions)
+	}
+
+	if f.Program.String() != program.String() {
+		t.Errorf("decoded program differs.\nwant=%s\ngot=%s", program, f.Program)
+	}
+
+	ast.Inspect(f.Program, func(n ast.Node) bool {
+		if pos := ast.Pos(n); n != nil && pos.IsValid() {
+			t.Errorf("%T has a position without a position table: %s", n, pos)
+		}
+		return true
+	})
+
+	// encoding is deterministic
+	again, _ := Marshal(f.Program, false)
+	if string(again) != string(data) {
+		t.Errorf("encoding the decoded program gives different bytes")
+	}
+}
+
+func TestDecodedProgramRuns(t *testing.T) {
+	data, err := Marshal(parse(t, "let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(10);"), false)
+	if err != nil {
+		t.Fatalf("Marshal: %s", err)
+	}
+
+	program, err := Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal: %s", err)
+	}
+
+	result := evaluator.Eval(program, object.NewEnvironment())
+	if result == nil || result.Inspect() != "55" {
+		t.Errorf("wrong result. want=55, got=%v", result)
+	}
+}
+
+// withChecksum recomputes the checksum after a test changed the data
+func withChecksum(data []byte) []byte {
+	body := data[:len(data)-4]
+	return binary.BigEndian.AppendUint32(append([]byte{}, body...), crc32.ChecksumIEEE(body))
+}
+
+func TestErrors(t *testing.T) {
+	good, err := Marshal(parse(t, "let x = 1 + 2; x;"), true)
+	if err != nil {
+		t.Fatalf("Marshal: %s", err)
+	}
+
+	modified := func(f func(data []byte) []byte) []byte {
+		return f(append([]byte{}, good...))
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		target  error
+		message string
+	}{
+		{"source", []byte("let x = 1;"), ErrNotProgram, "not a compiled monkey program"},
+		{"empty", nil, ErrNotProgram, "not a compiled monkey program"},
+		{
+			"version",
+			modified(func(d []byte) []byte { d[5] = 9; return d }),
+			nil,
+			"program file is format version 9, this build reads version 1, rebuild it",
+		},
+		{
+			"flipped byte",
+			modified(func(d []byte) []byte { d[12] ^= 0xff; return d }),
+			ErrCorrupt,
+			"corrupted program file: checksum mismatch",
+		},
+		{
+			"truncated",
+			good[:len(good)-10],
+			ErrCorrupt,
+			"corrupted program file: checksum mismatch",
+		},
+		{"only a header", []byte(magic + "\x00\x01\x00"), ErrCorrupt, "corrupted program file: file is too short"},
+		{
+			"unknown flags",
+			modified(func(d []byte) []byte { d[6] = 0x80; return withChecksum(d) }),
+			ErrCorrupt,
+			"corrupted program file: unknown flags 0x80",
+		},
+		{
+			"trailing bytes",
+			modified(func(d []byte) []byte {
+				return withChecksum(append(d[:len(d)-4], 0, 0, 0, 0, 0))
+			}),
+			ErrCorrupt,
+			"unexpected bytes at the end",
+		},
+		{
+			"unknown tag",
+			withChecksum([]byte(magic + "\x00\x01\x00" + "\x00" + "\x63" + "....")),
+			ErrCorrupt,
+			"unknown node tag 99",
+		},
+		{
+			"string index",
+			withChecksum([]byte(magic + "\x00\x01\x00" + "\x00" + "\x01\x01\x04\x05\x00" + "....")),
+			ErrCorrupt,
+			"string index 5 out of range",
+		},
+		{
+			"expression as statement",
+			withChecksum([]byte(magic + "\x00\x01\x00" + "\x02\x01a\x00" + "\x01\x01\x06\x00\x00\x01" + "....")),
+			ErrCorrupt,
+			"expected a statement, got *ast.Identifier",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := Unmarshal(tt.data)
+		if err == nil {
+			t.Errorf("%s: no error", tt.name)
+			continue
+		}
+
+		if tt.target != nil && !errors.Is(err, tt.target) {
+			t.Errorf("%s: error is not %v: %v", tt.name, tt.target, err)
+		}
+
+		if !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("%s: wrong message. want %q in %q", tt.name, tt.message, err.Error())
+		}
+	}
+
+	var versionErr *VersionError
+	if _, err := Unmarshal(modified(func(d []byte) []byte { d[5] = 9; return d })); !errors.As(err, &versionErr) {
+		t.Errorf("version mismatch is not a *VersionError: %v", err)
+	}
+}
+
+func TestMissingChildren(t *testing.T) {
+	one := &ast.IntegerLiteral{Value: 1}
+	x := &ast.Identifier{Value: "x"}
+	block := &ast.BlockStatement{}
+
+	// trees the parser never produces, written as-is by Marshal
+	tests := []struct {
+		name    string
+		node    ast.Statement
+		message string
+	}{
+		{"let name", &ast.LetStatement{Value: one}, "expected an identifier, got nothing"},
+		{"let value", &ast.LetStatement{Name: x}, "expected an expression, got nothing"},
+		{"return value", &ast.ReturnStatement{}, "expected an expression, got nothing"},
+		{"expression", &ast.ExpressionStatement{}, "expected an expression, got nothing"},
+		{"prefix right", &ast.ExpressionStatement{Expression: &ast.PrefixExpression{Operator: "-"}}, "expected an expression, got nothing"},
+		{"infix left", &ast.ExpressionStatement{Expression: &ast.InfixExpression{Operator: "+", Right: one}}, "expected an expression, got nothing"},
+		{"infix right", &ast.ExpressionStatement{Expression: &ast.InfixExpression{Operator: "+", Left: one}}, "expected an expression, got nothing"},
+		{"if condition", &ast.ExpressionStatement{Expression: &ast.IfExpression{Consequence: block}}, "expected an expression, got nothing"},
+		{"if consequence", &ast.ExpressionStatement{Expression: &ast.IfExpression{Condition: one}}, "expected a block, got nothing"},
+		{"function body", &ast.ExpressionStatement{Expression: &ast.FunctionLiteral{}}, "expected a block, got nothing"},
+		{"function parameter", &ast.ExpressionStatement{Expression: &ast.FunctionLiteral{Parameters: []*ast.Identifier{nil}, Body: block}}, "expected an identifier, got nothing"},
+		{"macro body", &ast.ExpressionStatement{Expression: &ast.MacroLiteral{}}, "expected a block, got nothing"},
+		{"call function", &ast.ExpressionStatement{Expression: &ast.CallExpression{Arguments: []ast.Expression{one}}}, "expected an expression, got nothing"},
+		{"call argument", &ast.ExpressionStatement{Expression: &ast.CallExpression{Function: x, Arguments: []ast.Expression{nil}}}, "expected an expression, got nothing"},
+		{"field object", &ast.ExpressionStatement{Expression: &ast.FieldExpression{Field: x}}, "expected an expression, got nothing"},
+		{"field name", &ast.ExpressionStatement{Expression: &ast.FieldExpression{Object: x}}, "expected an identifier, got nothing"},
+	}
+
+	for _, tt := range tests {
+		data, err := Marshal(&ast.Program{Statements: []ast.Statement{tt.node}}, false)
+		if err != nil {
+			t.Fatalf("%s: Marshal: %s", tt.name, err)
+		}
+
+		_, err = Unmarshal(data)
+		if !errors.Is(err, ErrCorrupt) {
+			t.Errorf("%s: error is not %v: %v", tt.name, ErrCorrupt, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("%s: wrong message. want %q in %q", tt.name, tt.message, err.Error())
+		}
+	}
+
+	// `if` without an `else` is what the parser makes of most ifs
+	data, err := Marshal(parse(t, "if (true) { 1 }"), false)
+	if err != nil {
+		t.Fatalf("Marshal: %s", err)
+	}
+	if _, err := Unmarshal(data); err != nil {
+		t.Errorf("if without else: %s", err)
+	}
+}
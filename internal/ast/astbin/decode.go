@@ -0,0 +1,440 @@
+package astbin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/token"
+)
+
+// File is a decoded program file
+type File struct {
+	Version   int
+	Positions bool // whether the file had a position table
+	Strings   []string
+	Program   *ast.Program
+}
+
+// Unmarshal decodes a program written by Marshal. the result can be evaluated
+// like a freshly parsed tree
+func Unmarshal(data []byte) (*ast.Program, error) {
+	f, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return f.Program, nil
+}
+
+// Decode is Unmarshal for tools that also want to see the file's header and
+// string table
+func Decode(data []byte) (*File, error) {
+	if !IsProgramFile(data) {
+		return nil, ErrNotProgram
+	}
+
+	if len(data) < headerLen+4 {
+		return nil, fmt.Errorf("%w: file is too short", ErrCorrupt)
+	}
+
+	version := int(binary.BigEndian.Uint16(data[len(magic):]))
+	if version != Version {
+		return nil, &VersionError{Version: version}
+	}
+
+	body, sum := data[:len(data)-4], binary.BigEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(body) != sum {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrCorrupt)
+	}
+
+	flags := data[len(magic)+2]
+	if flags&^flagPositions != 0 {
+		return nil, fmt.Errorf("%w: unknown flags %#x", ErrCorrupt, flags)
+	}
+
+	d := &decoder{data: body, pos: headerLen}
+	f := &File{Version: version, Positions: flags&flagPositions != 0}
+
+	n := d.count()
+	for i := 0; i < n && d.err == nil; i++ {
+		length := d.count()
+		f.Strings = append(f.Strings, string(d.bytes(length)))
+	}
+	d.strings = f.Strings
+
+	node := d.node()
+	program, ok := node.(*ast.Program)
+	if d.err == nil && !ok {
+		d.fail("expected a Program, got %s", nodeName(node))
+	}
+
+	if f.Positions {
+		var prev token.Position
+		for _, tok := range d.tokens {
+			if d.err != nil {
+				break
+			}
+			prev.Offset += int(d.varint())
+			prev.Line += int(d.varint())
+			prev.Column = int(d.uvarint())
+			tok.Pos = prev
+		}
+	}
+
+	if d.err == nil && d.pos != len(body) {
+		d.fail("%d unexpected bytes at the end", len(body)-d.pos)
+	}
+
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	f.Program = program
+	return f, nil
+}
+
+type decoder struct {
+	data    []byte
+	pos     int
+	strings []string
+
+	// every token decoded so far, the position table fills them in
+	tokens []*token.Token
+
+	// how many nodes deep we are
+	depth int
+
+	// the first error, reads after it return zero values
+	err error
+}
+
+// maxDepth bounds the nesting of nodes, so a crafted file can't recurse until
+// the stack runs out
+const maxDepth = 10000
+
+func (d *decoder) fail(format string, a ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf("%w: at byte %d: %s", ErrCorrupt, d.pos, fmt.Sprintf(format, a...))
+	}
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	if d.pos >= len(d.data) {
+		d.fail("unexpected end of data")
+		return 0
+	}
+	b := d.data[d.pos]
+	d.pos++
+	return b
+}
+
+func (d *decoder) bytes(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n > len(d.data)-d.pos {
+		d.fail("unexpected end of data")
+		return nil
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.data[d.pos:])
+	if n <= 0 {
+		d.fail("bad varint")
+		return 0
+	}
+	d.pos += n
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.data[d.pos:])
+	if n <= 0 {
+		d.fail("bad varint")
+		return 0
+	}
+	d.pos += n
+	return v
+}
+
+// count reads a length. anything longer than the rest of the data can't be
+// right, checking it here keeps a corrupt file from making us allocate
+func (d *decoder) count() int {
+	v := d.uvarint()
+	if v > uint64(len(d.data)-d.pos) {
+		d.fail("count %d is larger than the file", v)
+		return 0
+	}
+	return int(v)
+}
+
+func (d *decoder) string() string {
+	i := d.uvarint()
+	if d.err != nil {
+		return ""
+	}
+	if i >= uint64(len(d.strings)) {
+		d.fail("string index %d out of range", i)
+		return ""
+	}
+	return d.strings[i]
+}
+
+func (d *decoder) token(t *token.Token) {
+	t.Type = token.TokenType(d.string())
+	t.Literal = d.string()
+	d.tokens = append(d.tokens, t)
+}
+
+func (d *decoder) node() ast.Node {
+	tag := d.byte()
+	if d.err != nil {
+		return nil
+	}
+
+	d.depth++
+	defer func() { d.depth-- }()
+	if d.depth > maxDepth {
+		d.fail("nodes nested more than %d deep", maxDepth)
+		return nil
+	}
+
+	switch tag {
+	case tagNil:
+		return nil
+
+	case tagProgram:
+		return &ast.Program{Statements: d.statements()}
+
+	case tagLetStatement:
+		n := &ast.LetStatement{}
+		d.token(&n.Token)
+		n.Name = d.requiredIdentifier()
+		n.Value = d.requiredExpression()
+		return n
+
+	case tagReturnStatement:
+		n := &ast.ReturnStatement{}
+		d.token(&n.Token)
+		n.ReturnValue = d.requiredExpression()
+		return n
+
+	case tagExpressionStatement:
+		n := &ast.ExpressionStatement{}
+		d.token(&n.Token)
+		n.Expression = d.requiredExpression()
+		return n
+
+	case tagBlockStatement:
+		n := &ast.BlockStatement{}
+		d.token(&n.Token)
+		n.Statements = d.statements()
+		d.token(&n.Rbrace)
+		return n
+
+	case tagIdentifier:
+		n := &ast.Identifier{}
+		d.token(&n.Token)
+		n.Value = d.string()
+		return n
+
+	case tagIntegerLiteral:
+		n := &ast.IntegerLiteral{}
+		d.token(&n.Token)
+		n.Value = d.varint()
+		return n
+
+	case tagStringLiteral:
+		n := &ast.StringLiteral{}
+		d.token(&n.Token)
+		n.Value = d.string()
+		return n
+
+	case tagBoolean:
+		n := &ast.Boolean{}
+		d.token(&n.Token)
+		switch b := d.byte(); b {
+		case 0, 1:
+			n.Value = b == 1
+		default:
+			d.fail("bad boolean %d", b)
+		}
+		return n
+
+	case tagPrefixExpression:
+		n := &ast.PrefixExpression{}
+		d.token(&n.Token)
+		n.Operator = d.string()
+		n.Right = d.requiredExpression()
+		return n
+
+	case tagInfixExpression:
+		n := &ast.InfixExpression{}
+		d.token(&n.Token)
+		n.Left = d.requiredExpression()
+		n.Operator = d.string()
+		n.Right = d.requiredExpression()
+		return n
+
+	case tagIfExpression:
+		n := &ast.IfExpression{}
+		d.token(&n.Token)
+		n.Condition = d.requiredExpression()
+		n.Consequence = d.requiredBlock()
+		// the only child that may be missing, `if` without an `else`
+		n.Alternative = d.block()
+		return n
+
+	case tagFunctionLiteral:
+		n := &ast.FunctionLiteral{}
+		d.token(&n.Token)
+		n.Parameters = d.identifiers()
+		n.Body = d.requiredBlock()
+		return n
+
+	case tagMacroLiteral:
+		n := &ast.MacroLiteral{}
+		d.token(&n.Token)
+		n.Parameters = d.identifiers()
+		n.Body = d.requiredBlock()
+		return n
+
+	case tagCallExpression:
+		n := &ast.CallExpression{}
+		d.token(&n.Token)
+		n.Function = d.requiredExpression()
+		count := d.count()
+		n.Arguments = make([]ast.Expression, 0, count)
+		for i := 0; i < count && d.err == nil; i++ {
+			n.Arguments = append(n.Arguments, d.requiredExpression())
+		}
+		d.token(&n.Rparen)
+		return n
+
+	case tagFieldExpression:
+		n := &ast.FieldExpression{}
+		d.token(&n.Token)
+		n.Object = d.requiredExpression()
+		n.Field = d.requiredIdentifier()
+		return n
+	}
+
+	d.fail("unknown node tag %d", tag)
+	return nil
+}
+
+func nodeName(node ast.Node) string {
+	if node == nil {
+		return "nothing"
+	}
+	return fmt.Sprintf("%T", node)
+}
+
+func (d *decoder) statements() []ast.Statement {
+	count := d.count()
+
+	list := make([]ast.Statement, 0, count)
+	for i := 0; i < count && d.err == nil; i++ {
+		node := d.node()
+		s, ok := node.(ast.Statement)
+		if !ok {
+			d.fail("expected a statement, got %s", nodeName(node))
+			return nil
+		}
+		list = append(list, s)
+	}
+	return list
+}
+
+func (d *decoder) expression() ast.Expression {
+	node := d.node()
+	if node == nil {
+		return nil
+	}
+
+	e, ok := node.(ast.Expression)
+	if !ok {
+		d.fail("expected an expression, got %s", nodeName(node))
+		return nil
+	}
+	return e
+}
+
+func (d *decoder) block() *ast.BlockStatement {
+	node := d.node()
+	if node == nil {
+		return nil
+	}
+
+	b, ok := node.(*ast.BlockStatement)
+	if !ok {
+		d.fail("expected a block, got %s", nodeName(node))
+		return nil
+	}
+	return b
+}
+
+func (d *decoder) identifier() *ast.Identifier {
+	node := d.node()
+	if node == nil {
+		return nil
+	}
+
+	ident, ok := node.(*ast.Identifier)
+	if !ok {
+		d.fail("expected an identifier, got %s", nodeName(node))
+		return nil
+	}
+	return ident
+}
+
+// requiredExpression, requiredBlock and requiredIdentifier read children the
+// grammar always has, a tree without them can't have come from the parser
+
+func (d *decoder) requiredExpression() ast.Expression {
+	e := d.expression()
+	if e == nil {
+		d.fail("expected an expression, got nothing")
+	}
+	return e
+}
+
+func (d *decoder) requiredBlock() *ast.BlockStatement {
+	b := d.block()
+	if b == nil {
+		d.fail("expected a block, got nothing")
+	}
+	return b
+}
+
+func (d *decoder) requiredIdentifier() *ast.Identifier {
+	ident := d.identifier()
+	if ident == nil {
+		d.fail("expected an identifier, got nothing")
+	}
+	return ident
+}
+
+func (d *decoder) identifiers() []*ast.Identifier {
+	count := d.count()
+
+	list := make([]*ast.Identifier, 0, count)
+	for i := 0; i < count && d.err == nil; i++ {
+		list = append(list, d.requiredIdentifier())
+	}
+	return list
+}
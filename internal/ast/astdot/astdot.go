@@ -0,0 +1,108 @@
+package astdot
+
+/*
+astdot
+
+writes an AST as a Graphviz DOT graph. every node is a box labelled with its type
+and token literal, and every edge is labelled with the name of the field that
+holds the child, with an index for lists:
+
+	monkey ast -dot file.mk | dot -Tpng -o ast.png
+*/
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+)
+
+var nodeType = reflect.TypeOf((*ast.Node)(nil)).Elem()
+
+// Write writes the graph for `node` to `w`
+func Write(w io.Writer, node ast.Node) error {
+	g := &graph{w: bufio.NewWriter(w)}
+
+	g.printf("digraph AST {\n")
+	g.printf("\tnode [shape=box, fontname=\"monospace\"];\n")
+	g.printf("\tedge [fontname=\"monospace\", fontsize=10];\n")
+	g.node(node)
+	g.printf("}\n")
+
+	if g.err != nil {
+		return g.err
+	}
+	return g.w.Flush()
+}
+
+type graph struct {
+	w   *bufio.Writer
+	err error
+	ids int
+}
+
+func (g *graph) printf(format string, a ...interface{}) {
+	if g.err != nil {
+		return
+	}
+	_, g.err = fmt.Fprintf(g.w, format, a...)
+}
+
+// node writes `node` and everything below it, and returns its id
+func (g *graph) node(node ast.Node) string {
+	id := fmt.Sprintf("n%d", g.ids)
+	g.ids++
+
+	v := reflect.Indirect(reflect.ValueOf(node))
+	g.printf("\t%s [label=%s];\n", id, quote(label(v.Type().Name(), node)))
+
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		field := v.Field(i)
+
+		switch {
+		case field.Type().Implements(nodeType):
+			if !field.IsNil() {
+				g.edge(id, name, field.Interface().(ast.Node))
+			}
+		case field.Kind() == reflect.Slice && field.Type().Elem().Implements(nodeType):
+			for j := 0; j < field.Len(); j++ {
+				if child := field.Index(j); !child.IsNil() {
+					g.edge(id, fmt.Sprintf("%s[%d]", name, j), child.Interface().(ast.Node))
+				}
+			}
+		}
+	}
+
+	return id
+}
+
+func (g *graph) edge(from, label string, child ast.Node) {
+	if v := reflect.ValueOf(child); v.Kind() == reflect.Pointer && v.IsNil() {
+		return
+	}
+
+	to := g.node(child)
+	g.printf("\t%s -> %s [label=%s];\n", from, to, quote(label))
+}
+
+func label(typ string, node ast.Node) string {
+	literal := node.TokenLiteral()
+	if s, ok := node.(*ast.StringLiteral); ok {
+		literal = `"` + s.Value + `"`
+	}
+
+	if literal == "" {
+		return typ
+	}
+	return typ + "\n" + literal
+}
+
+// quote makes `s` a DOT string, line breaks become centered line breaks
+func quote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
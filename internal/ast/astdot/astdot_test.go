@@ -0,0 +1,55 @@
+package astdot
+
+import (
+	"strings"
+	"testing"
+
+	"monkey-lang.z9fr.xyz/internal/lexer"
+	"monkey-lang.z9fr.xyz/internal/parser"
+)
+
+func TestWrite(t *testing.T) {
+	p := parser.New(lexer.New(`if (a < b) { f(1, "x") }`))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	var out strings.Builder
+	if err := Write(&out, program); err != nil {
+		t.Fatalf("Write: %s", err)
+	}
+
+	expected := `digraph AST {
+	node [shape=box, fontname="monospace"];
+	edge [fontname="monospace", fontsize=10];
+	n0 [label="Program\nif"];
+	n1 [label="ExpressionStatement\nif"];
+	n2 [label="IfExpression\nif"];
+	n3 [label="InfixExpression\n<"];
+	n4 [label="Identifier\na"];
+	n3 -> n4 [label="Left"];
+	n5 [label="Identifier\nb"];
+	n3 -> n5 [label="Right"];
+	n2 -> n3 [label="Condition"];
+	n6 [label="BlockStatement\n{"];
+	n7 [label="ExpressionStatement\nf"];
+	n8 [label="CallExpression\n("];
+	n9 [label="Identifier\nf"];
+	n8 -> n9 [label="Function"];
+	n10 [label="IntegerLiteral\n1"];
+	n8 -> n10 [label="Arguments[0]"];
+	n11 [label="StringLiteral\n\"x\""];
+	n8 -> n11 [label="Arguments[1]"];
+	n7 -> n8 [label="Expression"];
+	n6 -> n7 [label="Statements[0]"];
+	n2 -> n6 [label="Consequence"];
+	n1 -> n2 [label="Expression"];
+	n0 -> n1 [label="Statements[0]"];
+}
+`
+
+	if out.String() != expected {
+		t.Errorf("wrong graph.\nexpected=\n%s\ngot=\n%s", expected, out.String())
+	}
+}
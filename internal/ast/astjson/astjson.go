@@ -0,0 +1,198 @@
+package astjson
+
+/*
+astjson
+
+converts ASTs and token streams to JSON and back. every node is an object with
+a "type" discriminator (the Go type name, e.g. "InfixExpression"), the token it
+was built from, its source span and its fields in camelCase:
+
+	{
+	  "type": "InfixExpression",
+	  "token": {"type": "+", "literal": "+", "pos": {"offset": 2, "line": 1, "column": 3}},
+	  "span": {"start": {...}, "end": {...}},
+	  "left": {...},
+	  "operator": "+",
+	  "right": {...}
+	}
+
+missing children (e.g. an `if` without `else`) are null. spans are only written,
+decoding recomputes them from the tokens
+*/
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/lexer"
+	"monkey-lang.z9fr.xyz/internal/token"
+)
+
+// Marshal encodes `node` and everything below it
+func Marshal(node ast.Node) ([]byte, error) {
+	return json.Marshal(encodeNode(node))
+}
+
+// MarshalIndent is like Marshal but indents the output
+func MarshalIndent(node ast.Node, prefix, indent string) ([]byte, error) {
+	return json.MarshalIndent(encodeNode(node), prefix, indent)
+}
+
+// MarshalTokens encodes every token `l` produces, up to and including EOF
+func MarshalTokens(l *lexer.Lexer) ([]byte, error) {
+	tokens := []token.Token{}
+	for {
+		tok := l.NextToken()
+		tokens = append(tokens, tok)
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+	return json.Marshal(tokens)
+}
+
+type span struct {
+	Start token.Position `json:"start"`
+	End   token.Position `json:"end"`
+}
+
+// jsonObject is a JSON object that keeps its keys in the order they were added, so
+// "type" comes first and the output reads like the Go struct
+type jsonObject []member
+
+type member struct {
+	key   string
+	value interface{}
+}
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(m.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func encodeNode(node ast.Node) interface{} {
+	if node == nil || isNilPointer(node) {
+		return nil
+	}
+
+	start, end := ast.Span(node)
+	o := jsonObject{}
+	header := func(typ string, tok token.Token) {
+		o = append(o,
+			member{"type", typ},
+			member{"token", tok},
+			member{"span", span{start, end}},
+		)
+	}
+	field := func(key string, value interface{}) {
+		o = append(o, member{key, value})
+	}
+
+	switch n := node.(type) {
+	case *ast.Program:
+		o = append(o, member{"type", "Program"}, member{"span", span{start, end}})
+		field("statements", encodeStatements(n.Statements))
+	case *ast.LetStatement:
+		header("LetStatement", n.Token)
+		field("name", encodeNode(n.Name))
+		field("value", encodeNode(n.Value))
+	case *ast.ReturnStatement:
+		header("ReturnStatement", n.Token)
+		field("returnValue", encodeNode(n.ReturnValue))
+	case *ast.ExpressionStatement:
+		header("ExpressionStatement", n.Token)
+		field("expression", encodeNode(n.Expression))
+	case *ast.BlockStatement:
+		header("BlockStatement", n.Token)
+		field("statements", encodeStatements(n.Statements))
+		field("rbrace", n.Rbrace)
+	case *ast.Identifier:
+		header("Identifier", n.Token)
+		field("value", n.Value)
+	case *ast.IntegerLiteral:
+		header("IntegerLiteral", n.Token)
+		field("value", n.Value)
+	case *ast.StringLiteral:
+		header("StringLiteral", n.Token)
+		field("value", n.Value)
+	case *ast.Boolean:
+		header("Boolean", n.Token)
+		field("value", n.Value)
+	case *ast.PrefixExpression:
+		header("PrefixExpression", n.Token)
+		field("operator", n.Operator)
+		field("right", encodeNode(n.Right))
+	case *ast.InfixExpression:
+		header("InfixExpression", n.Token)
+		field("left", encodeNode(n.Left))
+		field("operator", n.Operator)
+		field("right", encodeNode(n.Right))
+	case *ast.IfExpression:
+		header("IfExpression", n.Token)
+		field("condition", encodeNode(n.Condition))
+		field("consequence", encodeNode(n.Consequence))
+		field("alternative", encodeNode(n.Alternative))
+	case *ast.FunctionLiteral:
+		header("FunctionLiteral", n.Token)
+		field("parameters", encodeIdentifiers(n.Parameters))
+		field("body", encodeNode(n.Body))
+	case *ast.MacroLiteral:
+		header("MacroLiteral", n.Token)
+		field("parameters", encodeIdentifiers(n.Parameters))
+		field("body", encodeNode(n.Body))
+	case *ast.CallExpression:
+		header("CallExpression", n.Token)
+		field("function", encodeNode(n.Function))
+		field("arguments", encodeExpressions(n.Arguments))
+		field("rparen", n.Rparen)
+	case *ast.FieldExpression:
+		header("FieldExpression", n.Token)
+		field("object", encodeNode(n.Object))
+		field("field", encodeNode(n.Field))
+	}
+
+	return o
+}
+
+func encodeStatements(list []ast.Statement) []interface{} {
+	out := []interface{}{}
+	for _, s := range list {
+		out = append(out, encodeNode(s))
+	}
+	return out
+}
+
+func encodeExpressions(list []ast.Expression) []interface{} {
+	out := []interface{}{}
+	for _, e := range list {
+		out = append(out, encodeNode(e))
+	}
+	return out
+}
+
+func encodeIdentifiers(list []*ast.Identifier) []interface{} {
+	out := []interface{}{}
+	for _, ident := range list {
+		out = append(out, encodeNode(ident))
+	}
+	return out
+}
@@ -0,0 +1,193 @@
+package astjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/evaluator"
+	"monkey-lang.z9fr.xyz/internal/lexer"
+	"monkey-lang.z9fr.xyz/internal/object"
+	"monkey-lang.z9fr.xyz/internal/parser"
+	"monkey-lang.z9fr.xyz/internal/token"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+// every `testdata/*.mk` file is parsed and encoded, and the result compared with
+// the `.json` file next to it. run with -update after changing the encoding
+func TestGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "*.mk"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Fatal("no test inputs found")
+	}
+
+	for _, input := range inputs {
+		src, err := os.ReadFile(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		program := parse(t, string(src))
+
+		got, err := MarshalIndent(program, "", "  ")
+		if err != nil {
+			t.Fatalf("%s: Marshal: %s", input, err)
+		}
+		got = append(got, '\n')
+
+		golden := strings.TrimSuffix(input, ".mk") + ".json"
+		if *update {
+			if err := os.WriteFile(golden, got, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		expected, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("%s: %s (run with -update to create it)", input, err)
+		}
+
+		if !bytes.Equal(got, expected) {
+			t.Errorf("%s: output does not match %s", input, golden)
+		}
+
+		decoded, err := UnmarshalProgram(expected)
+		if err != nil {
+			t.Fatalf("%s: Unmarshal: %s", golden, err)
+		}
+
+		if !reflect.DeepEqual(decoded, program) {
+			t.Errorf("%s: decoded AST differs from the parsed one.\nparsed=%s\ndecoded=%s",
+				golden, program.String(), decoded.String())
+		}
+	}
+}
+
+func TestDecodedProgramEvaluates(t *testing.T) {
+	program := parse(t, `
+let newAdder = fn(x) { fn(y) { x + y } };
+let addTwo = newAdder(2);
+if (addTwo(3) == 5) { "five" } else { "not five" };
+`)
+
+	data, err := Marshal(program)
+	if err != nil {
+		t.Fatalf("Marshal: %s", err)
+	}
+
+	decoded, err := Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal: %s", err)
+	}
+
+	result := evaluator.Eval(decoded, object.NewEnvironment())
+	str, ok := result.(*object.String)
+	if !ok || str.Value != "five" {
+		t.Errorf("wrong result. got=%T (%+v)", result, result)
+	}
+}
+
+func TestNodeShape(t *testing.T) {
+	data, err := Marshal(parse(t, "1 + x").Statements[0])
+	if err != nil {
+		t.Fatalf("Marshal: %s", err)
+	}
+
+	var node map[string]interface{}
+	if err := json.Unmarshal(data, &node); err != nil {
+		t.Fatal(err)
+	}
+
+	if node["type"] != "ExpressionStatement" {
+		t.Errorf("wrong type discriminator: %v", node["type"])
+	}
+
+	if !strings.HasPrefix(string(data), `{"type":"ExpressionStatement"`) {
+		t.Errorf("type is not the first key: %s", data)
+	}
+
+	span := node["span"].(map[string]interface{})
+	end := span["end"].(map[string]interface{})
+	if end["offset"] != float64(5) {
+		t.Errorf("wrong span end: %v", span)
+	}
+}
+
+func TestMarshalTokens(t *testing.T) {
+	data, err := MarshalTokens(lexer.New("let x"))
+	if err != nil {
+		t.Fatalf("MarshalTokens: %s", err)
+	}
+
+	var tokens []token.Token
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []token.Token{
+		{Type: token.LET, Literal: "let", Pos: token.Position{Offset: 0, Line: 1, Column: 1}},
+		{Type: token.IDENT, Literal: "x", Pos: token.Position{Offset: 4, Line: 1, Column: 5}},
+		{Type: token.EOF, Literal: "", Pos: token.Position{Offset: 5, Line: 1, Column: 6}},
+	}
+
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("wrong tokens.\nexpected=%+v\ngot=     %+v", expected, tokens)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := []string{
+		`{"type":"Nope","token":{}}`,
+		`{"type":"Program","statements":[{"type":"Identifier","token":{},"value":"x"}]}`,
+		`{"token":{}}`,
+		`[1, 2]`,
+		// children every parsed tree has
+		`{"type":"LetStatement","token":{},"value":{"type":"IntegerLiteral","token":{},"value":1}}`,
+		`{"type":"LetStatement","token":{},"name":{"type":"Identifier","token":{},"value":"x"},"value":null}`,
+		`{"type":"InfixExpression","token":{},"operator":"+","right":{"type":"IntegerLiteral","token":{},"value":1}}`,
+		`{"type":"InfixExpression","token":{},"operator":"+","left":{"type":"IntegerLiteral","token":{},"value":1},"right":null}`,
+		`{"type":"PrefixExpression","token":{},"operator":"-"}`,
+		`{"type":"IfExpression","token":{},"consequence":{"type":"BlockStatement","token":{},"rbrace":{}}}`,
+		`{"type":"IfExpression","token":{},"condition":{"type":"Boolean","token":{},"value":true}}`,
+		`{"type":"CallExpression","token":{},"rparen":{},"arguments":[]}`,
+		`{"type":"CallExpression","token":{},"rparen":{},"function":{"type":"Identifier","token":{},"value":"f"},"arguments":[null]}`,
+		`{"type":"FunctionLiteral","token":{},"parameters":[null],"body":{"type":"BlockStatement","token":{},"rbrace":{}}}`,
+		`{"type":"FunctionLiteral","token":{},"parameters":[]}`,
+		`{"type":"FieldExpression","token":{},"object":{"type":"Identifier","token":{},"value":"p"}}`,
+		`{"type":"ExpressionStatement","token":{}}`,
+		`{"type":"ReturnStatement","token":{},"returnValue":null}`,
+	}
+
+	for _, input := range tests {
+		if _, err := Unmarshal([]byte(input)); err == nil {
+			t.Errorf("%s: expected an error", input)
+		}
+	}
+
+	// an `if` without an `else` is fine
+	input := `{"type":"IfExpression","token":{},"condition":{"type":"Boolean","token":{},"value":true},"consequence":{"type":"BlockStatement","token":{},"rbrace":{}},"alternative":null}`
+	if _, err := Unmarshal([]byte(input)); err != nil {
+		t.Errorf("%s: unexpected error %s", input, err)
+	}
+}
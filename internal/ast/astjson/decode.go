@@ -0,0 +1,339 @@
+package astjson
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/token"
+)
+
+// Unmarshal decodes a node written by Marshal. the result can be evaluated like
+// a freshly parsed tree
+func Unmarshal(data []byte) (ast.Node, error) {
+	return decodeNode(data)
+}
+
+// UnmarshalProgram is Unmarshal for the common case of a whole program
+func UnmarshalProgram(data []byte) (*ast.Program, error) {
+	node, err := decodeNode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	program, ok := node.(*ast.Program)
+	if !ok {
+		return nil, fmt.Errorf("expected Program, got %T", node)
+	}
+	return program, nil
+}
+
+func isNilPointer(node ast.Node) bool {
+	v := reflect.ValueOf(node)
+	return v.Kind() == reflect.Pointer && v.IsNil()
+}
+
+// fields holds the members of a node object until we know its type
+type fields map[string]json.RawMessage
+
+func (f fields) get(key string, v interface{}) error {
+	raw, ok := f[key]
+	if !ok {
+		return fmt.Errorf("missing field %q", key)
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("field %q: %w", key, err)
+	}
+	return nil
+}
+
+// expression, block and identifier decode children the grammar always has, a
+// tree without them can't have come from the parser
+
+func (f fields) expression(key string) (ast.Expression, error) {
+	e, err := decodeExpression(f[key])
+	if err == nil && e == nil {
+		err = fmt.Errorf("missing field %q", key)
+	}
+	return e, err
+}
+
+func (f fields) block(key string) (*ast.BlockStatement, error) {
+	b, err := decodeBlock(f[key])
+	if err == nil && b == nil {
+		err = fmt.Errorf("missing field %q", key)
+	}
+	return b, err
+}
+
+func (f fields) identifier(key string) (*ast.Identifier, error) {
+	ident, err := decodeIdentifier(f[key])
+	if err == nil && ident == nil {
+		err = fmt.Errorf("missing field %q", key)
+	}
+	return ident, err
+}
+
+func isNull(data []byte) bool {
+	return len(data) == 0 || string(data) == "null"
+}
+
+func decodeNode(data []byte) (ast.Node, error) {
+	if isNull(data) {
+		return nil, nil
+	}
+
+	var f fields
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+
+	var typ string
+	if err := f.get("type", &typ); err != nil {
+		return nil, err
+	}
+
+	var tok token.Token
+	if typ != "Program" {
+		if err := f.get("token", &tok); err != nil {
+			return nil, fmt.Errorf("%s: %w", typ, err)
+		}
+	}
+
+	node, err := decodeFields(typ, tok, f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", typ, err)
+	}
+	return node, nil
+}
+
+func decodeFields(typ string, tok token.Token, f fields) (ast.Node, error) {
+	var err error
+
+	switch typ {
+	case "Program":
+		n := &ast.Program{}
+		n.Statements, err = decodeStatements(f["statements"])
+		return n, err
+
+	case "LetStatement":
+		n := &ast.LetStatement{Token: tok}
+		if n.Name, err = f.identifier("name"); err != nil {
+			return nil, err
+		}
+		n.Value, err = f.expression("value")
+		return n, err
+
+	case "ReturnStatement":
+		n := &ast.ReturnStatement{Token: tok}
+		n.ReturnValue, err = f.expression("returnValue")
+		return n, err
+
+	case "ExpressionStatement":
+		n := &ast.ExpressionStatement{Token: tok}
+		n.Expression, err = f.expression("expression")
+		return n, err
+
+	case "BlockStatement":
+		n := &ast.BlockStatement{Token: tok}
+		if err := f.get("rbrace", &n.Rbrace); err != nil {
+			return nil, err
+		}
+		n.Statements, err = decodeStatements(f["statements"])
+		return n, err
+
+	case "Identifier":
+		n := &ast.Identifier{Token: tok}
+		return n, f.get("value", &n.Value)
+
+	case "IntegerLiteral":
+		n := &ast.IntegerLiteral{Token: tok}
+		return n, f.get("value", &n.Value)
+
+	case "StringLiteral":
+		n := &ast.StringLiteral{Token: tok}
+		return n, f.get("value", &n.Value)
+
+	case "Boolean":
+		n := &ast.Boolean{Token: tok}
+		return n, f.get("value", &n.Value)
+
+	case "PrefixExpression":
+		n := &ast.PrefixExpression{Token: tok}
+		if err := f.get("operator", &n.Operator); err != nil {
+			return nil, err
+		}
+		n.Right, err = f.expression("right")
+		return n, err
+
+	case "InfixExpression":
+		n := &ast.InfixExpression{Token: tok}
+		if err := f.get("operator", &n.Operator); err != nil {
+			return nil, err
+		}
+		if n.Left, err = f.expression("left"); err != nil {
+			return nil, err
+		}
+		n.Right, err = f.expression("right")
+		return n, err
+
+	case "IfExpression":
+		n := &ast.IfExpression{Token: tok}
+		if n.Condition, err = f.expression("condition"); err != nil {
+			return nil, err
+		}
+		if n.Consequence, err = f.block("consequence"); err != nil {
+			return nil, err
+		}
+		// the only child that may be left out, `if` without an `else`
+		n.Alternative, err = decodeBlock(f["alternative"])
+		return n, err
+
+	case "FunctionLiteral":
+		n := &ast.FunctionLiteral{Token: tok}
+		if n.Parameters, err = decodeIdentifiers(f["parameters"]); err != nil {
+			return nil, err
+		}
+		n.Body, err = f.block("body")
+		return n, err
+
+	case "MacroLiteral":
+		n := &ast.MacroLiteral{Token: tok}
+		if n.Parameters, err = decodeIdentifiers(f["parameters"]); err != nil {
+			return nil, err
+		}
+		n.Body, err = f.block("body")
+		return n, err
+
+	case "CallExpression":
+		n := &ast.CallExpression{Token: tok}
+		if err := f.get("rparen", &n.Rparen); err != nil {
+			return nil, err
+		}
+		if n.Function, err = f.expression("function"); err != nil {
+			return nil, err
+		}
+		n.Arguments, err = decodeExpressions(f["arguments"])
+		return n, err
+
+	case "FieldExpression":
+		n := &ast.FieldExpression{Token: tok}
+		if n.Object, err = f.expression("object"); err != nil {
+			return nil, err
+		}
+		n.Field, err = f.identifier("field")
+		return n, err
+	}
+
+	return nil, fmt.Errorf("unknown node type")
+}
+
+func decodeList(data []byte) ([]json.RawMessage, error) {
+	var list []json.RawMessage
+	if isNull(data) {
+		return list, nil
+	}
+	err := json.Unmarshal(data, &list)
+	return list, err
+}
+
+func decodeStatements(data []byte) ([]ast.Statement, error) {
+	list, err := decodeList(data)
+	if err != nil {
+		return nil, err
+	}
+
+	out := []ast.Statement{}
+	for _, raw := range list {
+		node, err := decodeNode(raw)
+		if err != nil {
+			return nil, err
+		}
+		stmt, ok := node.(ast.Statement)
+		if !ok {
+			return nil, fmt.Errorf("expected a statement, got %T", node)
+		}
+		out = append(out, stmt)
+	}
+	return out, nil
+}
+
+func decodeExpressions(data []byte) ([]ast.Expression, error) {
+	list, err := decodeList(data)
+	if err != nil {
+		return nil, err
+	}
+
+	out := []ast.Expression{}
+	for _, raw := range list {
+		e, err := decodeExpression(raw)
+		if err != nil {
+			return nil, err
+		}
+		if e == nil {
+			return nil, fmt.Errorf("expected an expression, got null")
+		}
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+func decodeIdentifiers(data []byte) ([]*ast.Identifier, error) {
+	list, err := decodeList(data)
+	if err != nil {
+		return nil, err
+	}
+
+	out := []*ast.Identifier{}
+	for _, raw := range list {
+		ident, err := decodeIdentifier(raw)
+		if err != nil {
+			return nil, err
+		}
+		if ident == nil {
+			return nil, fmt.Errorf("expected an Identifier, got null")
+		}
+		out = append(out, ident)
+	}
+	return out, nil
+}
+
+func decodeExpression(data []byte) (ast.Expression, error) {
+	node, err := decodeNode(data)
+	if err != nil || node == nil {
+		return nil, err
+	}
+
+	e, ok := node.(ast.Expression)
+	if !ok {
+		return nil, fmt.Errorf("expected an expression, got %T", node)
+	}
+	return e, nil
+}
+
+func decodeBlock(data []byte) (*ast.BlockStatement, error) {
+	node, err := decodeNode(data)
+	if err != nil || node == nil {
+		return nil, err
+	}
+
+	block, ok := node.(*ast.BlockStatement)
+	if !ok {
+		return nil, fmt.Errorf("expected a BlockStatement, got %T", node)
+	}
+	return block, nil
+}
+
+func decodeIdentifier(data []byte) (*ast.Identifier, error) {
+	node, err := decodeNode(data)
+	if err != nil || node == nil {
+		return nil, err
+	}
+
+	ident, ok := node.(*ast.Identifier)
+	if !ok {
+		return nil, fmt.Errorf("expected an Identifier, got %T", node)
+	}
+	return ident, nil
+}
@@ -0,0 +1,4 @@
+fn() {};
+fn(x, y, z) {};
+let add = fn(x, y) { x + y; };
+add(1, 2 * 3, 4 + 5);
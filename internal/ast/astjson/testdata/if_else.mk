@@ -0,0 +1,2 @@
+if (x < y) { x }
+if (x < y) { x } else { y }
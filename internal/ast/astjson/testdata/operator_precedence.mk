@@ -0,0 +1,11 @@
+-a * b;
+!-a;
+a + b * c + d / e - f;
+3 + 4; -5 * 5;
+5 > 4 == 3 < 4;
+3 + 4 * 5 == 3 * 1 + 4 * 5;
+1 + (2 + 3) + 4;
+-(5 + 5);
+!(true == true);
+a + add(b * c) + d;
+add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8));
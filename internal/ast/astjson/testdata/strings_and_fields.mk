@@ -0,0 +1,3 @@
+let greeting = "hello world";
+point.x + point.y;
+a.b(c) * d.e;
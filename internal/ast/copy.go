@@ -0,0 +1,59 @@
+package ast
+
+import "reflect"
+
+// Copy returns a deep copy of the tree rooted at `node`. `Modify` changes a tree
+// in place, so copy first when the original is still needed afterwards.
+// like `Fprint` it uses reflection, new node types are copied without changes
+// here
+func Copy(node Node) Node {
+	v := reflect.ValueOf(node)
+	if node == nil || (v.Kind() == reflect.Pointer && v.IsNil()) {
+		return node
+	}
+
+	return copyValue(v).Interface().(Node)
+}
+
+func copyValue(v reflect.Value) reflect.Value {
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return v
+	}
+
+	c := reflect.New(v.Elem().Type())
+	c.Elem().Set(v.Elem())
+
+	s := c.Elem()
+	for i := 0; i < s.NumField(); i++ {
+		field := s.Field(i)
+
+		switch {
+		case field.Type().Implements(nodeType):
+			if field.IsNil() {
+				continue
+			}
+			elem := field
+			if elem.Kind() == reflect.Interface {
+				elem = elem.Elem()
+			}
+			field.Set(copyValue(elem))
+		case field.Kind() == reflect.Slice && field.Type().Elem().Implements(nodeType):
+			if field.IsNil() {
+				continue
+			}
+			list := reflect.MakeSlice(field.Type(), field.Len(), field.Len())
+			for j := 0; j < field.Len(); j++ {
+				elem := field.Index(j)
+				if elem.Kind() == reflect.Interface {
+					elem = elem.Elem()
+				}
+				if elem.IsValid() {
+					list.Index(j).Set(copyValue(elem))
+				}
+			}
+			field.Set(list)
+		}
+	}
+
+	return c
+}
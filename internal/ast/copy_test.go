@@ -0,0 +1,57 @@
+package ast
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCopy(t *testing.T) {
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Name: &Identifier{Value: "f"},
+				Value: &FunctionLiteral{
+					Parameters: []*Identifier{{Value: "x"}},
+					Body: &BlockStatement{
+						Statements: []Statement{
+							&ExpressionStatement{Expression: &InfixExpression{
+								Left:     &Identifier{Value: "x"},
+								Operator: "+",
+								Right:    &IntegerLiteral{Value: 1},
+							}},
+						},
+					},
+				},
+			},
+			&ExpressionStatement{Expression: &IfExpression{
+				Condition:   &Boolean{Value: true},
+				Consequence: &BlockStatement{},
+			}},
+		},
+	}
+
+	copied := Copy(program)
+
+	if !reflect.DeepEqual(program, copied) {
+		t.Fatalf("copy is not equal to the original.\nwant=%s\ngot=%s", program, copied)
+	}
+
+	// nothing may be shared, changing the copy leaves the original alone
+	Inspect(copied, func(n Node) bool {
+		if ident, ok := n.(*Identifier); ok {
+			ident.Value = "y"
+		}
+		return true
+	})
+
+	Inspect(program, func(n Node) bool {
+		if ident, ok := n.(*Identifier); ok && ident.Value == "y" {
+			t.Errorf("original was changed through the copy")
+		}
+		return true
+	})
+
+	if Copy(nil) != nil {
+		t.Errorf("Copy(nil) is not nil")
+	}
+}
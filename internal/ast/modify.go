@@ -0,0 +1,106 @@
+package ast
+
+// ModifierFunc is called by `Modify` for every node. whatever it returns takes
+// the place of the node in the tree
+type ModifierFunc func(Node) Node
+
+// Modify rebuilds the tree rooted at `node` bottom-up: the children of a node are
+// modified first, then `modifier` is called with the node itself. the tree is
+// changed in place and the (possibly replaced) root is returned.
+//
+// a replacement only goes in to the tree when it fits the field it's going in
+// to, e.g. a `Statement` in `Program.Statements` or an `*Identifier` in
+// `FunctionLiteral.Parameters`. otherwise the field keeps its old value
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch node := node.(type) {
+	case *Program:
+		modifyStatements(node.Statements, modifier)
+
+	case *ExpressionStatement:
+		node.Expression = modifyExpression(node.Expression, modifier)
+
+	case *LetStatement:
+		node.Name = modifyIdentifier(node.Name, modifier)
+		node.Value = modifyExpression(node.Value, modifier)
+
+	case *ReturnStatement:
+		node.ReturnValue = modifyExpression(node.ReturnValue, modifier)
+
+	case *BlockStatement:
+		modifyStatements(node.Statements, modifier)
+
+	case *PrefixExpression:
+		node.Right = modifyExpression(node.Right, modifier)
+
+	case *InfixExpression:
+		node.Left = modifyExpression(node.Left, modifier)
+		node.Right = modifyExpression(node.Right, modifier)
+
+	case *IfExpression:
+		node.Condition = modifyExpression(node.Condition, modifier)
+		node.Consequence = modifyBlock(node.Consequence, modifier)
+		node.Alternative = modifyBlock(node.Alternative, modifier)
+
+	case *FunctionLiteral:
+		for i, p := range node.Parameters {
+			node.Parameters[i] = modifyIdentifier(p, modifier)
+		}
+		node.Body = modifyBlock(node.Body, modifier)
+
+	case *MacroLiteral:
+		for i, p := range node.Parameters {
+			node.Parameters[i] = modifyIdentifier(p, modifier)
+		}
+		node.Body = modifyBlock(node.Body, modifier)
+
+	case *CallExpression:
+		node.Function = modifyExpression(node.Function, modifier)
+		for i, arg := range node.Arguments {
+			node.Arguments[i] = modifyExpression(arg, modifier)
+		}
+
+	case *FieldExpression:
+		node.Object = modifyExpression(node.Object, modifier)
+		node.Field = modifyIdentifier(node.Field, modifier)
+	}
+
+	return modifier(node)
+}
+
+func modifyStatements(list []Statement, modifier ModifierFunc) {
+	for i, s := range list {
+		if modified, ok := Modify(s, modifier).(Statement); ok {
+			list[i] = modified
+		}
+	}
+}
+
+func modifyExpression(e Expression, modifier ModifierFunc) Expression {
+	if e == nil {
+		return nil
+	}
+	if modified, ok := Modify(e, modifier).(Expression); ok {
+		return modified
+	}
+	return e
+}
+
+func modifyBlock(b *BlockStatement, modifier ModifierFunc) *BlockStatement {
+	if b == nil {
+		return nil
+	}
+	if modified, ok := Modify(b, modifier).(*BlockStatement); ok {
+		return modified
+	}
+	return b
+}
+
+func modifyIdentifier(ident *Identifier, modifier ModifierFunc) *Identifier {
+	if ident == nil {
+		return nil
+	}
+	if modified, ok := Modify(ident, modifier).(*Identifier); ok {
+		return modified
+	}
+	return ident
+}
@@ -0,0 +1,172 @@
+package ast
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestModify(t *testing.T) {
+	one := func() Expression { return &IntegerLiteral{Value: 1} }
+	two := func() Expression { return &IntegerLiteral{Value: 2} }
+
+	turnOneIntoTwo := func(node Node) Node {
+		integer, ok := node.(*IntegerLiteral)
+		if !ok {
+			return node
+		}
+
+		if integer.Value != 1 {
+			return node
+		}
+
+		integer.Value = 2
+		return integer
+	}
+
+	tests := []struct {
+		input    Node
+		expected Node
+	}{
+		{
+			one(),
+			two(),
+		},
+		{
+			&Program{
+				Statements: []Statement{
+					&ExpressionStatement{Expression: one()},
+				},
+			},
+			&Program{
+				Statements: []Statement{
+					&ExpressionStatement{Expression: two()},
+				},
+			},
+		},
+		{
+			&InfixExpression{Left: one(), Operator: "+", Right: two()},
+			&InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			&InfixExpression{Left: two(), Operator: "+", Right: one()},
+			&InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			&PrefixExpression{Operator: "-", Right: one()},
+			&PrefixExpression{Operator: "-", Right: two()},
+		},
+		{
+			&IfExpression{
+				Condition: one(),
+				Consequence: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: one()},
+					},
+				},
+				Alternative: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: one()},
+					},
+				},
+			},
+			&IfExpression{
+				Condition: two(),
+				Consequence: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: two()},
+					},
+				},
+				Alternative: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: two()},
+					},
+				},
+			},
+		},
+		{
+			&ReturnStatement{ReturnValue: one()},
+			&ReturnStatement{ReturnValue: two()},
+		},
+		{
+			&LetStatement{Name: &Identifier{Value: "x"}, Value: one()},
+			&LetStatement{Name: &Identifier{Value: "x"}, Value: two()},
+		},
+		{
+			&FunctionLiteral{
+				Parameters: []*Identifier{},
+				Body: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: one()},
+					},
+				},
+			},
+			&FunctionLiteral{
+				Parameters: []*Identifier{},
+				Body: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: two()},
+					},
+				},
+			},
+		},
+		{
+			&CallExpression{Function: &Identifier{Value: "f"}, Arguments: []Expression{one(), one()}},
+			&CallExpression{Function: &Identifier{Value: "f"}, Arguments: []Expression{two(), two()}},
+		},
+	}
+
+	for _, tt := range tests {
+		modified := Modify(tt.input, turnOneIntoTwo)
+
+		if !reflect.DeepEqual(modified, tt.expected) {
+			t.Errorf("not equal. got=%#v, want=%#v", modified, tt.expected)
+		}
+	}
+}
+
+func TestModifyReplacesNodes(t *testing.T) {
+	// rename every identifier, including let names and parameters
+	rename := func(node Node) Node {
+		if ident, ok := node.(*Identifier); ok {
+			return &Identifier{Value: ident.Value + "_"}
+		}
+		return node
+	}
+
+	fn := &FunctionLiteral{
+		Parameters: []*Identifier{{Value: "a"}},
+		Body: &BlockStatement{
+			Statements: []Statement{
+				&ExpressionStatement{Expression: &Identifier{Value: "a"}},
+			},
+		},
+	}
+	let := &LetStatement{Name: &Identifier{Value: "f"}, Value: fn}
+
+	Modify(let, rename)
+
+	if let.Name.Value != "f_" {
+		t.Errorf("let name not renamed. got=%q", let.Name.Value)
+	}
+	if fn.Parameters[0].Value != "a_" {
+		t.Errorf("parameter not renamed. got=%q", fn.Parameters[0].Value)
+	}
+	body := fn.Body.Statements[0].(*ExpressionStatement).Expression.(*Identifier)
+	if body.Value != "a_" {
+		t.Errorf("identifier in body not renamed. got=%q", body.Value)
+	}
+
+	// a replacement that doesn't fit the field is ignored
+	toStatement := func(node Node) Node {
+		if _, ok := node.(*Identifier); ok {
+			return &ReturnStatement{}
+		}
+		return node
+	}
+
+	Modify(let, toStatement)
+
+	if let.Name.Value != "f_" {
+		t.Errorf("let name replaced by a statement. got=%#v", let.Name)
+	}
+}
@@ -0,0 +1,83 @@
+package ast
+
+import "monkey-lang.z9fr.xyz/internal/token"
+
+// Span returns where `node` starts and ends in the source, taken from the
+// positions of all tokens in it. nodes built by hand or by macros have no
+// positions, for those both are the zero `token.Position`
+func Span(node Node) (start, end token.Position) {
+	add := func(t token.Token) {
+		if !t.Pos.IsValid() {
+			return
+		}
+		if !start.IsValid() || t.Pos.Offset < start.Offset {
+			start = t.Pos
+		}
+		if e := t.End(); !end.IsValid() || e.Offset > end.Offset {
+			end = e
+		}
+	}
+
+	Inspect(node, func(n Node) bool {
+		switch n := n.(type) {
+		case nil:
+		case *BlockStatement:
+			add(n.Token)
+			add(n.Rbrace)
+		case *CallExpression:
+			add(n.Token)
+			add(n.Rparen)
+		default:
+			add(nodeToken(n))
+		}
+		return true
+	})
+
+	return start, end
+}
+
+// Pos is the position of the token `node` is built around, e.g. the operator of
+// an `InfixExpression` or the `let` of a `LetStatement`
+func Pos(node Node) token.Position {
+	return nodeToken(node).Pos
+}
+
+func nodeToken(node Node) token.Token {
+	switch n := node.(type) {
+	case *Program:
+		if len(n.Statements) > 0 {
+			return nodeToken(n.Statements[0])
+		}
+	case *Identifier:
+		return n.Token
+	case *LetStatement:
+		return n.Token
+	case *ReturnStatement:
+		return n.Token
+	case *ExpressionStatement:
+		return n.Token
+	case *IntegerLiteral:
+		return n.Token
+	case *StringLiteral:
+		return n.Token
+	case *PrefixExpression:
+		return n.Token
+	case *InfixExpression:
+		return n.Token
+	case *Boolean:
+		return n.Token
+	case *BlockStatement:
+		return n.Token
+	case *IfExpression:
+		return n.Token
+	case *FunctionLiteral:
+		return n.Token
+	case *MacroLiteral:
+		return n.Token
+	case *CallExpression:
+		return n.Token
+	case *FieldExpression:
+		return n.Token
+	}
+	return token.Token{}
+}
@@ -0,0 +1,34 @@
+package ast_test
+
+import (
+	"testing"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+)
+
+func TestSpan(t *testing.T) {
+	input := "let add = fn(x, y) {\n  x + y;\n};\nadd(1, \"two\")"
+	program := parse(t, input)
+
+	tests := []struct {
+		node     ast.Node
+		expected string
+	}{
+		{program, input},
+		{program.Statements[0], "let add = fn(x, y) {\n  x + y;\n}"},
+		{program.Statements[0].(*ast.LetStatement).Value, "fn(x, y) {\n  x + y;\n}"},
+		{program.Statements[1], `add(1, "two")`},
+		{program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.CallExpression).Arguments[1], `"two"`},
+	}
+
+	for _, tt := range tests {
+		start, end := ast.Span(tt.node)
+		if got := input[start.Offset:end.Offset]; got != tt.expected {
+			t.Errorf("wrong span for %T. expected=%q, got=%q", tt.node, tt.expected, got)
+		}
+	}
+
+	if pos := ast.Pos(program.Statements[1]); pos.Line != 4 || pos.Column != 1 {
+		t.Errorf("wrong position for call statement: %s", pos)
+	}
+}
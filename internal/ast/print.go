@@ -0,0 +1,71 @@
+package ast
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+var nodeType = reflect.TypeOf((*Node)(nil)).Elem()
+
+// Fprint writes `node` to `w` as an indented tree, one node per line. every node
+// shows its type and token literal, and its child nodes are listed under the
+// name of the field that holds them:
+//
+//	InfixExpression (+)
+//	  Left: IntegerLiteral (1)
+//	  Right: IntegerLiteral (2)
+//
+// it uses reflection so new node types show up without changes here
+func Fprint(w io.Writer, node Node) error {
+	p := &printer{w: w}
+	p.node("", node, 0)
+	return p.err
+}
+
+type printer struct {
+	w   io.Writer
+	err error
+}
+
+func (p *printer) printf(depth int, format string, a ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	_, p.err = fmt.Fprintf(p.w, strings.Repeat("  ", depth)+format+"\n", a...)
+}
+
+func (p *printer) node(label string, node Node, depth int) {
+	v := reflect.ValueOf(node)
+	if node == nil || (v.Kind() == reflect.Pointer && v.IsNil()) {
+		p.printf(depth, "%snil", label)
+		return
+	}
+
+	p.printf(depth, "%s%s (%s)", label, reflect.Indirect(v).Type().Name(), node.TokenLiteral())
+
+	v = reflect.Indirect(v)
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		field := v.Field(i)
+
+		switch {
+		case field.Type().Implements(nodeType):
+			var child Node
+			if !field.IsNil() {
+				child = field.Interface().(Node)
+			}
+			p.node(name+": ", child, depth+1)
+		case field.Kind() == reflect.Slice && field.Type().Elem().Implements(nodeType):
+			if field.Len() == 0 {
+				p.printf(depth+1, "%s: []", name)
+				continue
+			}
+			p.printf(depth+1, "%s:", name)
+			for j := 0; j < field.Len(); j++ {
+				p.node("", field.Index(j).Interface().(Node), depth+2)
+			}
+		}
+	}
+}
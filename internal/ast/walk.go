@@ -0,0 +1,147 @@
+package ast
+
+import "fmt"
+
+// A Visitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children
+// of node with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses an AST in depth-first order, the same way `go/ast` does. it
+// starts by calling v.Visit(node); node must not be nil. if the visitor returned
+// by v.Visit(node) is not nil, Walk is invoked recursively with that visitor for
+// each of the non-nil children of node, followed by a call of w.Visit(nil).
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		walkStatements(v, n.Statements)
+
+	case *LetStatement:
+		if n.Name != nil {
+			Walk(v, n.Name)
+		}
+		if n.Value != nil {
+			Walk(v, n.Value)
+		}
+
+	case *ReturnStatement:
+		if n.ReturnValue != nil {
+			Walk(v, n.ReturnValue)
+		}
+
+	case *ExpressionStatement:
+		if n.Expression != nil {
+			Walk(v, n.Expression)
+		}
+
+	case *BlockStatement:
+		walkStatements(v, n.Statements)
+
+	case *Identifier, *IntegerLiteral, *Boolean, *StringLiteral:
+		// nothing to do
+
+	case *PrefixExpression:
+		if n.Right != nil {
+			Walk(v, n.Right)
+		}
+
+	case *InfixExpression:
+		if n.Left != nil {
+			Walk(v, n.Left)
+		}
+		if n.Right != nil {
+			Walk(v, n.Right)
+		}
+
+	case *IfExpression:
+		if n.Condition != nil {
+			Walk(v, n.Condition)
+		}
+		if n.Consequence != nil {
+			Walk(v, n.Consequence)
+		}
+		if n.Alternative != nil {
+			Walk(v, n.Alternative)
+		}
+
+	case *FunctionLiteral:
+		walkIdentifiers(v, n.Parameters)
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+
+	case *MacroLiteral:
+		walkIdentifiers(v, n.Parameters)
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+
+	case *CallExpression:
+		if n.Function != nil {
+			Walk(v, n.Function)
+		}
+		walkExpressions(v, n.Arguments)
+
+	case *FieldExpression:
+		if n.Object != nil {
+			Walk(v, n.Object)
+		}
+		if n.Field != nil {
+			Walk(v, n.Field)
+		}
+
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+// the parser leaves nil elements in lists it gave up on, like the arguments of
+// `add((z, 1)`. they are skipped like any other missing child
+
+func walkStatements(v Visitor, list []Statement) {
+	for _, s := range list {
+		if s != nil {
+			Walk(v, s)
+		}
+	}
+}
+
+func walkExpressions(v Visitor, list []Expression) {
+	for _, e := range list {
+		if e != nil {
+			Walk(v, e)
+		}
+	}
+}
+
+func walkIdentifiers(v Visitor, list []*Identifier) {
+	for _, ident := range list {
+		if ident != nil {
+			Walk(v, ident)
+		}
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses an AST in depth-first order: it starts by calling f(node);
+// node must not be nil. if f returns true, Inspect invokes f recursively for each
+// of the non-nil children of node, followed by a call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
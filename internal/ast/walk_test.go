@@ -0,0 +1,191 @@
+package ast_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/lexer"
+	"monkey-lang.z9fr.xyz/internal/parser"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+func TestInspectOrder(t *testing.T) {
+	program := parse(t, `
+let f = fn(a, b) { return -a; };
+if (f(1, "s") == p.x) { true } else { !false }
+`)
+
+	var visited []string
+	ast.Inspect(program, func(n ast.Node) bool {
+		if n != nil {
+			visited = append(visited, fmt.Sprintf("%s(%s)",
+				reflect.TypeOf(n).Elem().Name(), n.TokenLiteral()))
+		}
+		return true
+	})
+
+	expected := []string{
+		"Program(let)",
+		"LetStatement(let)",
+		"Identifier(f)",
+		"FunctionLiteral(fn)",
+		"Identifier(a)",
+		"Identifier(b)",
+		"BlockStatement({)",
+		"ReturnStatement(return)",
+		"PrefixExpression(-)",
+		"Identifier(a)",
+		"ExpressionStatement(if)",
+		"IfExpression(if)",
+		"InfixExpression(==)",
+		"CallExpression(()",
+		"Identifier(f)",
+		"IntegerLiteral(1)",
+		"StringLiteral(s)",
+		"FieldExpression(.)",
+		"Identifier(p)",
+		"Identifier(x)",
+		"BlockStatement({)",
+		"ExpressionStatement(true)",
+		"Boolean(true)",
+		"BlockStatement({)",
+		"ExpressionStatement(!)",
+		"PrefixExpression(!)",
+		"Boolean(false)",
+	}
+
+	if !reflect.DeepEqual(visited, expected) {
+		t.Fatalf("wrong visiting order.\nexpected=%v\ngot=     %v", expected, visited)
+	}
+}
+
+// every node reachable through the fields of its parent must be visited. we
+// find those with reflection so a node type added later without updating
+// `Walk` makes this test fail
+func TestWalkVisitsEveryNode(t *testing.T) {
+	program := parse(t, `
+let add = fn(x, y) { x + y; };
+let r = add(1, 2 * 3);
+return if (!r) { "a" } else { p.q };
+fn() { -1 }();
+let m = macro(a) { quote(unquote(a)) };
+`)
+
+	visited := map[ast.Node]bool{}
+	ast.Inspect(program, func(n ast.Node) bool {
+		if n != nil {
+			if visited[n] {
+				t.Errorf("node visited twice: %T %s", n, n)
+			}
+			visited[n] = true
+		}
+		return true
+	})
+
+	expected := collectNodes(reflect.ValueOf(program), nil)
+	for _, n := range expected {
+		if !visited[n] {
+			t.Errorf("node not visited: %T %s", n, n)
+		}
+	}
+
+	if len(visited) != len(expected) {
+		t.Errorf("visited %d nodes, want %d", len(visited), len(expected))
+	}
+}
+
+var nodeType = reflect.TypeOf((*ast.Node)(nil)).Elem()
+
+func collectNodes(v reflect.Value, out []ast.Node) []ast.Node {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return out
+		}
+		return collectNodes(v.Elem(), out)
+	case reflect.Pointer:
+		if v.IsNil() {
+			return out
+		}
+		if v.Type().Implements(nodeType) {
+			out = append(out, v.Interface().(ast.Node))
+		}
+		return collectNodes(v.Elem(), out)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			out = collectNodes(v.Field(i), out)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			out = collectNodes(v.Index(i), out)
+		}
+	}
+	return out
+}
+
+func TestInspectPrune(t *testing.T) {
+	program := parse(t, "let f = fn(x) { x }; f(1);")
+
+	count := 0
+	ast.Inspect(program, func(n ast.Node) bool {
+		if _, ok := n.(*ast.FunctionLiteral); ok {
+			return false
+		}
+		if n != nil {
+			count++
+		}
+		return true
+	})
+
+	// Program, LetStatement, f, ExpressionStatement, CallExpression, f, 1
+	if count != 7 {
+		t.Errorf("expected 7 nodes outside the function body, got %d", count)
+	}
+}
+
+func TestWalkSkipsMissingElements(t *testing.T) {
+	// what the parser leaves behind for input it could not make sense of
+	inputs := []string{"add((z, 1)", "fn(x, { x }", "let x = f(1, ;", "if (x) { 1 } else {"}
+
+	for _, input := range inputs {
+		program := parser.New(lexer.New(input)).ParseProgram()
+
+		count := 0
+		ast.Inspect(program, func(n ast.Node) bool {
+			count++
+			return true
+		})
+		if count == 0 {
+			t.Errorf("%q: nothing visited", input)
+		}
+	}
+
+	call := &ast.CallExpression{
+		Function:  &ast.Identifier{Value: "add"},
+		Arguments: []ast.Expression{nil, &ast.IntegerLiteral{Value: 1}},
+	}
+	var visited []string
+	ast.Inspect(call, func(n ast.Node) bool {
+		if n != nil {
+			visited = append(visited, fmt.Sprintf("%T", n))
+		}
+		return true
+	})
+
+	expected := []string{"*ast.CallExpression", "*ast.Identifier", "*ast.IntegerLiteral"}
+	if !reflect.DeepEqual(visited, expected) {
+		t.Errorf("wrong nodes visited. got=%v, want=%v", visited, expected)
+	}
+}
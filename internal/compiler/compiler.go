@@ -0,0 +1,392 @@
+package compiler
+
+/*
+compiler
+
+lowers an `ast.Program` to bytecode for the vm. names are laid out by
+`resolver.Annotate`, so a function's locals are the slots the evaluator would
+give them and a closure reaches the locals of the functions around it by depth
+and slot. everything else is a global, addressed by its index in
+`Bytecode.Globals`. a global that is used but never defined still gets an
+index, it is an error only when it is read before anything was stored there,
+just like the evaluator reports it only when it gets there
+*/
+
+import (
+	"fmt"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/code"
+	"monkey-lang.z9fr.xyz/internal/object"
+	"monkey-lang.z9fr.xyz/internal/resolver"
+)
+
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+	// the names of the globals, the operand of `OpGetGlobal` and `OpSetGlobal`
+	// is an index in to this
+	Globals []string
+}
+
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+// CompilationScope holds the instructions of the function being compiled
+type CompilationScope struct {
+	instructions        code.Instructions
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+}
+
+type Compiler struct {
+	constants []object.Object
+
+	globals     map[string]int
+	globalNames []string
+
+	scopes     []CompilationScope
+	scopeIndex int
+
+	// the first operand that did not fit in its instruction, e.g. the index of
+	// constant 65536. `Compile` returns it
+	err error
+}
+
+func New() *Compiler {
+	return &Compiler{
+		globals: map[string]int{},
+		scopes:  []CompilationScope{{}},
+	}
+}
+
+func (c *Compiler) Compile(node ast.Node) error {
+	switch node := node.(type) {
+	case *ast.Program:
+		resolver.Annotate(node)
+
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+		// the value of a program is the value of its last statement, when
+		// that is an expression
+		if c.lastInstructionIs(code.OpPop) {
+			c.replaceLastPopWithReturn()
+		} else {
+			c.emit(code.OpReturn)
+		}
+
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.LetStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if node.Name.Local {
+			c.emit(code.OpSetLocal, node.Name.Slot)
+		} else {
+			c.emit(code.OpSetGlobal, c.global(node.Name.Value))
+		}
+
+	case *ast.ReturnStatement:
+		if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+
+	case *ast.Identifier:
+		if node.Local {
+			if node.Depth > 255 || node.Slot > 255 {
+				return fmt.Errorf("%s is out of reach: depth %d, slot %d", node.Value, node.Depth, node.Slot)
+			}
+			c.emit(code.OpGetLocal, node.Depth, node.Slot)
+		} else {
+			c.emit(code.OpGetGlobal, c.global(node.Value))
+		}
+
+	case *ast.IntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: node.Value}))
+
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: node.Value}))
+
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+
+		switch node.Operator {
+		case "!":
+			c.emit(code.OpBang)
+		case "-":
+			c.emit(code.OpMinus)
+		default:
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+
+	case *ast.InfixExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+
+		switch node.Operator {
+		case "+":
+			c.emit(code.OpAdd)
+		case "-":
+			c.emit(code.OpSub)
+		case "*":
+			c.emit(code.OpMul)
+		case "/":
+			c.emit(code.OpDiv)
+		case ">":
+			c.emit(code.OpGreaterThan)
+		case "<":
+			c.emit(code.OpLessThan)
+		case "==":
+			c.emit(code.OpEqual)
+		case "!=":
+			c.emit(code.OpNotEqual)
+		default:
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+
+	case *ast.IfExpression:
+		if err := c.Compile(node.Condition); err != nil {
+			return err
+		}
+
+		// bogus offset, patched once we know where the consequence ends
+		jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+		if err := c.compileBranch(node.Consequence); err != nil {
+			return err
+		}
+
+		jumpPos := c.emit(code.OpJump, 9999)
+		c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+
+		if node.Alternative == nil {
+			c.emit(code.OpNull)
+		} else if err := c.compileBranch(node.Alternative); err != nil {
+			return err
+		}
+
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+
+	case *ast.FunctionLiteral:
+		c.enterScope()
+
+		if err := c.Compile(node.Body); err != nil {
+			return err
+		}
+
+		if c.lastInstructionIs(code.OpPop) {
+			c.replaceLastPopWithReturn()
+		}
+		if !c.lastInstructionIs(code.OpReturnValue) {
+			c.emit(code.OpReturn)
+		}
+
+		instructions := c.leaveScope()
+
+		params := make([]int, len(node.Parameters))
+		for i, p := range node.Parameters {
+			params[i] = p.Slot
+		}
+
+		fn := &object.CompiledFunction{
+			Instructions: instructions,
+			Locals:       node.Locals,
+			Parameters:   params,
+		}
+		c.emit(code.OpClosure, c.addConstant(fn))
+
+	case *ast.CallExpression:
+		if node.Function.TokenLiteral() == "quote" {
+			return fmt.Errorf("quote is not supported by the vm")
+		}
+
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+
+		if len(node.Arguments) > 255 {
+			return fmt.Errorf("too many arguments: %d", len(node.Arguments))
+		}
+		for _, a := range node.Arguments {
+			if err := c.Compile(a); err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpCall, len(node.Arguments))
+
+	case *ast.FieldExpression:
+		if err := c.Compile(node.Object); err != nil {
+			return err
+		}
+		c.emit(code.OpField, c.addConstant(&object.String{Value: node.Field.Value}))
+
+	case *ast.MacroLiteral:
+		return fmt.Errorf("macro literal outside of a top level let, macros are expanded before compiling")
+
+	default:
+		return fmt.Errorf("cannot compile %T", node)
+	}
+
+	return c.err
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Constants:    c.constants,
+		Globals:      c.globalNames,
+	}
+}
+
+// compileBranch compiles the block of an `if` so that it leaves its value on
+// the stack, null when it does not end in an expression
+func (c *Compiler) compileBranch(block *ast.BlockStatement) error {
+	if err := c.Compile(block); err != nil {
+		return err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+
+	return nil
+}
+
+func (c *Compiler) global(name string) int {
+	index, ok := c.globals[name]
+	if !ok {
+		index = len(c.globalNames)
+		c.globals[name] = index
+		c.globalNames = append(c.globalNames, name)
+	}
+	return index
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	c.checkOperands(op, operands...)
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+
+	c.setLastInstruction(op, pos)
+
+	return pos
+}
+
+// checkOperands keeps the first operand that is too big for its instruction,
+// `code.Make` would wrap it around without a word
+func (c *Compiler) checkOperands(op code.Opcode, operands ...int) {
+	if err := code.CheckOperands(op, operands...); err != nil && c.err == nil {
+		c.err = fmt.Errorf("program too large: %w", err)
+	}
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	posNewInstruction := len(c.currentInstructions())
+	c.scopes[c.scopeIndex].instructions = append(c.currentInstructions(), ins...)
+	return posNewInstruction
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: pos}
+
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	last := c.scopes[c.scopeIndex].lastInstruction
+	previous := c.scopes[c.scopeIndex].previousInstruction
+
+	c.scopes[c.scopeIndex].instructions = c.currentInstructions()[:last.Position]
+	c.scopes[c.scopeIndex].lastInstruction = previous
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	c.checkOperands(op, operand)
+	newInstruction := code.Make(op, operand)
+
+	c.replaceInstruction(opPos, newInstruction)
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, CompilationScope{})
+	c.scopeIndex++
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+
+	return instructions
+}
@@ -0,0 +1,364 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"monkey-lang.z9fr.xyz/internal/code"
+	"monkey-lang.z9fr.xyz/internal/lexer"
+	"monkey-lang.z9fr.xyz/internal/object"
+	"monkey-lang.z9fr.xyz/internal/parser"
+)
+
+type compilerTestCase struct {
+	input                string
+	expectedConstants    []interface{}
+	expectedInstructions []code.Instructions
+	expectedGlobals      []string
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1 + 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "1; 2 < 3",
+			expectedConstants: []interface{}{1, 2, 3},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpLessThan),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "-1 == !true",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpMinus),
+				code.Make(code.OpTrue),
+				code.Make(code.OpBang),
+				code.Make(code.OpEqual),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "if (true) { 10 }; 3333;",
+			expectedConstants: []interface{}{10, 3333},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 10),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpJump, 11),
+				// 0010
+				code.Make(code.OpNull),
+				// 0011
+				code.Make(code.OpPop),
+				// 0012
+				code.Make(code.OpConstant, 1),
+				// 0015
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			// a branch that ends in a `let` has no value, it is null
+			input:             "if (true) { let a = 1; } else { 2 }",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 14),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpSetGlobal, 0),
+				// 0010
+				code.Make(code.OpNull),
+				// 0011
+				code.Make(code.OpJump, 17),
+				// 0014
+				code.Make(code.OpConstant, 1),
+				// 0017
+				code.Make(code.OpReturnValue),
+			},
+			expectedGlobals: []string{"a"},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestGlobals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "let one = 1; let two = one; two;",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpGetGlobal, 1),
+				code.Make(code.OpReturnValue),
+			},
+			expectedGlobals: []string{"one", "two"},
+		},
+		{
+			// names that are never defined still get a global, reading it is
+			// an error only at run time
+			input:             "let one = 1;",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpReturn),
+			},
+			expectedGlobals: []string{"one"},
+		},
+		{
+			input:             `puts("hi")`,
+			expectedConstants: []interface{}{"hi"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpReturnValue),
+			},
+			expectedGlobals: []string{"puts"},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestFunctions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: "fn() { }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpReturn),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input: "fn(a, b) { let c = a; return c + b; }(1, 2)",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0, 0),
+					code.Make(code.OpSetLocal, 2),
+					code.Make(code.OpGetLocal, 0, 2),
+					code.Make(code.OpGetLocal, 0, 1),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+				1,
+				2,
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpCall, 2),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			// closures reach the locals around them by depth and slot
+			input: "fn(a) { fn(b) { a + b } }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 1, 0),
+					code.Make(code.OpGetLocal, 0, 0),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpClosure, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "p.name",
+			expectedConstants: []interface{}{"name"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpField, 0),
+				code.Make(code.OpReturnValue),
+			},
+			expectedGlobals: []string{"p"},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestCompilerErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"quote(1)", "quote is not supported by the vm"},
+		{"let m = fn() { macro(x) { x } };", "macro literal outside of a top level let, macros are expanded before compiling"},
+		// operands that don't fit in their instruction
+		{repeat("\"%s\";", 65537), "program too large: OpConstant operand 65536 is out of range 0..65535"},
+		{repeat("let %s = true;", 65537), "program too large: OpSetGlobal operand 65536 is out of range 0..65535"},
+		{"if (true) { " + repeat("true;", 33000) + " }", "program too large: OpJumpNotTruthy operand 66006 is out of range 0..65535"},
+		{"fn() { " + repeat("let %s = true;", 257) + " }", "program too large: OpSetLocal operand 256 is out of range 0..255"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+
+		input := tt.input
+		if len(input) > 40 {
+			input = input[:40] + "..."
+		}
+
+		err := New().Compile(program)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%q: wrong error. want=%q, got=%v", input, tt.expected, err)
+		}
+	}
+}
+
+// repeat joins `n` copies of `format`. a `%s` in it is replaced by a name that
+// is different for each copy, made of letters since identifiers can't have
+// digits
+func repeat(format string, n int) string {
+	var out strings.Builder
+	for i := 0; i < n; i++ {
+		name := "v"
+		for j := i; ; j /= 26 {
+			name += string(rune('a' + j%26))
+			if j < 26 {
+				break
+			}
+		}
+		if strings.Contains(format, "%") {
+			fmt.Fprintf(&out, format, name)
+		} else {
+			out.WriteString(format)
+		}
+	}
+	return out.String()
+}
+
+func runCompilerTests(t *testing.T, tests []compilerTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors for %q: %v", tt.input, p.Errors())
+		}
+
+		compiler := New()
+		if err := compiler.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		bytecode := compiler.Bytecode()
+
+		if err := testInstructions(tt.expectedInstructions, bytecode.Instructions); err != nil {
+			t.Fatalf("%q: testInstructions failed: %s", tt.input, err)
+		}
+
+		if err := testConstants(tt.expectedConstants, bytecode.Constants); err != nil {
+			t.Fatalf("%q: testConstants failed: %s", tt.input, err)
+		}
+
+		if fmt.Sprint(bytecode.Globals) != fmt.Sprint(tt.expectedGlobals) {
+			t.Fatalf("%q: wrong globals. want=%v, got=%v", tt.input, tt.expectedGlobals, bytecode.Globals)
+		}
+	}
+}
+
+func concatInstructions(s []code.Instructions) code.Instructions {
+	out := code.Instructions{}
+	for _, ins := range s {
+		out = append(out, ins...)
+	}
+	return out
+}
+
+func testInstructions(expected []code.Instructions, actual code.Instructions) error {
+	concatted := concatInstructions(expected)
+
+	if len(actual) != len(concatted) {
+		return fmt.Errorf("wrong instructions length.\nwant=%q\ngot =%q", concatted, actual)
+	}
+
+	for i, ins := range concatted {
+		if actual[i] != ins {
+			return fmt.Errorf("wrong instruction at %d.\nwant=%q\ngot =%q", i, concatted, actual)
+		}
+	}
+
+	return nil
+}
+
+func testConstants(expected []interface{}, actual []object.Object) error {
+	if len(expected) != len(actual) {
+		return fmt.Errorf("wrong number of constants. want=%d, got=%d", len(expected), len(actual))
+	}
+
+	for i, constant := range expected {
+		switch constant := constant.(type) {
+		case int:
+			integer, ok := actual[i].(*object.Integer)
+			if !ok || integer.Value != int64(constant) {
+				return fmt.Errorf("constant %d is not Integer %d. got=%T (%+v)", i, constant, actual[i], actual[i])
+			}
+		case string:
+			str, ok := actual[i].(*object.String)
+			if !ok || str.Value != constant {
+				return fmt.Errorf("constant %d is not String %q. got=%T (%+v)", i, constant, actual[i], actual[i])
+			}
+		case []code.Instructions:
+			fn, ok := actual[i].(*object.CompiledFunction)
+			if !ok {
+				return fmt.Errorf("constant %d is not a function. got=%T (%+v)", i, actual[i], actual[i])
+			}
+			if err := testInstructions(constant, fn.Instructions); err != nil {
+				return fmt.Errorf("constant %d: %s", i, err)
+			}
+		}
+	}
+
+	return nil
+}
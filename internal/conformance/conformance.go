@@ -0,0 +1,128 @@
+package conformance
+
+/*
+conformance
+
+the behaviour every engine that runs monkey code has to agree on, written down
+as cases in testdata. the tree walking evaluator, the vm and the optimizer all
+run every case from their tests, a case added here is checked against each of
+them.
+
+a case is monkey source followed by a line that starts with `=>` and the
+result the program must have, the way `Describe` writes it:
+
+	let add = fn(a, b) { a + b };
+	add(1, 2);
+	=> INTEGER 3
+
+lines that start with `#` are comments. the vm has room for `vm.MaxFrames`
+calls at once while the evaluator is limited by the Go stack only, so no case
+recurses deeply
+*/
+
+import (
+	"bufio"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"monkey-lang.z9fr.xyz/internal/object"
+)
+
+//go:embed testdata/*.txt
+var testdata embed.FS
+
+// Case is a program and what it has to evaluate to
+type Case struct {
+	// the file and line the case starts at, like `errors.txt:12`
+	Name  string
+	Input string
+	// the result, as written by `Describe`
+	Expected string
+}
+
+// Check returns an error when `result` is not what the case expects
+func (c Case) Check(result object.Object) error {
+	if got := Describe(result); got != c.Expected {
+		return fmt.Errorf("%s: wrong result.\nwant=%s\ngot=%s", c.Name, c.Expected, got)
+	}
+	return nil
+}
+
+// Describe writes `obj` the way the cases expect it: the type and the value,
+// the message of an error and just NULL for null
+func Describe(obj object.Object) string {
+	switch obj := obj.(type) {
+	case nil:
+		return "nothing"
+	case *object.Null:
+		return "NULL"
+	case *object.Error:
+		return "ERROR " + obj.Message
+	}
+
+	return fmt.Sprintf("%s %s", obj.Type(), obj.Inspect())
+}
+
+// Cases reads every case in testdata, sorted by file
+func Cases() ([]Case, error) {
+	files, err := fs.Glob(testdata, "testdata/*.txt")
+	if err != nil {
+		return nil, err
+	}
+
+	var cases []Case
+	for _, file := range files {
+		data, err := testdata.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		parsed, err := parse(path.Base(file), string(data))
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, parsed...)
+	}
+
+	return cases, nil
+}
+
+func parse(name, src string) ([]Case, error) {
+	var cases []Case
+	var input []string
+	start := 0
+
+	scanner := bufio.NewScanner(strings.NewReader(src))
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+
+		switch {
+		case strings.HasPrefix(text, "#"):
+		case strings.HasPrefix(text, "=>"):
+			if len(input) == 0 {
+				return nil, fmt.Errorf("%s:%d: result without a program", name, line)
+			}
+			cases = append(cases, Case{
+				Name:     fmt.Sprintf("%s:%d", name, start),
+				Input:    strings.Join(input, "\n"),
+				Expected: strings.TrimSpace(strings.TrimPrefix(text, "=>")),
+			})
+			input = nil
+		case strings.TrimSpace(text) == "" && len(input) == 0:
+		default:
+			if len(input) == 0 {
+				start = line
+			}
+			input = append(input, text)
+		}
+	}
+
+	if len(input) != 0 {
+		return nil, fmt.Errorf("%s:%d: program without a result", name, start)
+	}
+
+	return cases, scanner.Err()
+}
@@ -0,0 +1,179 @@
+package coverage
+
+/*
+coverage
+
+records which parts of a program ran while the tree walking evaluator runs it:
+every statement, and both ways out of every `if`. the second way is the
+alternative, or for an `if` without one, going on without running anything.
+
+a `File` records what it is told as the `object.Hooks` of the environment the
+program runs in. only what has a source position is counted, so the program
+has to come from source. the results are written as per file percentages, an
+HTML page with the source and an LCOV tracefile
+*/
+
+import (
+	"sort"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/object"
+)
+
+// File is the coverage of the program in one source file
+type File struct {
+	Name string
+	Src  string
+
+	// how often every statement ran
+	statements map[ast.Statement]int
+	// how often each way out of every `if` was taken, consequence first
+	branches map[*ast.IfExpression]*[2]int
+	// the `if`s in source order
+	ifs []*ast.IfExpression
+}
+
+var _ object.Hooks = (*File)(nil)
+
+// New returns the coverage of `program`, parsed from `src` in the file `name`.
+// the program should have been through macro expansion and
+// `resolver.Annotate`, like any program about to be evaluated
+func New(name, src string, program *ast.Program) *File {
+	f := &File{
+		Name:       name,
+		Src:        src,
+		statements: map[ast.Statement]int{},
+		branches:   map[*ast.IfExpression]*[2]int{},
+	}
+
+	ast.Inspect(program, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.MacroLiteral:
+			// macros ran before the program
+			return false
+		case *ast.BlockStatement:
+		case ast.Statement:
+			if ast.Pos(n).IsValid() {
+				f.statements[n] = 0
+			}
+		case *ast.IfExpression:
+			if n.Token.Pos.IsValid() {
+				f.branches[n] = &[2]int{}
+				f.ifs = append(f.ifs, n)
+			}
+		}
+		return true
+	})
+
+	sort.SliceStable(f.ifs, func(i, j int) bool {
+		return f.ifs[i].Token.Pos.Offset < f.ifs[j].Token.Pos.Offset
+	})
+
+	return f
+}
+
+func (f *File) BeforeStatement(stmt ast.Statement, env *object.Environment) {
+	if n, ok := f.statements[stmt]; ok {
+		f.statements[stmt] = n + 1
+	}
+}
+
+func (f *File) AfterStatement(stmt ast.Statement, env *object.Environment) {}
+
+func (f *File) BeforeCall(call *ast.CallExpression, fn object.Object, args []object.Object, tail bool) {
+}
+
+func (f *File) AfterCall(call *ast.CallExpression, result object.Object) {}
+
+func (f *File) Branch(ie *ast.IfExpression, consequence bool) {
+	counts, ok := f.branches[ie]
+	if !ok {
+		return
+	}
+	if consequence {
+		counts[0]++
+	} else {
+		counts[1]++
+	}
+}
+
+// Statements reports how many statements ran at least once, out of all of them
+func (f *File) Statements() (covered, total int) {
+	for _, n := range f.statements {
+		if n > 0 {
+			covered++
+		}
+	}
+	return covered, len(f.statements)
+}
+
+// Branches reports how many ways out of an `if` were taken, out of all of
+// them. every `if` has two
+func (f *File) Branches() (covered, total int) {
+	for _, counts := range f.branches {
+		for _, n := range counts {
+			if n > 0 {
+				covered++
+			}
+		}
+	}
+	return covered, 2 * len(f.branches)
+}
+
+// Line is what ran of the statements and branches that start on a line
+type Line struct {
+	Number int
+	// how often the statement on the line that ran most often ran
+	Hits int
+	// set when some statement or branch on the line never ran
+	Missed bool
+}
+
+// Lines returns the lines where statements or branches start, in order
+func (f *File) Lines() []Line {
+	lines := map[int]*Line{}
+	add := func(number, hits int) {
+		l, ok := lines[number]
+		if !ok {
+			l = &Line{Number: number}
+			lines[number] = l
+		}
+		if hits > l.Hits {
+			l.Hits = hits
+		}
+		if hits == 0 {
+			l.Missed = true
+		}
+	}
+
+	for stmt, n := range f.statements {
+		add(ast.Pos(stmt).Line, n)
+	}
+	for ie, counts := range f.branches {
+		add(ie.Consequence.Token.Pos.Line, counts[0])
+		add(alternativeLine(ie), counts[1])
+	}
+
+	list := make([]Line, 0, len(lines))
+	for _, l := range lines {
+		list = append(list, *l)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Number < list[j].Number })
+	return list
+}
+
+// alternativeLine is where the alternative of `ie` starts. without one it is
+// where the consequence ends, that is where the program goes on from
+func alternativeLine(ie *ast.IfExpression) int {
+	if ie.Alternative != nil {
+		return ie.Alternative.Token.Pos.Line
+	}
+	return ie.Consequence.Rbrace.Pos.Line
+}
+
+func percent(covered, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return 100 * float64(covered) / float64(total)
+}
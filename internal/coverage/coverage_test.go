@@ -0,0 +1,163 @@
+package coverage
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"monkey-lang.z9fr.xyz/internal/evaluator"
+	"monkey-lang.z9fr.xyz/internal/lexer"
+	"monkey-lang.z9fr.xyz/internal/object"
+	"monkey-lang.z9fr.xyz/internal/parser"
+	"monkey-lang.z9fr.xyz/internal/resolver"
+)
+
+const program = `let abs = fn(n) {
+  if (n < 0) {
+    return -n;
+  }
+  n
+};
+let sign = fn(n) { if (n < 0) { -1 } else { 1 } };
+let unused = fn() {
+  1
+};
+abs(3);
+sign(4);
+`
+
+// cover runs `src` and returns what ran of it
+func cover(t *testing.T, src string) *File {
+	t.Helper()
+
+	p := parser.New(lexer.New(src))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	resolver.Annotate(prog)
+
+	f := New("test.mk", src, prog)
+	env := object.NewEnvironment()
+	env.SetHooks(f)
+	if result := evaluator.Eval(prog, env); result != nil && result.Type() == object.ERROR_OBJ {
+		t.Fatalf("program failed: %s", result.Inspect())
+	}
+	return f
+}
+
+func TestCounts(t *testing.T) {
+	tests := []struct {
+		input                      string
+		statements, statementTotal int
+		branches, branchTotal      int
+	}{
+		{program, 9, 12, 2, 4},
+		{"let x = 1; x;", 2, 2, 0, 0},
+		// an `if` without an alternative that is false took its other way
+		{"if (false) { 1 };", 1, 2, 1, 2},
+		// tail position `if`s are counted as well
+		{"let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(2);", 5, 5, 2, 2},
+	}
+
+	for _, tt := range tests {
+		f := cover(t, tt.input)
+
+		if covered, total := f.Statements(); covered != tt.statements || total != tt.statementTotal {
+			t.Errorf("%q: statements %d/%d, want %d/%d", tt.input, covered, total, tt.statements, tt.statementTotal)
+		}
+		if covered, total := f.Branches(); covered != tt.branches || total != tt.branchTotal {
+			t.Errorf("%q: branches %d/%d, want %d/%d", tt.input, covered, total, tt.branches, tt.branchTotal)
+		}
+	}
+}
+
+func TestLines(t *testing.T) {
+	f := cover(t, program)
+
+	expected := []Line{
+		{Number: 1, Hits: 1},
+		// the `if` ran, its consequence did not
+		{Number: 2, Hits: 1, Missed: true},
+		{Number: 3, Hits: 0, Missed: true},
+		// an `if` without an alternative goes on after its `}`
+		{Number: 4, Hits: 1},
+		{Number: 5, Hits: 1},
+		{Number: 7, Hits: 1, Missed: true},
+		{Number: 8, Hits: 1},
+		{Number: 9, Hits: 0, Missed: true},
+		{Number: 11, Hits: 1},
+		{Number: 12, Hits: 1},
+	}
+
+	lines := f.Lines()
+	if len(lines) != len(expected) {
+		t.Fatalf("got lines %+v, want %+v", lines, expected)
+	}
+	for i, want := range expected {
+		if lines[i] != want {
+			t.Errorf("got %+v, want %+v", lines[i], want)
+		}
+	}
+}
+
+func TestWriteLCOV(t *testing.T) {
+	f := cover(t, "let f = fn(x) {\n  if (x) { 1 } else { 2 }\n};\nf(true);\nf(true);\nif (false) { 3 };\n")
+
+	var out bytes.Buffer
+	if err := WriteLCOV(&out, []*File{f}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `TN:
+SF:test.mk
+BRDA:2,0,0,2
+BRDA:2,0,1,0
+BRDA:6,1,0,0
+BRDA:6,1,1,1
+BRF:4
+BRH:2
+DA:1,1
+DA:2,2
+DA:4,1
+DA:5,1
+DA:6,1
+LF:5
+LH:5
+end_of_record
+`
+	if out.String() != expected {
+		t.Errorf("wrong tracefile.\ngot:\n%s\nwant:\n%s", out.String(), expected)
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	f := cover(t, "let x = 1;\nif (x < 0) {\n  puts(\"<negative>\");\n}\n")
+
+	var out bytes.Buffer
+	if err := WriteHTML(&out, []*File{f}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		`<span class="line covered" title="ran once"><span class="number">1</span>let x = 1;</span>`,
+		`<span class="line partial" title="ran once, not all of it"><span class="number">2</span>if (x &lt; 0) {</span>`,
+		`<span class="line uncovered" title="never ran"><span class="number">3</span>  puts(&#34;&lt;negative&gt;&#34;);</span>`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("the page is missing %s\n%s", want, out.String())
+		}
+	}
+}
+
+func TestWriteSummary(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteSummary(&out, []*File{cover(t, program)}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "test.mk\tcoverage: 75.0% of statements (9/12), 50.0% of branches (2/4)\n"
+	if out.String() != expected {
+		t.Errorf("got %q, want %q", out.String(), expected)
+	}
+}
@@ -0,0 +1,154 @@
+package coverage
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// WriteSummary writes a line with the percentages of each file
+func WriteSummary(w io.Writer, files []*File) error {
+	for _, f := range files {
+		stmts, stmtTotal := f.Statements()
+		branches, branchTotal := f.Branches()
+
+		_, err := fmt.Fprintf(w, "%s\tcoverage: %.1f%% of statements (%d/%d), %.1f%% of branches (%d/%d)\n",
+			f.Name,
+			percent(stmts, stmtTotal), stmts, stmtTotal,
+			percent(branches, branchTotal), branches, branchTotal)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteLCOV writes the coverage of `files` as an LCOV tracefile, the format
+// `genhtml` and most coverage services read
+func WriteLCOV(w io.Writer, files []*File) error {
+	var b strings.Builder
+
+	for _, f := range files {
+		fmt.Fprintf(&b, "TN:\nSF:%s\n", f.Name)
+
+		// every `if` is a block with the consequence as branch 0 and the
+		// alternative as branch 1. an `if` that never ran has "-" for both
+		branches, branchHits := 0, 0
+		for i, ie := range f.ifs {
+			counts := f.branches[ie]
+			for branch, n := range counts {
+				taken := "-"
+				if counts[0]+counts[1] > 0 {
+					taken = fmt.Sprint(n)
+				}
+				fmt.Fprintf(&b, "BRDA:%d,%d,%d,%s\n", ie.Token.Pos.Line, i, branch, taken)
+
+				branches++
+				if n > 0 {
+					branchHits++
+				}
+			}
+		}
+		fmt.Fprintf(&b, "BRF:%d\nBRH:%d\n", branches, branchHits)
+
+		lines, lineHits := 0, 0
+		for _, l := range f.Lines() {
+			fmt.Fprintf(&b, "DA:%d,%d\n", l.Number, l.Hits)
+
+			lines++
+			if l.Hits > 0 {
+				lineHits++
+			}
+		}
+		fmt.Fprintf(&b, "LF:%d\nLH:%d\nend_of_record\n", lines, lineHits)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+type htmlLine struct {
+	Number int
+	Text   string
+	// "covered", "partial", "uncovered", or "" for lines nothing starts on
+	Class string
+	Title string
+}
+
+type htmlFile struct {
+	Name    string
+	Summary string
+	Lines   []htmlLine
+}
+
+var page = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>monkey coverage</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+pre { font-family: monospace; line-height: 1.3; }
+.line { display: block; }
+.number { display: inline-block; width: 4em; color: #999; text-align: right; margin-right: 1em; user-select: none; }
+.covered { background: #dfd; }
+.partial { background: #ffd; }
+.uncovered { background: #fdd; }
+</style>
+</head>
+<body>
+{{range .}}<h2>{{.Name}}</h2>
+<p>{{.Summary}}</p>
+<pre>{{range .Lines}}<span class="line {{.Class}}"{{if .Title}} title="{{.Title}}"{{end}}><span class="number">{{.Number}}</span>{{.Text}}</span>{{end}}</pre>
+{{end}}</body>
+</html>
+`))
+
+// WriteHTML writes a page with the source of each file, lines that ran are
+// green and lines that never ran red. a line where only some of what starts
+// on it ran, like an `if` that always went the same way, is yellow
+func WriteHTML(w io.Writer, files []*File) error {
+	var data []htmlFile
+
+	for _, f := range files {
+		stmts, stmtTotal := f.Statements()
+		branches, branchTotal := f.Branches()
+		hf := htmlFile{
+			Name: f.Name,
+			Summary: fmt.Sprintf("%.1f%% of statements, %.1f%% of branches",
+				percent(stmts, stmtTotal), percent(branches, branchTotal)),
+		}
+
+		status := map[int]Line{}
+		for _, l := range f.Lines() {
+			status[l.Number] = l
+		}
+
+		for i, text := range strings.Split(strings.TrimSuffix(f.Src, "\n"), "\n") {
+			line := htmlLine{Number: i + 1, Text: text}
+			if l, ok := status[i+1]; ok {
+				switch {
+				case l.Hits == 0:
+					line.Class, line.Title = "uncovered", "never ran"
+				case l.Missed:
+					line.Class, line.Title = "partial", "ran "+times(l.Hits)+", not all of it"
+				default:
+					line.Class, line.Title = "covered", "ran "+times(l.Hits)
+				}
+			}
+			hf.Lines = append(hf.Lines, line)
+		}
+
+		data = append(data, hf)
+	}
+
+	return page.Execute(w, data)
+}
+
+func times(n int) string {
+	if n == 1 {
+		return "once"
+	}
+	return fmt.Sprintf("%d times", n)
+}
@@ -0,0 +1,563 @@
+package dap
+
+/*
+dap
+
+serves the Debug Adapter Protocol, which is how editors talk to debuggers. the
+program runs in a goroutine of its own under a `debugger.Debugger`. when it
+stops, the server tells the editor with a `stopped` event and the goroutine
+waits until a `continue` or a step lets it go on. requests that look at the
+program, like `stackTrace` or `variables`, are only answered while it is
+stopped.
+
+monkey has a single thread, its id is always 1. frames are numbered from 1 for
+the innermost one and variable references are only good until the program
+goes on, both the way the protocol expects
+*/
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/debugger"
+	"monkey-lang.z9fr.xyz/internal/object"
+)
+
+const threadID = 1
+
+// Launcher loads the program at `path` for debugging, along with the
+// environment it runs in. output of the program should go to `out`
+type Launcher func(path string, out io.Writer) (*ast.Program, *object.Environment, error)
+
+var errRunning = errors.New("the program is running")
+
+// Serve answers requests from `in` on `out` until the editor disconnects or
+// `in` runs out
+func Serve(in io.Reader, out io.Writer, launch Launcher) error {
+	s := &server{
+		in:     bufio.NewReader(in),
+		out:    out,
+		launch: launch,
+		resume: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	return s.serve()
+}
+
+type server struct {
+	in     *bufio.Reader
+	out    io.Writer
+	launch Launcher
+
+	// messages are written by the request loop and by the program's
+	// goroutine
+	wmu sync.Mutex
+	seq int
+
+	d       *debugger.Debugger
+	env     *object.Environment
+	path    string
+	started bool
+
+	// guards `stopped` and `handles`, which the program's goroutine changes
+	// when it stops
+	mu      sync.Mutex
+	stopped bool
+	// what variable references point at, reference `i` is `handles[i-1]`
+	handles []handle
+
+	// the program goes on when it receives from `resume`
+	resume chan struct{}
+	// closed once the program is done
+	done chan struct{}
+}
+
+// handle is something the editor can expand: the bindings of an environment,
+// and with `outer` also an entry for the environment around it
+type handle struct {
+	env   *object.Environment
+	outer bool
+}
+
+type handler func(s *server, req *request, args json.RawMessage) (interface{}, error)
+
+var handlers = map[string]handler{
+	"initialize":        (*server).initialize,
+	"launch":            (*server).launchProgram,
+	"setBreakpoints":    (*server).setBreakpoints,
+	"configurationDone": (*server).configurationDone,
+	"threads":           (*server).threads,
+	"stackTrace":        (*server).stackTrace,
+	"scopes":            (*server).scopes,
+	"variables":         (*server).variables,
+	"evaluate":          (*server).evaluate,
+	"continue":          resumeWith((*debugger.Debugger).Continue),
+	"next":              resumeWith((*debugger.Debugger).StepOver),
+	"stepIn":            resumeWith((*debugger.Debugger).StepIn),
+	"stepOut":           resumeWith(stepOut),
+	"disconnect":        (*server).disconnect,
+	"terminate":         (*server).disconnect,
+}
+
+func (s *server) serve() error {
+	// whatever ends the session, the program must not outlive it
+	defer s.stop()
+
+	for {
+		data, err := readMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(data, &req); err != nil {
+			return fmt.Errorf("bad message: %w", err)
+		}
+		if req.Type != "request" {
+			continue
+		}
+
+		handler, ok := handlers[req.Command]
+		if !ok {
+			s.respond(&req, nil, fmt.Errorf("unsupported request %q", req.Command))
+			continue
+		}
+
+		body, err := handler(s, &req, req.Arguments)
+		s.respond(&req, body, err)
+
+		switch req.Command {
+		case "launch":
+			// the editor sends breakpoints and `configurationDone` after this
+			if err == nil {
+				s.event("initialized", nil)
+			}
+		case "continue", "next", "stepIn", "stepOut":
+			// the response goes out before the events of the program going on
+			if err == nil {
+				s.resume <- struct{}{}
+			}
+		case "disconnect", "terminate":
+			return nil
+		}
+	}
+}
+
+func (s *server) send(msg interface{}) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	s.seq++
+	switch msg := msg.(type) {
+	case *response:
+		msg.Seq = s.seq
+	case *event:
+		msg.Seq = s.seq
+	}
+
+	// a write error means the editor is gone, the next read says so
+	writeMessage(s.out, msg)
+}
+
+func (s *server) respond(req *request, body interface{}, err error) {
+	resp := &response{
+		Type:       "response",
+		RequestSeq: req.Seq,
+		Success:    err == nil,
+		Command:    req.Command,
+		Body:       body,
+	}
+	if err != nil {
+		resp.Message = err.Error()
+		resp.Body = nil
+	}
+	s.send(resp)
+}
+
+func (s *server) event(name string, body interface{}) {
+	s.send(&event{Type: "event", Event: name, Body: body})
+}
+
+func (s *server) initialize(req *request, args json.RawMessage) (interface{}, error) {
+	return map[string]bool{
+		"supportsConfigurationDoneRequest": true,
+		"supportsEvaluateForHovers":        true,
+		"supportsTerminateRequest":         true,
+	}, nil
+}
+
+func (s *server) launchProgram(req *request, args json.RawMessage) (interface{}, error) {
+	var a struct {
+		Program     string `json:"program"`
+		StopOnEntry bool   `json:"stopOnEntry"`
+	}
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, err
+	}
+	if a.Program == "" {
+		return nil, errors.New("launch needs a program")
+	}
+	if s.d != nil {
+		return nil, errors.New("a program is already launched")
+	}
+
+	program, env, err := s.launch(a.Program, outputWriter{s, "stdout"})
+	if err != nil {
+		return nil, err
+	}
+
+	s.d = debugger.New(program, s)
+	s.d.StopOnEntry = a.StopOnEntry
+	s.env = env
+	s.path = cleanPath(a.Program)
+	return nil, nil
+}
+
+func (s *server) setBreakpoints(req *request, args json.RawMessage) (interface{}, error) {
+	var a struct {
+		Source      source `json:"source"`
+		Breakpoints []struct {
+			Line int `json:"line"`
+		} `json:"breakpoints"`
+	}
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, err
+	}
+	if s.d == nil {
+		return nil, errors.New("no program is launched")
+	}
+
+	// only the program's own file has statements
+	ours := cleanPath(a.Source.Path) == s.path
+	if ours {
+		s.d.ClearBreakpoints()
+	}
+
+	breakpoints := make([]breakpoint, len(a.Breakpoints))
+	for i, bp := range a.Breakpoints {
+		breakpoints[i] = breakpoint{Line: bp.Line}
+		switch {
+		case !ours:
+			breakpoints[i].Message = "not the program being debugged"
+		case s.d.SetBreakpoint(bp.Line):
+			breakpoints[i].Verified = true
+		default:
+			breakpoints[i].Message = "no statement starts on this line"
+		}
+	}
+
+	return map[string]interface{}{"breakpoints": breakpoints}, nil
+}
+
+func (s *server) configurationDone(req *request, args json.RawMessage) (interface{}, error) {
+	if s.d == nil {
+		return nil, errors.New("no program is launched")
+	}
+	if s.started {
+		return nil, nil
+	}
+
+	s.started = true
+	go s.run()
+	return nil, nil
+}
+
+// run runs the program, in its own goroutine
+func (s *server) run() {
+	defer close(s.done)
+
+	exitCode := 0
+	result, err := s.d.Run(s.env)
+	if errObj, ok := result.(*object.Error); ok && err == nil {
+		s.event("output", map[string]string{"category": "stderr", "output": errObj.Inspect() + "\n"})
+		exitCode = 1
+	}
+
+	s.event("exited", map[string]int{"exitCode": exitCode})
+	s.event("terminated", nil)
+}
+
+// Stopped is called in the program's goroutine, it waits until a request
+// lets the program go on
+func (s *server) Stopped(d *debugger.Debugger, reason string) {
+	s.mu.Lock()
+	s.stopped = true
+	s.handles = nil
+	s.mu.Unlock()
+
+	s.event("stopped", map[string]interface{}{
+		"reason":            reason,
+		"threadId":          threadID,
+		"allThreadsStopped": true,
+	})
+
+	<-s.resume
+}
+
+// stepOut out of the program itself runs it to the end
+func stepOut(d *debugger.Debugger) {
+	if !d.StepOut() {
+		d.Continue()
+	}
+}
+
+// resumeWith returns the handler of a request that lets the program go on
+// after `move` said how
+func resumeWith(move func(d *debugger.Debugger)) handler {
+	return func(s *server, req *request, args json.RawMessage) (interface{}, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if !s.stopped {
+			return nil, errRunning
+		}
+
+		move(s.d)
+		s.stopped = false
+		s.handles = nil
+
+		if req.Command == "continue" {
+			return map[string]bool{"allThreadsContinued": true}, nil
+		}
+		return nil, nil
+	}
+}
+
+func (s *server) disconnect(req *request, args json.RawMessage) (interface{}, error) {
+	s.stop()
+	return nil, nil
+}
+
+// stop ends the program, if there is one, and waits for it
+func (s *server) stop() {
+	if !s.started {
+		return
+	}
+
+	s.d.Quit()
+
+	// a stopped program quits once it goes on, a running one before its
+	// next statement
+	for {
+		select {
+		case s.resume <- struct{}{}:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *server) threads(req *request, args json.RawMessage) (interface{}, error) {
+	return map[string]interface{}{
+		"threads": []map[string]interface{}{{"id": threadID, "name": "main"}},
+	}, nil
+}
+
+func (s *server) stackTrace(req *request, args json.RawMessage) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.stopped {
+		return nil, errRunning
+	}
+
+	var a struct {
+		StartFrame int `json:"startFrame"`
+		Levels     int `json:"levels"`
+	}
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, err
+	}
+
+	frames := s.d.Frames()
+	stack := []stackFrame{}
+	for i := a.StartFrame; i < len(frames); i++ {
+		if a.Levels > 0 && len(stack) == a.Levels {
+			break
+		}
+
+		f := frames[i]
+		stack = append(stack, stackFrame{
+			ID:     i + 1,
+			Name:   f.Name,
+			Source: source{Name: filepath.Base(s.path), Path: s.path},
+			Line:   f.Pos.Line,
+			Column: f.Pos.Column,
+		})
+	}
+
+	return map[string]interface{}{"stackFrames": stack, "totalFrames": len(frames)}, nil
+}
+
+func (s *server) scopes(req *request, args json.RawMessage) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.stopped {
+		return nil, errRunning
+	}
+
+	var a struct {
+		FrameID int `json:"frameId"`
+	}
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, err
+	}
+
+	frames := s.d.Frames()
+	if a.FrameID < 1 || a.FrameID > len(frames) {
+		return nil, fmt.Errorf("no frame %d", a.FrameID)
+	}
+
+	// one scope for each environment out to the top level one
+	scopes := []scope{}
+	top := frames[a.FrameID-1].Env
+	for env := top; env != nil; env = env.Outer() {
+		sc := scope{Name: "Closure", VariablesReference: s.handle(env, false)}
+		switch {
+		case env.Outer() == nil:
+			sc.Name, sc.Expensive = "Globals", true
+		case env == top:
+			sc.Name, sc.PresentationHint = "Locals", "locals"
+		}
+		scopes = append(scopes, sc)
+	}
+
+	return map[string]interface{}{"scopes": scopes}, nil
+}
+
+func (s *server) variables(req *request, args json.RawMessage) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.stopped {
+		return nil, errRunning
+	}
+
+	var a struct {
+		VariablesReference int `json:"variablesReference"`
+	}
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, err
+	}
+	if a.VariablesReference < 1 || a.VariablesReference > len(s.handles) {
+		return nil, fmt.Errorf("no variables with reference %d", a.VariablesReference)
+	}
+
+	h := s.handles[a.VariablesReference-1]
+	vars := []variable{}
+	for _, name := range h.env.Names() {
+		val, _ := h.env.Get(name)
+		vars = append(vars, s.variable(name, val))
+	}
+	if h.outer && h.env.Outer() != nil {
+		vars = append(vars, variable{
+			Name:               "(outer)",
+			Value:              "environment",
+			VariablesReference: s.handle(h.env.Outer(), true),
+		})
+	}
+
+	return map[string]interface{}{"variables": vars}, nil
+}
+
+func (s *server) evaluate(req *request, args json.RawMessage) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.stopped {
+		return nil, errRunning
+	}
+
+	var a struct {
+		Expression string `json:"expression"`
+		FrameID    int    `json:"frameId"`
+	}
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, err
+	}
+
+	frame := 0
+	if a.FrameID > 0 {
+		frame = a.FrameID - 1
+	}
+
+	result, err := s.d.Eval(a.Expression, frame)
+	if err != nil {
+		return nil, err
+	}
+	if errObj, ok := result.(*object.Error); ok {
+		return nil, errors.New(errObj.Message)
+	}
+	if result == nil {
+		return map[string]interface{}{"result": "", "variablesReference": 0}, nil
+	}
+
+	v := s.variable("", result)
+	return map[string]interface{}{
+		"result":             v.Value,
+		"type":               v.Type,
+		"variablesReference": v.VariablesReference,
+	}, nil
+}
+
+// variable describes `val` for the editor. a function can be expanded in to
+// the environment it closes over, a name bound to nothing shows as null.
+// `s.mu` must be held
+func (s *server) variable(name string, val object.Object) variable {
+	if val == nil {
+		return variable{Name: name, Value: "null", Type: string(object.NULL_OBJ)}
+	}
+	v := variable{Name: name, Type: string(val.Type())}
+
+	switch val := val.(type) {
+	case *object.String:
+		v.Value = strconv.Quote(val.Value)
+	case *object.Function:
+		params := make([]string, len(val.Parameters))
+		for i, p := range val.Parameters {
+			params[i] = p.String()
+		}
+		v.Value = "fn(" + strings.Join(params, ", ") + ")"
+		v.VariablesReference = s.handle(val.Env, true)
+	default:
+		v.Value = val.Inspect()
+	}
+
+	return v
+}
+
+// handle returns a new variable reference for `env`. `s.mu` must be held
+func (s *server) handle(env *object.Environment, outer bool) int {
+	s.handles = append(s.handles, handle{env: env, outer: outer})
+	return len(s.handles)
+}
+
+// outputWriter sends what the program writes to the editor
+type outputWriter struct {
+	s        *server
+	category string
+}
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	w.s.event("output", map[string]string{"category": w.category, "output": string(p)})
+	return len(p), nil
+}
+
+func cleanPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
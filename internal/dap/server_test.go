@@ -0,0 +1,484 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/evaluator"
+	"monkey-lang.z9fr.xyz/internal/lexer"
+	"monkey-lang.z9fr.xyz/internal/object"
+	"monkey-lang.z9fr.xyz/internal/parser"
+	"monkey-lang.z9fr.xyz/internal/resolver"
+)
+
+const program = `let makeAdder = fn(x) { fn(y) { x + y } };
+let add = fn(a, b) {
+  let sum = a + b;
+  sum
+};
+let addOne = makeAdder(1);
+puts(add(addOne(0), 2));
+`
+
+func launch(path string, out io.Writer) (*ast.Program, *object.Environment, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p := parser.New(lexer.New(string(src)))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, nil, errors.New(strings.Join(p.Errors(), "\n"))
+	}
+	resolver.Annotate(prog)
+
+	env := object.NewEnvironment()
+	evaluator.Bind(env, "puts", func(args ...object.Object) {
+		for _, arg := range args {
+			fmt.Fprintln(out, arg.Inspect())
+		}
+	})
+	return prog, env, nil
+}
+
+// client is a scripted editor
+type client struct {
+	t    *testing.T
+	in   *bufio.Reader
+	out  io.WriteCloser
+	seq  int
+	msgs chan map[string]interface{}
+	errc chan error
+}
+
+func newClient(t *testing.T) *client {
+	t.Helper()
+
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+
+	c := &client{
+		t:    t,
+		in:   bufio.NewReader(clientIn),
+		out:  clientOut,
+		msgs: make(chan map[string]interface{}, 100),
+		errc: make(chan error, 1),
+	}
+
+	go func() {
+		c.errc <- Serve(serverIn, serverOut, launch)
+		serverOut.Close()
+	}()
+
+	go func() {
+		defer close(c.msgs)
+		for {
+			data, err := readMessage(c.in)
+			if err != nil {
+				return
+			}
+			var msg map[string]interface{}
+			if err := json.Unmarshal(data, &msg); err != nil {
+				t.Errorf("server sent bad JSON: %s", data)
+				return
+			}
+			c.msgs <- msg
+		}
+	}()
+
+	return c
+}
+
+func (c *client) send(command string, args interface{}) {
+	c.t.Helper()
+
+	c.seq++
+	msg := map[string]interface{}{"seq": c.seq, "type": "request", "command": command}
+	if args != nil {
+		msg["arguments"] = args
+	}
+	if err := writeMessage(c.out, msg); err != nil {
+		c.t.Fatalf("sending %s: %s", command, err)
+	}
+}
+
+// next returns the next message, events and responses alike
+func (c *client) next() map[string]interface{} {
+	c.t.Helper()
+
+	select {
+	case msg, ok := <-c.msgs:
+		if !ok {
+			c.t.Fatal("server closed the connection")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timed out waiting for the server")
+	}
+	return nil
+}
+
+// expect skips messages until the response to `command` or the event of that
+// name, whichever is asked for, and returns its body. output events on the way
+// are collected in `output`
+func (c *client) expect(typ, name string, output *strings.Builder) map[string]interface{} {
+	c.t.Helper()
+
+	for {
+		msg := c.next()
+		if msg["type"] == "event" && msg["event"] == "output" && output != nil {
+			body := msg["body"].(map[string]interface{})
+			output.WriteString(body["output"].(string))
+		}
+
+		key := "event"
+		if msg["type"] == "response" {
+			key = "command"
+		}
+		if msg["type"] != typ || msg[key] != name {
+			continue
+		}
+
+		if typ == "response" && msg["success"] != true {
+			c.t.Fatalf("%s failed: %v", name, msg["message"])
+		}
+		body, _ := msg["body"].(map[string]interface{})
+		return body
+	}
+}
+
+// request sends `command` and returns the body of its response
+func (c *client) request(command string, args interface{}) map[string]interface{} {
+	c.t.Helper()
+	c.send(command, args)
+	return c.expect("response", command, nil)
+}
+
+// failure sends `command`, which must fail, and returns the error message
+func (c *client) failure(command string, args interface{}) string {
+	c.t.Helper()
+
+	c.send(command, args)
+	for {
+		msg := c.next()
+		if msg["type"] == "response" && msg["command"] == command {
+			if msg["success"] == true {
+				c.t.Fatalf("%s succeeded, expected it to fail", command)
+			}
+			return msg["message"].(string)
+		}
+	}
+}
+
+func writeProgram(t *testing.T, src string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.mk")
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func (c *client) start(path string, stopOnEntry bool, lines ...int) []interface{} {
+	c.t.Helper()
+
+	c.request("initialize", map[string]string{"adapterID": "monkey"})
+	c.request("launch", map[string]interface{}{"program": path, "stopOnEntry": stopOnEntry})
+	c.expect("event", "initialized", nil)
+
+	breakpoints := []map[string]int{}
+	for _, line := range lines {
+		breakpoints = append(breakpoints, map[string]int{"line": line})
+	}
+	body := c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]string{"path": path},
+		"breakpoints": breakpoints,
+	})
+	c.request("configurationDone", nil)
+
+	return body["breakpoints"].([]interface{})
+}
+
+func (c *client) stopped(reason string) {
+	c.t.Helper()
+
+	body := c.expect("event", "stopped", nil)
+	if body["reason"] != reason {
+		c.t.Fatalf("stopped because of %v, want %s", body["reason"], reason)
+	}
+}
+
+// frames returns "name:line" for every frame of the stack
+func (c *client) frames() []string {
+	c.t.Helper()
+
+	body := c.request("stackTrace", map[string]int{"threadId": 1})
+	var frames []string
+	for _, f := range body["stackFrames"].([]interface{}) {
+		f := f.(map[string]interface{})
+		frames = append(frames, fmt.Sprintf("%s:%v", f["name"], f["line"]))
+	}
+	return frames
+}
+
+// variables returns "name=value" for every variable under `ref`, and the
+// references of those that can be expanded
+func (c *client) variables(ref int) ([]string, map[string]int) {
+	c.t.Helper()
+
+	body := c.request("variables", map[string]int{"variablesReference": ref})
+	var vars []string
+	refs := map[string]int{}
+	for _, v := range body["variables"].([]interface{}) {
+		v := v.(map[string]interface{})
+		vars = append(vars, fmt.Sprintf("%s=%s", v["name"], v["value"]))
+		if r := int(v["variablesReference"].(float64)); r != 0 {
+			refs[v["name"].(string)] = r
+		}
+	}
+	return vars, refs
+}
+
+func (c *client) finish(output *strings.Builder) {
+	c.t.Helper()
+
+	body := c.expect("event", "exited", output)
+	if body["exitCode"] != float64(0) {
+		c.t.Errorf("exit code %v, want 0", body["exitCode"])
+	}
+	c.expect("event", "terminated", nil)
+
+	c.request("disconnect", nil)
+	if err := <-c.errc; err != nil {
+		c.t.Errorf("Serve returned %s", err)
+	}
+}
+
+func equal(t *testing.T, what string, got, want []string) {
+	t.Helper()
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("%s: got %v, want %v", what, got, want)
+	}
+}
+
+func TestSession(t *testing.T) {
+	path := writeProgram(t, program)
+	c := newClient(t)
+
+	verified := c.start(path, false, 3, 5)
+	if verified[0].(map[string]interface{})["verified"] != true {
+		t.Errorf("breakpoint on line 3 is not verified: %v", verified[0])
+	}
+	if verified[1].(map[string]interface{})["verified"] != false {
+		t.Errorf("breakpoint on line 5 is verified: %v", verified[1])
+	}
+
+	c.stopped("breakpoint")
+
+	threads := c.request("threads", nil)["threads"].([]interface{})
+	if len(threads) != 1 {
+		t.Errorf("expected one thread, got %v", threads)
+	}
+
+	equal(t, "stack", c.frames(), []string{"add:3", "<program>:7"})
+
+	scopes := c.request("scopes", map[string]int{"frameId": 1})["scopes"].([]interface{})
+	var names []string
+	refs := map[string]int{}
+	for _, s := range scopes {
+		s := s.(map[string]interface{})
+		names = append(names, s["name"].(string))
+		refs[s["name"].(string)] = int(s["variablesReference"].(float64))
+	}
+	equal(t, "scopes", names, []string{"Locals", "Globals"})
+
+	locals, _ := c.variables(refs["Locals"])
+	equal(t, "locals", locals, []string{"a=1", "b=2"})
+
+	globals, funcs := c.variables(refs["Globals"])
+	equal(t, "globals", globals, []string{
+		"add=fn(a, b)", "addOne=fn(y)", "makeAdder=fn(x)", "puts=go:puts func(...object.Object)",
+	})
+
+	// a closure expands in to the environment it closes over
+	closure, outer := c.variables(funcs["addOne"])
+	equal(t, "closure", closure, []string{"x=1", "(outer)=environment"})
+	above, _ := c.variables(outer["(outer)"])
+	if len(above) != 4 {
+		t.Errorf("the environment around the closure should be the globals, got %v", above)
+	}
+
+	result := c.request("evaluate", map[string]interface{}{"expression": "a * 10 + b", "frameId": 1})
+	if result["result"] != "12" {
+		t.Errorf("evaluate gave %v, want 12", result["result"])
+	}
+	if msg := c.failure("evaluate", map[string]interface{}{"expression": "nope", "frameId": 1}); msg != "identifier not found: nope" {
+		t.Errorf("evaluate of an unknown name failed with %q", msg)
+	}
+
+	c.request("next", map[string]int{"threadId": 1})
+	c.stopped("step")
+	equal(t, "after next", c.frames(), []string{"add:4", "<program>:7"})
+
+	c.request("continue", map[string]int{"threadId": 1})
+
+	var output strings.Builder
+	c.finish(&output)
+	if output.String() != "3\n" {
+		t.Errorf("program output %q, want %q", output.String(), "3\n")
+	}
+}
+
+func TestEmptyValues(t *testing.T) {
+	path := writeProgram(t, "let f = fn() { };\nlet x = f();\nlet y = if (true) { };\n1;\n")
+	c := newClient(t)
+	c.start(path, false, 4)
+	c.stopped("breakpoint")
+
+	var ref int
+	for _, s := range c.request("scopes", map[string]int{"frameId": 1})["scopes"].([]interface{}) {
+		s := s.(map[string]interface{})
+		if s["name"] == "Globals" {
+			ref = int(s["variablesReference"].(float64))
+		}
+	}
+
+	// an empty block leaves nothing in `y`, it is shown like null
+	globals, _ := c.variables(ref)
+	equal(t, "globals", globals, []string{"f=fn()", "puts=go:puts func(...object.Object)", "x=null", "y=null"})
+
+	c.request("continue", map[string]int{"threadId": 1})
+	c.finish(nil)
+}
+
+func TestStepping(t *testing.T) {
+	path := writeProgram(t, program)
+	c := newClient(t)
+
+	c.start(path, true)
+	c.stopped("entry")
+	equal(t, "entry", c.frames(), []string{"<program>:1"})
+
+	steps := []struct {
+		command string
+		frames  []string
+	}{
+		{"next", []string{"<program>:2"}},
+		{"next", []string{"<program>:6"}},
+		{"stepIn", []string{"makeAdder:1", "<program>:6"}},
+		{"stepOut", []string{"<program>:7"}},
+		{"stepIn", []string{"addOne:1", "<program>:7"}},
+		{"stepIn", []string{"add:3", "<program>:7"}},
+	}
+
+	for _, step := range steps {
+		c.request(step.command, map[string]int{"threadId": 1})
+		c.stopped("step")
+		equal(t, "after "+step.command, c.frames(), step.frames)
+	}
+
+	c.request("continue", map[string]int{"threadId": 1})
+	c.finish(nil)
+}
+
+func TestRequestsWhileRunning(t *testing.T) {
+	path := writeProgram(t, "let loop = fn(n) { if (n == 0) { 0 } else { loop(n - 1) } };\nloop(100000000);\n")
+	c := newClient(t)
+
+	c.start(path, false)
+
+	if msg := c.failure("stackTrace", map[string]int{"threadId": 1}); msg != errRunning.Error() {
+		t.Errorf("stackTrace while running failed with %q", msg)
+	}
+	if msg := c.failure("continue", map[string]int{"threadId": 1}); msg != errRunning.Error() {
+		t.Errorf("continue while running failed with %q", msg)
+	}
+
+	// disconnecting ends the program
+	c.request("disconnect", nil)
+	if err := <-c.errc; err != nil {
+		t.Errorf("Serve returned %s", err)
+	}
+}
+
+func TestLaunchErrors(t *testing.T) {
+	c := newClient(t)
+	c.request("initialize", nil)
+
+	if msg := c.failure("launch", map[string]string{"program": filepath.Join(t.TempDir(), "missing.mk")}); !strings.Contains(msg, "no such file") {
+		t.Errorf("launching a missing file failed with %q", msg)
+	}
+	if msg := c.failure("launch", map[string]string{"program": writeProgram(t, "let = 1;")}); !strings.Contains(msg, "expected next token") {
+		t.Errorf("launching a broken file failed with %q", msg)
+	}
+	if msg := c.failure("goto", nil); msg != `unsupported request "goto"` {
+		t.Errorf("unknown request failed with %q", msg)
+	}
+
+	c.out.Close()
+	if err := <-c.errc; err != nil {
+		t.Errorf("Serve returned %s", err)
+	}
+}
+
+func TestBadMessageStopsProgram(t *testing.T) {
+	c := newClient(t)
+	c.start(writeProgram(t, program), true)
+	c.stopped("entry")
+
+	// the program is waiting to go on when the editor sends garbage, it is
+	// ended before `Serve` returns
+	fmt.Fprintf(c.out, "Content-Length: 5\r\n\r\n{nope")
+	c.expect("event", "terminated", nil)
+
+	if err := <-c.errc; err == nil || !strings.Contains(err.Error(), "bad message") {
+		t.Errorf("Serve returned %v, want a bad message error", err)
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		err      string
+	}{
+		{"Content-Length: 2\r\n\r\n{}", "{}", ""},
+		{"content-length: 2\r\nContent-Type: x\r\n\r\n{}", "{}", ""},
+		{"\r\n{}", "", "message without a Content-Length header"},
+		{"Content-Length: x\r\n\r\n", "", `bad Content-Length " x"`},
+		{"Content-Length: -1\r\n\r\n", "", `bad Content-Length " -1"`},
+		{
+			fmt.Sprintf("Content-Length: %d\r\n\r\n", maxMessageSize+1), "",
+			fmt.Sprintf("message of %d bytes is larger than the limit of %d", maxMessageSize+1, maxMessageSize),
+		},
+		{"Content-Length: 5\r\n\r\n{}", "", "unexpected EOF"},
+	}
+
+	for _, tt := range tests {
+		data, err := readMessage(bufio.NewReader(strings.NewReader(tt.input)))
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%q: got error %v, want %q", tt.input, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %s", tt.input, err)
+			continue
+		}
+		if string(data) != tt.expected {
+			t.Errorf("%q: got %q, want %q", tt.input, data, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package debugger
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// commands are what the console understands while the program is stopped
+type command struct {
+	name  string
+	short string
+	args  string
+	help  string
+	run   func(c *console, arg string)
+}
+
+var commands []command
+
+func init() {
+	// assigned in init because `help` refers back to `commands`
+	commands = []command{
+		{"break", "b", "[line]", "stop before the statements on a line, or list breakpoints", (*console).setBreakpoint},
+		{"clear", "", "<line>", "remove the breakpoint on a line", (*console).clearBreakpoint},
+		{"step", "s", "", "run to the next statement, going in to calls", (*console).step},
+		{"next", "n", "", "run to the next statement of this function, over calls", (*console).next},
+		{"out", "o", "", "run until the current function returns", (*console).stepOut},
+		{"continue", "c", "", "run until a breakpoint is hit", (*console).resumeProgram},
+		{"print", "p", "<expr>", "evaluate expr where the program stopped", (*console).print},
+		{"env", "e", "", "list the bindings of every environment, innermost first", (*console).env},
+		{"backtrace", "bt", "", "list the calls that led here", (*console).backtrace},
+		{"list", "l", "", "show the source around the current line", (*console).list},
+		{"quit", "q", "", "stop the program", (*console).quit},
+		{"help", "h", "", "show this help", (*console).help},
+	}
+}
+
+func (c *console) runCommand(line string) {
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+
+	for _, cmd := range commands {
+		if cmd.name == name || (cmd.short != "" && cmd.short == name) {
+			cmd.run(c, arg)
+			return
+		}
+	}
+
+	fmt.Fprintf(c.out, "unknown command %s, try help\n", name)
+}
+
+func (c *console) parseLine(arg string) (int, bool) {
+	line, err := strconv.Atoi(arg)
+	if err != nil || line < 1 {
+		fmt.Fprintf(c.out, "not a line number: %q\n", arg)
+		return 0, false
+	}
+	return line, true
+}
+
+func (c *console) setBreakpoint(arg string) {
+	if arg == "" {
+		lines := c.d.Breakpoints()
+		if len(lines) == 0 {
+			fmt.Fprintln(c.out, "no breakpoints")
+		}
+		for _, line := range lines {
+			fmt.Fprintf(c.out, "breakpoint at %s:%d\n", c.name, line)
+		}
+		return
+	}
+
+	line, ok := c.parseLine(arg)
+	if !ok {
+		return
+	}
+	if !c.d.SetBreakpoint(line) {
+		fmt.Fprintf(c.out, "no statement starts on line %d\n", line)
+		return
+	}
+
+	fmt.Fprintf(c.out, "breakpoint at %s:%d\n", c.name, line)
+}
+
+func (c *console) clearBreakpoint(arg string) {
+	line, ok := c.parseLine(arg)
+	if !ok {
+		return
+	}
+	if !c.d.ClearBreakpoint(line) {
+		fmt.Fprintf(c.out, "no breakpoint on line %d\n", line)
+	}
+}
+
+func (c *console) step(string) {
+	c.d.StepIn()
+	c.resume = true
+}
+
+func (c *console) next(string) {
+	c.d.StepOver()
+	c.resume = true
+}
+
+func (c *console) stepOut(string) {
+	if !c.d.StepOut() {
+		fmt.Fprintln(c.out, "not in a function")
+		return
+	}
+	c.resume = true
+}
+
+func (c *console) resumeProgram(string) {
+	c.d.Continue()
+	c.resume = true
+}
+
+func (c *console) print(src string) {
+	if src == "" {
+		fmt.Fprintln(c.out, "usage: print <expr>")
+		return
+	}
+
+	result, err := c.d.Eval(src, 0)
+	if err != nil {
+		fmt.Fprintln(c.out, err)
+		return
+	}
+	if result != nil {
+		fmt.Fprintln(c.out, summary(result))
+	}
+}
+
+func (c *console) env(string) {
+	top := c.d.Frames()[0].Env
+
+	for env := top; env != nil; env = env.Outer() {
+		switch {
+		case env.Outer() == nil:
+			fmt.Fprintln(c.out, "globals:")
+		case env == top:
+			fmt.Fprintln(c.out, "locals:")
+		default:
+			fmt.Fprintln(c.out, "outer:")
+		}
+
+		for _, name := range env.Names() {
+			val, _ := env.Get(name)
+			fmt.Fprintf(c.out, "  %s = %s\n", name, summary(val))
+		}
+	}
+}
+
+func (c *console) backtrace(string) {
+	for i, f := range c.d.Frames() {
+		fmt.Fprintf(c.out, "#%d %s at %s:%d\n", i, f.Name, c.name, f.Pos.Line)
+	}
+}
+
+func (c *console) list(string) {
+	if c.lines == nil {
+		fmt.Fprintln(c.out, "no source for", c.name)
+		return
+	}
+
+	current := c.d.Frames()[0].Pos.Line
+	from, to := current-5, current+5
+	if from < 1 {
+		from = 1
+	}
+	if to > len(c.lines) {
+		to = len(c.lines)
+	}
+
+	breakpoints := map[int]bool{}
+	for _, line := range c.d.Breakpoints() {
+		breakpoints[line] = true
+	}
+
+	for line := from; line <= to; line++ {
+		marker := ""
+		switch {
+		case line == current:
+			marker = "=>"
+		case breakpoints[line]:
+			marker = "*"
+		}
+		c.printLine(line, marker)
+	}
+}
+
+func (c *console) quit(string) {
+	c.d.Quit()
+	c.resume = true
+}
+
+func (c *console) help(string) {
+	for _, cmd := range commands {
+		name := cmd.name
+		if cmd.short != "" {
+			name += ", " + cmd.short
+		}
+		fmt.Fprintf(c.out, "%-14s %-8s %s\n", name, cmd.args, cmd.help)
+	}
+	fmt.Fprintln(c.out, "an empty line repeats the last command")
+}
@@ -0,0 +1,152 @@
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/object"
+	"monkey-lang.z9fr.xyz/internal/readline"
+)
+
+const PROMPT = "(mdb) "
+
+// console is the frontend of `monkey debug`, it reads commands from a terminal
+// while the program is stopped. see `help` for the list
+type console struct {
+	d     *Debugger
+	name  string
+	lines []string
+	in    lineReader
+	out   io.Writer
+
+	// last command, an empty line repeats it
+	last string
+	// set by the command that lets the program go on
+	resume bool
+}
+
+// NewConsole returns a debugger for `program`, read from the file `name`,
+// that takes commands from `in`, with line editing when it is a terminal. it
+// stops before the first statement. `src` is the source of the program, it is
+// only used to show lines and may be empty
+func NewConsole(name, src string, program *ast.Program, in io.Reader, out io.Writer) *Debugger {
+	c := &console{
+		name: name,
+		in:   newLineReader(in, out),
+		out:  out,
+	}
+	if src != "" {
+		c.lines = strings.Split(strings.TrimSuffix(src, "\n"), "\n")
+	}
+
+	c.d = New(program, c)
+	c.d.StopOnEntry = true
+	return c.d
+}
+
+// Stopped shows where the program is and reads commands until one of them
+// lets it go on. when the commands run out the program quits
+func (c *console) Stopped(d *Debugger, reason string) {
+	if name, val := d.ReturnValue(); val != nil {
+		fmt.Fprintf(c.out, "%s returned %s\n", name, summary(val))
+	}
+
+	top := d.Frames()[0]
+	fmt.Fprintf(c.out, "%s:%d in %s\n", c.name, top.Pos.Line, top.Name)
+	c.printLine(top.Pos.Line, "")
+
+	for c.resume = false; !c.resume; {
+		input, err := c.in.ReadLine(PROMPT)
+		if err == readline.ErrInterrupted {
+			continue
+		}
+		if err != nil {
+			fmt.Fprintln(c.out)
+			d.Quit()
+			return
+		}
+
+		input = strings.TrimSpace(input)
+		if input == "" {
+			input = c.last
+		}
+		if input == "" {
+			continue
+		}
+		c.last = input
+
+		c.runCommand(input)
+	}
+}
+
+func (c *console) printLine(line int, marker string) {
+	if line < 1 || line > len(c.lines) {
+		return
+	}
+	fmt.Fprintf(c.out, "%2s%4d\t%s\n", marker, line, c.lines[line-1])
+}
+
+// summary is a one line description of `obj`, functions are shown without
+// their body. a name bound to nothing, like an empty block, shows as null
+func summary(obj object.Object) string {
+	switch obj := obj.(type) {
+	case nil:
+		return "null"
+	case *object.Function:
+		params := make([]string, len(obj.Parameters))
+		for i, p := range obj.Parameters {
+			params[i] = p.String()
+		}
+		return "fn(" + strings.Join(params, ", ") + ")"
+	}
+	return obj.Inspect()
+}
+
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+// newLineReader uses the line editor when `in` is a terminal, and plain line
+// reading otherwise
+func newLineReader(in io.Reader, out io.Writer) lineReader {
+	if f, ok := in.(*os.File); ok {
+		if editor, err := readline.NewTerminal(f, out); err == nil {
+			return &editorReader{editor}
+		}
+	}
+	return &scannerReader{scanner: bufio.NewScanner(in), out: out}
+}
+
+type editorReader struct {
+	editor *readline.Editor
+}
+
+func (r *editorReader) ReadLine(prompt string) (string, error) {
+	line, err := r.editor.ReadLine(prompt)
+	if err == nil {
+		r.editor.AddHistory(line)
+	}
+	return line, err
+}
+
+type scannerReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (r *scannerReader) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(r.out, prompt)
+
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+
+	return r.scanner.Text(), nil
+}
@@ -0,0 +1,355 @@
+package debugger
+
+/*
+debugger
+
+stops a program while the tree walking evaluator runs it. the debugger puts
+itself in to the top level environment as its `object.Hooks`, and before every
+statement decides whether to stop there: because a breakpoint is on its line,
+or because a step is done.
+
+what happens while the program is stopped is up to a `Frontend`. the console
+one reads commands from a terminal, `monkey dap` answers an editor.
+
+statements are found by their source positions, so the program has to come
+from source or from a binary program built with positions
+*/
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/evaluator"
+	"monkey-lang.z9fr.xyz/internal/lexer"
+	"monkey-lang.z9fr.xyz/internal/object"
+	"monkey-lang.z9fr.xyz/internal/parser"
+	"monkey-lang.z9fr.xyz/internal/resolver"
+	"monkey-lang.z9fr.xyz/internal/token"
+)
+
+// ErrQuit is returned by `Run` when the program was stopped with `Quit`
+var ErrQuit = errors.New("debugger quit")
+
+// Frontend is what the debugger hands control to when the program stops
+type Frontend interface {
+	// Stopped is called in the goroutine running the program each time it
+	// stops, `reason` is "entry", "breakpoint" or "step". the program goes on
+	// once it returns, the way the last call to `StepIn`, `StepOver`,
+	// `StepOut`, `Continue` or `Quit` said
+	Stopped(d *Debugger, reason string)
+}
+
+type mode int
+
+const (
+	// run until a breakpoint
+	running mode = iota
+	// stop at the next statement, wherever it is
+	stepInto
+	// stop at the next statement that is not in a function called from here
+	stepOver
+	// stop at the next statement once the current function returned
+	stepOut
+)
+
+type Debugger struct {
+	program  *ast.Program
+	frontend Frontend
+
+	// StopOnEntry stops the program before its first statement
+	StopOnEntry bool
+
+	// breakpoints may be set from another goroutine while the program runs
+	mu          sync.Mutex
+	breakpoints map[int]bool
+	// lines statements start on, a breakpoint anywhere else is never hit
+	statementLines map[int]bool
+
+	mode mode
+	// set until the first statement when `StopOnEntry` is
+	entry bool
+	// how many frames there were when the current step started
+	stepDepth int
+
+	// the calls being run, innermost last. the first one is the program
+	frames []*Frame
+
+	// where the program stopped last. a breakpoint stops once for a line in a
+	// frame, not once for every statement on it
+	stopLine int
+	stopEnv  *object.Environment
+
+	// the function that returned at the end of a `StepOut`, and its value
+	returnName  string
+	returnValue object.Object
+
+	// set while `Eval` runs, the program must not stop inside of it
+	evaluating bool
+
+	quitting atomic.Bool
+}
+
+var _ object.Hooks = (*Debugger)(nil)
+
+// Frame is a call the program is in the middle of
+type Frame struct {
+	// Name is the function as it was written at the call
+	Name string
+	// Env is the environment of the statement the frame is at
+	Env *object.Environment
+	// Pos is the start of that statement
+	Pos token.Position
+}
+
+// New returns a debugger for `program`, which should have been through macro
+// expansion and `resolver.Annotate` like any program about to be evaluated
+func New(program *ast.Program, frontend Frontend) *Debugger {
+	d := &Debugger{
+		frontend:       frontend,
+		breakpoints:    map[int]bool{},
+		statementLines: map[int]bool{},
+	}
+
+	ast.Inspect(program, func(n ast.Node) bool {
+		if stmt, ok := n.(ast.Statement); ok {
+			if _, block := stmt.(*ast.BlockStatement); !block {
+				d.statementLines[ast.Pos(stmt).Line] = true
+			}
+		}
+		return true
+	})
+
+	d.program = program
+	return d
+}
+
+// quit unwinds the evaluator when the user quits, `Run` recovers it
+type quit struct{}
+
+// Run evaluates the program in `env`. it returns the value of the program like
+// `evaluator.Eval`, or `ErrQuit`
+func (d *Debugger) Run(env *object.Environment) (result object.Object, err error) {
+	d.frames = []*Frame{{Name: "<program>", Env: env}}
+	d.mode, d.entry = running, d.StopOnEntry
+
+	env.SetHooks(d)
+	defer env.SetHooks(nil)
+
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(quit); !ok {
+				panic(r)
+			}
+			result, err = nil, ErrQuit
+		}
+	}()
+
+	return evaluator.Eval(d.program, env), nil
+}
+
+// SetBreakpoint stops the program before the statements on `line`. it reports
+// false, and does nothing, when no statement starts there
+func (d *Debugger) SetBreakpoint(line int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.statementLines[line] {
+		return false
+	}
+	d.breakpoints[line] = true
+	return true
+}
+
+// ClearBreakpoint removes the breakpoint on `line`, it reports whether there
+// was one
+func (d *Debugger) ClearBreakpoint(line int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ok := d.breakpoints[line]
+	delete(d.breakpoints, line)
+	return ok
+}
+
+// ClearBreakpoints removes every breakpoint
+func (d *Debugger) ClearBreakpoints() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.breakpoints = map[int]bool{}
+}
+
+// Breakpoints returns the lines with a breakpoint, sorted
+func (d *Debugger) Breakpoints() []int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	lines := make([]int, 0, len(d.breakpoints))
+	for line := range d.breakpoints {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+func (d *Debugger) hasBreakpoint(line int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.breakpoints[line]
+}
+
+// the rest is only for while the program is stopped, from the frontend
+
+// Frames returns the calls the program is in, innermost first. the last one
+// is the program itself
+func (d *Debugger) Frames() []Frame {
+	frames := make([]Frame, len(d.frames))
+	for i, f := range d.frames {
+		frames[len(d.frames)-1-i] = *f
+	}
+	return frames
+}
+
+// StepIn stops at the next statement, also when it is in a function called
+// from here
+func (d *Debugger) StepIn() {
+	d.mode = stepInto
+}
+
+// StepOver stops at the next statement of this function, or of its caller once
+// it returns
+func (d *Debugger) StepOver() {
+	d.mode, d.stepDepth = stepOver, len(d.frames)
+}
+
+// StepOut stops at the next statement after the current function returned. it
+// reports false when the program is not in a function
+func (d *Debugger) StepOut() bool {
+	if len(d.frames) == 1 {
+		return false
+	}
+
+	d.mode, d.stepDepth = stepOut, len(d.frames)
+	return true
+}
+
+// ReturnValue is the function a `StepOut` left and the value it returned. the
+// value is nil when the program did not stop because of a `StepOut`
+func (d *Debugger) ReturnValue() (name string, val object.Object) {
+	return d.returnName, d.returnValue
+}
+
+// Continue runs until a breakpoint is hit
+func (d *Debugger) Continue() {
+	d.mode = running
+}
+
+// Quit ends the program, `Run` returns `ErrQuit`. it may be called from any
+// goroutine: a running program quits before its next statement
+func (d *Debugger) Quit() {
+	d.quitting.Store(true)
+}
+
+// Eval evaluates `src` in the environment of frame `frame`, counted like
+// `Frames`. the program does not stop in functions it calls. the error is for
+// source that does not parse, runtime errors are an `*object.Error` result
+func (d *Debugger) Eval(src string, frame int) (object.Object, error) {
+	if frame < 0 || frame >= len(d.frames) {
+		return nil, errors.New("no such frame")
+	}
+
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, errors.New(strings.Join(p.Errors(), "\n"))
+	}
+	resolver.Annotate(program)
+
+	d.evaluating = true
+	defer func() { d.evaluating = false }()
+
+	return evaluator.Eval(program, d.frames[len(d.frames)-1-frame].Env), nil
+}
+
+func (d *Debugger) top() *Frame {
+	return d.frames[len(d.frames)-1]
+}
+
+func (d *Debugger) BeforeStatement(stmt ast.Statement, env *object.Environment) {
+	if d.evaluating {
+		return
+	}
+	if d.quitting.Load() {
+		panic(quit{})
+	}
+
+	pos := ast.Pos(stmt)
+	top := d.top()
+	top.Env, top.Pos = env, pos
+
+	if reason := d.stopReason(pos.Line, env); reason != "" {
+		d.stopLine, d.stopEnv = pos.Line, env
+		d.frontend.Stopped(d, reason)
+		d.returnName, d.returnValue = "", nil
+
+		if d.quitting.Load() {
+			panic(quit{})
+		}
+	}
+}
+
+func (d *Debugger) AfterStatement(stmt ast.Statement, env *object.Environment) {}
+
+func (d *Debugger) Branch(ie *ast.IfExpression, consequence bool) {}
+
+func (d *Debugger) BeforeCall(call *ast.CallExpression, fn object.Object, args []object.Object, tail bool) {
+	if d.evaluating {
+		return
+	}
+
+	// a tail call takes the place of the one that made it, there is no
+	// frame to come back to
+	if tail {
+		d.top().Name = call.Function.String()
+		return
+	}
+	d.frames = append(d.frames, &Frame{Name: call.Function.String()})
+}
+
+func (d *Debugger) AfterCall(call *ast.CallExpression, result object.Object) {
+	if d.evaluating {
+		return
+	}
+
+	// the function being stepped out of returned, what is left is a step
+	// over in its caller
+	if d.mode == stepOut && len(d.frames) == d.stepDepth {
+		d.returnName, d.returnValue = d.top().Name, result
+		d.mode, d.stepDepth = stepOver, len(d.frames)-1
+	}
+	d.frames = d.frames[:len(d.frames)-1]
+}
+
+// stopReason tells why the program stops before a statement on `line`, or ""
+// when it goes on
+func (d *Debugger) stopReason(line int, env *object.Environment) string {
+	switch {
+	case d.entry:
+		d.entry = false
+		return "entry"
+	case d.mode == stepInto:
+		return "step"
+	case d.mode == stepOver && len(d.frames) <= d.stepDepth:
+		return "step"
+	}
+
+	if d.hasBreakpoint(line) && (line != d.stopLine || env != d.stopEnv) {
+		return "breakpoint"
+	}
+	return ""
+}
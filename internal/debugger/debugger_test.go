@@ -0,0 +1,168 @@
+package debugger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"monkey-lang.z9fr.xyz/internal/lexer"
+	"monkey-lang.z9fr.xyz/internal/object"
+	"monkey-lang.z9fr.xyz/internal/parser"
+	"monkey-lang.z9fr.xyz/internal/resolver"
+)
+
+const program = `let add = fn(a, b) {
+  let sum = a + b;
+  sum
+};
+let x = 1;
+let y = add(x, 2);
+y * 2;
+`
+
+// debug runs `src` under the debugger with `commands` typed in, one per line
+func debug(t *testing.T, src string, commands ...string) (object.Object, error, string) {
+	t.Helper()
+
+	p := parser.New(lexer.New(src))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	resolver.Annotate(prog)
+
+	var out bytes.Buffer
+	in := strings.NewReader(strings.Join(commands, "\n") + "\n")
+	d := NewConsole("test.mk", src, prog, in, &out)
+
+	result, err := d.Run(object.NewEnvironment())
+	return result, err, out.String()
+}
+
+func TestCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands []string
+		expected []string
+	}{
+		{
+			"stops at the first statement",
+			[]string{"continue"},
+			[]string{"test.mk:1 in <program>\n     1\tlet add = fn(a, b) {\n"},
+		},
+		{
+			"breakpoint in a function",
+			[]string{"break 2", "c", "print a + b", "backtrace", "c"},
+			[]string{
+				"breakpoint at test.mk:2",
+				"test.mk:2 in add\n",
+				"(mdb) 3\n",
+				"#0 add at test.mk:2\n#1 <program> at test.mk:6\n",
+			},
+		},
+		{
+			"env lists every scope",
+			[]string{"b 3", "c", "env", "c"},
+			[]string{"locals:\n  a = 1\n  b = 2\n  sum = 3\nglobals:\n  add = fn(a, b)\n  x = 1\n"},
+		},
+		{
+			"next steps over calls",
+			[]string{"n", "n", "n", "print y", "c"},
+			[]string{"test.mk:6 in <program>", "test.mk:7 in <program>", "(mdb) 3\n"},
+		},
+		{
+			"step goes in to calls",
+			[]string{"n", "n", "s", "s", "out", "c"},
+			[]string{"test.mk:2 in add", "test.mk:3 in add", "add returned 3\ntest.mk:7 in <program>"},
+		},
+		{
+			"an empty line repeats the last command",
+			[]string{"n", "", "", "print x", "c"},
+			[]string{"test.mk:6 in <program>", "(mdb) 1\n"},
+		},
+		{
+			"bad commands",
+			[]string{"jump", "break 4", "break x", "clear 2", "out", "print let", "c"},
+			[]string{
+				"unknown command jump, try help",
+				"no statement starts on line 4",
+				`not a line number: "x"`,
+				"no breakpoint on line 2",
+				"not in a function",
+				"expected next token to be IDENT",
+			},
+		},
+		{
+			"list marks the line and breakpoints",
+			[]string{"b 5", "list", "c", "c"},
+			[]string{"=>   1\tlet add = fn(a, b) {\n     2\t  let sum = a + b;\n", " *   5\tlet x = 1;\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err, out := debug(t, program, tt.commands...)
+			if err != nil {
+				t.Fatalf("Run failed: %s\n%s", err, out)
+			}
+			if result.Inspect() != "6" {
+				t.Errorf("result is %s, want 6", result.Inspect())
+			}
+
+			for _, want := range tt.expected {
+				if !strings.Contains(out, want) {
+					t.Errorf("output does not contain %q\n%s", want, out)
+				}
+			}
+		})
+	}
+}
+
+func TestEnvWithEmptyValues(t *testing.T) {
+	src := "let f = fn() { };\nlet x = f();\nlet y = if (true) { };\n1;\n"
+	_, err, out := debug(t, src, "b 4", "c", "env", "c")
+	if err != nil {
+		t.Fatalf("Run failed: %s\n%s", err, out)
+	}
+
+	// an empty block leaves nothing in `y`, it is shown like null
+	if want := "  f = fn()\n  x = null\n  y = null\n"; !strings.Contains(out, want) {
+		t.Errorf("output does not contain %q\n%s", want, out)
+	}
+}
+
+func TestQuit(t *testing.T) {
+	for _, commands := range [][]string{{"next", "quit"}, {"next"}} {
+		_, err, out := debug(t, program, commands...)
+		if err != ErrQuit {
+			t.Errorf("%v: Run returned %v, want ErrQuit\n%s", commands, err, out)
+		}
+	}
+}
+
+func TestTailCallsKeepOneFrame(t *testing.T) {
+	src := `let loop = fn(n) {
+  if (n == 0) { 0 } else { loop(n - 1) }
+};
+loop(3);
+`
+	_, _, out := debug(t, src, "b 2", "c", "c", "c", "c", "bt", "c")
+
+	if strings.Count(out, "test.mk:2 in loop") != 4 {
+		t.Errorf("expected to stop 4 times on line 2\n%s", out)
+	}
+	if !strings.Contains(out, "#0 loop at test.mk:2\n#1 <program> at test.mk:4\n(mdb)") {
+		t.Errorf("tail calls grew the stack\n%s", out)
+	}
+}
+
+func TestPrintDoesNotStop(t *testing.T) {
+	_, _, out := debug(t, program, "b 2", "b 5", "c", "print add(5, 5)", "clear 2", "c")
+
+	if strings.Contains(out, "in add") {
+		t.Errorf("stopped inside of print\n%s", out)
+	}
+	if !strings.Contains(out, "(mdb) 10\n") {
+		t.Errorf("print gave the wrong value\n%s", out)
+	}
+}
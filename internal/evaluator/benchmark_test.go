@@ -0,0 +1,62 @@
+package evaluator
+
+import (
+	"testing"
+
+	"monkey-lang.z9fr.xyz/internal/lexer"
+	"monkey-lang.z9fr.xyz/internal/object"
+	"monkey-lang.z9fr.xyz/internal/parser"
+	"monkey-lang.z9fr.xyz/internal/resolver"
+)
+
+// each benchmark runs twice: "map" is the program as parsed, which the
+// evaluator runs with a map per call, "slots" is after `resolver.Annotate`
+func benchmarkEval(b *testing.B, input string) {
+	for _, mode := range []string{"map", "slots"} {
+		b.Run(mode, func(b *testing.B) {
+			program := parser.New(lexer.New(input)).ParseProgram()
+			if mode == "slots" {
+				resolver.Annotate(program)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if result := Eval(program, object.NewEnvironment()); isError(result) {
+					b.Fatal(result.Inspect())
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkFibonacci(b *testing.B) {
+	benchmarkEval(b, `
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+fib(20);
+`)
+}
+
+func BenchmarkClosures(b *testing.B) {
+	benchmarkEval(b, `
+let compose = fn(f, g) { fn(x) { let y = g(x); f(y) } };
+let inc = fn(x) { x + 1 };
+let double = fn(x) { x * 2 };
+let h = compose(inc, double);
+let loop = fn(n, acc) { if (n == 0) { acc } else { loop(n - 1, h(acc) - acc) } };
+loop(500, 1);
+`)
+}
+
+func BenchmarkAnnotate(b *testing.B) {
+	program := parser.New(lexer.New(`
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+let newAdder = fn(x) { fn(y) { fn(z) { x + y + z } } };
+fib(newAdder(1)(2)(3));
+`)).ParseProgram()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		resolver.Annotate(program)
+	}
+}
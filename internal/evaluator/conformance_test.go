@@ -0,0 +1,34 @@
+package evaluator_test
+
+import (
+	"testing"
+
+	"monkey-lang.z9fr.xyz/internal/conformance"
+	"monkey-lang.z9fr.xyz/internal/evaluator"
+	"monkey-lang.z9fr.xyz/internal/lexer"
+	"monkey-lang.z9fr.xyz/internal/object"
+	"monkey-lang.z9fr.xyz/internal/parser"
+	"monkey-lang.z9fr.xyz/internal/resolver"
+)
+
+// the evaluator looks locals up by name in programs that were not annotated,
+// both ways have to pass
+func TestConformance(t *testing.T) {
+	cases, err := conformance.Cases()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range cases {
+		program := parser.New(lexer.New(c.Input)).ParseProgram()
+		if err := c.Check(evaluator.Eval(program, object.NewEnvironment())); err != nil {
+			t.Errorf("maps: %s", err)
+		}
+
+		program = parser.New(lexer.New(c.Input)).ParseProgram()
+		resolver.Annotate(program)
+		if err := c.Check(evaluator.Eval(program, object.NewEnvironment())); err != nil {
+			t.Errorf("slots: %s", err)
+		}
+	}
+}
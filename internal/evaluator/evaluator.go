@@ -23,6 +23,8 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return Eval(node.Expression, env)
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+	case *ast.StringLiteral:
+		return &object.String{Value: node.Value}
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 	case *ast.PrefixExpression:
@@ -50,7 +52,11 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+		if node.Name.Local {
+			env.SetSlot(node.Name.Slot, val)
+		} else {
+			env.Set(node.Name.Value, val)
+		}
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 
@@ -67,8 +73,16 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{Parameters: params, Env: env, Body: body, Locals: node.Locals}
 	case *ast.CallExpression:
+		// `quote` is not a function, its argument must not be evaluated
+		if node.Function.TokenLiteral() == "quote" {
+			if len(node.Arguments) != 1 {
+				return newError("wrong number of arguments to quote: want 1, got %d", len(node.Arguments))
+			}
+			return quote(node.Arguments[0], env)
+		}
+
 		// we are just using eval to get function we want to call. whether that's a
 		// `ast.Identifier` or an `*ast.FunctionLiteral`
 		// Eval returns `*object.Function`
@@ -81,27 +95,72 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return args[0]
 		}
 
-		return applyFunction(function, args)
+		hooks := env.Hooks()
+		if hooks == nil {
+			return applyFunction(node, function, args)
+		}
+
+		hooks.BeforeCall(node, function, args, false)
+		result := applyFunction(node, function, args)
+		hooks.AfterCall(node, result)
+		return result
+	case *ast.FieldExpression:
+		obj := Eval(node.Object, env)
+		if isError(obj) {
+			return obj
+		}
+		return EvalFieldExpression(obj, node.Field.Value)
 	}
 	return nil
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
-	// we check if we have `object.Function` at hand and convert as well.
-	// we do this in order to get access to function's .Env and .Body fields
-	function, ok := fn.(*object.Function)
-
-	if !ok {
-		return newError("not a function: %s", fn.Type())
-	}
+func applyFunction(call *ast.CallExpression, fn object.Object, args []object.Object) object.Object {
+	// calls in tail position come back as a `tailCall`, we make them here in
+	// a loop instead of recursing
+	for {
+		// we check if we have `object.Function` at hand and convert as well.
+		// we do this in order to get access to function's .Env and .Body fields
+		switch function := fn.(type) {
+		case *object.Function:
+			if len(function.Parameters) != len(args) {
+				return newError("wrong number of arguments: want %d, got %d",
+					len(function.Parameters), len(args))
+			}
 
-	extendedEnv := extendFunctionEnv(function, args)
-	evaluated := Eval(function.Body, extendedEnv)
+			extendedEnv := extendFunctionEnv(function, args)
+			evaluated := unwrapReturnValue(evalTailBlock(function.Body, extendedEnv, true))
 
-	return unwrapReturnValue(evaluated)
+			if tc, ok := evaluated.(*tailCall); ok {
+				call, fn, args = tc.call, tc.fn, tc.args
+				if hooks := extendedEnv.Hooks(); hooks != nil {
+					hooks.BeforeCall(call, fn, args, true)
+				}
+				continue
+			}
+			if evaluated == nil {
+				// a body without an expression to end on, like the vm does
+				return NULL
+			}
+			return evaluated
+		case *object.GoFunction:
+			return ApplyGoFunction(function, args)
+		default:
+			return newError("not a function: %s", fn.Type())
+		}
+	}
 }
 
 func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+	// functions that went through `resolver.Annotate` get a frame with a slot
+	// for each local, their parameters know which slot is theirs
+	if len(fn.Locals) != 0 {
+		env := object.NewFrame(fn.Env, fn.Locals)
+		for paramIdx, param := range fn.Parameters {
+			env.SetSlot(param.Slot, args[paramIdx])
+		}
+		return env
+	}
+
 	// creates a new `*object.Environment` that enclosed by the function's environment.
 	env := object.NewEnclosedEnvironment(fn.Env)
 
@@ -136,6 +195,14 @@ func evalExpression(exps []ast.Expression, env *object.Environment) []object.Obj
 }
 
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+	if node.Local {
+		if val, ok := env.Slot(node.Depth, node.Slot); ok {
+			return val
+		}
+		// the slot is not bound yet, like a `let` in an `if` that did not run.
+		// the name may still be bound further out
+	}
+
 	val, ok := env.Get(node.Value)
 
 	if !ok {
@@ -149,7 +216,7 @@ func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range stmts {
-		result = Eval(statement, env)
+		result = evalStatement(statement, env)
 
 		// in case the last eval result is a `object.ReturnValue` if so we stop the
 		// evaluation and return the unwrapped value. we dont need to return an `object.ReturlValue`
@@ -166,11 +233,25 @@ func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 	return result
 }
 
+// evalStatement runs one statement of a program or block, between the hooks of
+// `env` when it has any
+func evalStatement(stmt ast.Statement, env *object.Environment) object.Object {
+	hooks := env.Hooks()
+	if hooks == nil {
+		return Eval(stmt, env)
+	}
+
+	hooks.BeforeStatement(stmt, env)
+	result := Eval(stmt, env)
+	hooks.AfterStatement(stmt, env)
+	return result
+}
+
 func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range block.Statements {
-		result = Eval(statement, env)
+		result = evalStatement(statement, env)
 
 		// when we have block statements we cant unwrap the result in the first sight, because
 		// we need to furthure keep track of its so we can stop execution in outermost block
@@ -199,6 +280,10 @@ func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Obje
 		return condition
 	}
 
+	if hooks := env.Hooks(); hooks != nil {
+		hooks.Branch(ie, isTruthy(condition))
+	}
+
 	if isTruthy(condition) {
 		return Eval(ie.Consequence, env)
 	} else if ie.Alternative != nil {
@@ -228,6 +313,8 @@ func evalInfixExpression(
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return evalStringInfixExpression(operator, left, right)
 	// monkey boolean operands support
 	case operator == "==":
 		return nativeBoolToBooleanObject(left == right)
@@ -257,6 +344,9 @@ func evalIntegerInfixExpression(
 	case "*":
 		return &object.Integer{Value: leftValue * rightValue}
 	case "/":
+		if rightValue == 0 {
+			return newError("division by zero")
+		}
 		return &object.Integer{Value: leftValue / rightValue}
 	case "<":
 		return nativeBoolToBooleanObject(leftValue < rightValue)
@@ -272,6 +362,26 @@ func evalIntegerInfixExpression(
 	}
 }
 
+func evalStringInfixExpression(
+	operator string,
+	left, right object.Object,
+) object.Object {
+	leftValue := left.(*object.String).Value
+	rightValue := right.(*object.String).Value
+
+	switch operator {
+	case "+":
+		return &object.String{Value: leftValue + rightValue}
+	case "==":
+		return nativeBoolToBooleanObject(leftValue == rightValue)
+	case "!=":
+		return nativeBoolToBooleanObject(leftValue != rightValue)
+	default:
+		return newError("unknown operator: %s %s %s",
+			left.Type(), operator, right.Type())
+	}
+}
+
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	if input {
 		return TRUE
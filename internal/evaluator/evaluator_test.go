@@ -6,42 +6,13 @@ import (
 	"monkey-lang.z9fr.xyz/internal/lexer"
 	"monkey-lang.z9fr.xyz/internal/object"
 	"monkey-lang.z9fr.xyz/internal/parser"
+	"monkey-lang.z9fr.xyz/internal/resolver"
 )
 
-func TestClosures(t *testing.T) {
-	input := `
-let newAdder = fn(x) {
-  fn(y) { x + y };
-};
-
-let addTwo = newAdder(2);
-addTwo(2);`
-
-	testIntegerObject(t, testEval(input), 4)
-}
-
-func TestFunctionApplication(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected int64
-	}{
-		{"let identity = fn(x) { x; }; identity(5);", 5},
-		{"let identity = fn(x) { return x; }; identity(5);", 5},
-		{"let double = fn(x) { x * 2; }; double(5);", 10},
-		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
-		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
-		{"fn(x) { x; }(5)", 5},
-	}
-
-	for _, tt := range tests {
-		testIntegerObject(t, testEval(tt.input), tt.expected)
-	}
-}
-
 func TestFunctionObject(t *testing.T) {
 	input := "fn(x) { x + 2; };"
 
-	evaluated := testEval(input)
+	evaluated := testEval(t, input)
 	fn, ok := evaluated.(*object.Function)
 	if !ok {
 		t.Fatalf("object is not Function. got=%T (%+v)", evaluated, evaluated)
@@ -63,230 +34,75 @@ func TestFunctionObject(t *testing.T) {
 	}
 }
 
-func TestLetStatements(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected int64
-	}{
-		{"let a = 5; a;", 5},
-		{"let a = 5 * 5; a;", 25},
-		{"let a = 5; let b = a; b;", 5},
-		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
-	}
-
-	for _, tt := range tests {
-		testIntegerObject(t, testEval(tt.input), tt.expected)
-	}
-}
-
-func TestErrorHandling(t *testing.T) {
-	tests := []struct {
-		input           string
-		expectedMessage string
-	}{
-		{
-			"5 + true;",
-			"type mismatch: INTEGER + BOOLEAN",
-		},
-		{
-			"5 + true; 5;",
-			"type mismatch: INTEGER + BOOLEAN",
-		},
-		{
-			"-true",
-			"unknown operator: -BOOLEAN",
-		},
-		{
-			"true + false;",
-			"unknown operator: BOOLEAN + BOOLEAN",
-		},
-		{
-			"5; true + false; 5",
-			"unknown operator: BOOLEAN + BOOLEAN",
-		},
-		{
-			"if (10 > 1) { true + false; }",
-			"unknown operator: BOOLEAN + BOOLEAN",
-		},
-		{
-			`
-if (10 > 1) {
-  if (10 > 1) {
-	return true + false;
-  }
-
-  return 1;
-}
-`,
-			"unknown operator: BOOLEAN + BOOLEAN",
-		},
-		{
-			"foobar",
-			"identifier not found: foobar",
-		},
+func TestDivisionByZero(t *testing.T) {
+	tests := []string{
+		"5 / 0",
+		"let zero = 0; 10 / zero;",
+		"let f = fn(x) { 1 / x }; f(0);",
 	}
 
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-
+	for _, input := range tests {
+		evaluated := testEval(t, input)
 		errObj, ok := evaluated.(*object.Error)
 		if !ok {
-			t.Errorf("no error object returned. got=%T(%+v)",
-				evaluated, evaluated)
+			t.Errorf("%q: no error object returned. got=%T(%+v)", input, evaluated, evaluated)
 			continue
 		}
-
-		if errObj.Message != tt.expectedMessage {
-			t.Errorf("wrong error message. expected=%q, got=%q",
-				tt.expectedMessage, errObj.Message)
+		if errObj.Message != "division by zero" {
+			t.Errorf("%q: wrong error message. got=%q", input, errObj.Message)
 		}
 	}
 }
 
-func TestReturnStatements(t *testing.T) {
+func TestWrongNumberOfArguments(t *testing.T) {
 	tests := []struct {
 		input    string
-		expected int64
+		expected string
 	}{
-		{"return 10;", 10},
-		{"return 10; 9;", 10},
-		{"return 2 * 5; 9;", 10},
-		{"9; return 2 * 5; 9;", 10},
-		{
-			`
-if (10 > 1) {
-  if (10 > 1) {
-    return 10;
-  }
-
-  return 1;
-}
-`,
-			10,
-		},
+		{"let f = fn(a, b) { a }; f(1);", "wrong number of arguments: want 2, got 1"},
+		{"let f = fn() { 1 }; f(1, 2);", "wrong number of arguments: want 0, got 2"},
+		// a call in tail position is made by the trampoline, not by evalCallExpression
+		{"let g = fn(a, b) { a }; let f = fn() { g(1) }; f();", "wrong number of arguments: want 2, got 1"},
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		testIntegerObject(t, evaluated, tt.expected)
-	}
-}
-
-func TestIfElseExpressions(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected interface{}
-	}{
-		{"if (true) { 10 }", 10},
-		{"if (false) { 10 }", nil},
-		{"if (1) { 10 }", 10},
-		{"if (1 < 2) { 10 }", 10},
-		{"if (1 > 2) { 10 }", nil},
-		{"if (1 > 2) { 10 } else { 20 }", 20},
-		{"if (1 < 2) { 10 } else { 20 }", 10},
-	}
-
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		integer, ok := tt.expected.(int)
-		if ok {
-			testIntegerObject(t, evaluated, int64(integer))
-		} else {
-			testNullObject(t, evaluated)
+		evaluated := testEval(t, tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("%q: wrong error message. got=%q, want=%q", tt.input, errObj.Message, tt.expected)
 		}
 	}
 }
 
-func TestEvalIntegerExpression(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected int64
-	}{
-		{"5", 5},
-		{"10", 10},
-		{"-5", -5},
-		{"-10", -10},
-		{"5 + 5 + 5 + 5 - 10", 10},
-		{"2 * 2 * 2 * 2 * 2", 32},
-		{"-50 + 100 + -50", 0},
-		{"5 * 2 + 10", 20},
-		{"5 + 2 * 10", 25},
-		{"20 + 2 * -10", 0},
-		{"50 / 2 * 2 + 10", 60},
-		{"2 * (5 + 10)", 30},
-		{"3 * 3 * 3 + 10", 37},
-		{"3 * (3 * 3) + 10", 37},
-		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
-	}
+// testEval evaluates `input` twice, once finding locals in maps and once by the
+// lexical addresses `resolver.Annotate` writes. both have to agree, the result
+// of the annotated run is returned
+func testEval(t *testing.T, input string) object.Object {
+	t.Helper()
 
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		testIntegerObject(t, evaluated, tt.expected)
-	}
-}
+	plain := parser.New(lexer.New(input)).ParseProgram()
+	want := Eval(plain, object.NewEnvironment())
 
-func TestBangOperator(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected bool
-	}{
-		{"true", true},
-		{"false", false},
-		{"!true", false},
-		{"!false", true},
-		{"!5", false},
-		{"!!true", true},
-		{"!!false", false},
-		{"!!5", true},
-		{"1 < 2", true},
-		{"1 > 2", false},
-		{"1 < 1", false},
-		{"1 > 1", false},
-		{"1 == 1", true},
-		{"1 != 1", false},
-		{"1 == 2", false},
-		{"1 != 2", true},
+	annotated := parser.New(lexer.New(input)).ParseProgram()
+	resolver.Annotate(annotated)
+	got := Eval(annotated, object.NewEnvironment())
 
-		//
-		{"true == true", true},
-		{"false == false", true},
-		{"true == false", false},
-		{"true != false", true},
-		{"false != true", true},
-		{"(1 < 2) == true", true},
-		{"(1 < 2) == false", false},
-		{"(1 > 2) == true", false},
-		{"(1 > 2) == false", true},
+	if inspect(want) != inspect(got) {
+		t.Errorf("%q: results differ. map=%s, slots=%s", input, inspect(want), inspect(got))
 	}
 
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		testBooleanObject(t, evaluated, tt.expected)
-	}
+	return got
 }
 
-func TestEvalBooleanExpression(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected bool
-	}{
-		{"true", true},
-		{"false", false},
-	}
-
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		testBooleanObject(t, evaluated, tt.expected)
+func inspect(obj object.Object) string {
+	if obj == nil {
+		return "<nil>"
 	}
-}
-
-func testEval(input string) object.Object {
-	l := lexer.New(input)
-	p := parser.New(l)
-	program := p.ParseProgram()
-	env := object.NewEnvironment()
-
-	return Eval(program, env)
+	return obj.Inspect()
 }
 
 func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
@@ -325,3 +141,60 @@ func testNullObject(t *testing.T, obj object.Object) bool {
 	}
 	return true
 }
+
+// the same programs evaluated with map based environments and with lexical
+// addresses must give the same result
+func TestLexicalAddressing(t *testing.T) {
+	tests := []string{
+		"let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(15);",
+		"let newAdder = fn(x) { fn(y) { fn(z) { x + y + z } } }; newAdder(1)(2)(3);",
+		// a `let` in an `if` that did not run leaves the global visible
+		"let x = 5; let f = fn(c) { if (c) { let x = 1; } x }; f(false) * 10 + f(true);",
+		// a closure sees the latest value of a name bound twice
+		"let f = fn() { let g = fn() { x }; let x = 1; let a = g(); let x = 2; a * 10 + g() }; f();",
+		"let x = 10; let f = fn(x) { x * 2 }; f(3) + x;",
+		"let f = fn(a, a) { a }; f(1, 2);",
+		"let f = fn() { y }; f();",
+		"let f = fn() { let y = y + 1; y }; f();",
+		"let apply = fn(f, v) { f(v) }; let n = 3; apply(fn(v) { v * n }, 4);",
+	}
+
+	for _, input := range tests {
+		plain := parser.New(lexer.New(input)).ParseProgram()
+		want := Eval(plain, object.NewEnvironment())
+
+		annotated := parser.New(lexer.New(input)).ParseProgram()
+		resolver.Annotate(annotated)
+		got := Eval(annotated, object.NewEnvironment())
+
+		if want.Inspect() != got.Inspect() {
+			t.Errorf("%q: results differ. map=%s, slots=%s", input, want.Inspect(), got.Inspect())
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let loop = fn(n) { if (n == 0) { 0 } else { loop(n - 1) } }; loop(10000000);", 0},
+		{"let sum = fn(n, acc) { if (n == 0) { return acc; } return sum(n - 1, acc + n); }; sum(1000000, 0);", 500000500000},
+		{"let sum = fn(n, acc) { if (n == 0) { return acc; }; sum(n - 1, acc + n) }; sum(1000000, 0);", 500000500000},
+		{
+			"let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };" +
+				"let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };" +
+				"if (even(1000001)) { 1 } else { 0 };",
+			0,
+		},
+		// only the outer call is in tail position, the inner one still returns
+		{"let double = fn(x) { x * 2 }; let f = fn(n) { double(double(n)) }; f(3);", 12},
+		{"let f = fn(n) { let g = fn(m) { m + n }; g(1) }; f(2);", 3},
+		// calls that are not in tail position are only limited by the Go stack
+		{"let sum = fn(n) { if (n == 0) { 0 } else { n + sum(n - 1) } }; sum(20000);", 200010000},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
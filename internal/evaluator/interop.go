@@ -0,0 +1,243 @@
+package evaluator
+
+import (
+	"fmt"
+	"reflect"
+
+	"monkey-lang.z9fr.xyz/internal/object"
+)
+
+var objectType = reflect.TypeOf((*object.Object)(nil)).Elem()
+
+// Bind makes a Go value available to monkey code under `name`. functions become
+// `object.GoFunction`, structs become read-only `object.GoStruct` and plain values
+// are converted to their monkey counterparts
+func Bind(env *object.Environment, name string, v interface{}) error {
+	if reflect.TypeOf(v) != nil && reflect.TypeOf(v).Kind() == reflect.Func {
+		fn, err := object.NewGoFunction(name, v)
+		if err != nil {
+			return err
+		}
+		env.Set(name, fn)
+		return nil
+	}
+
+	obj, err := goToObject(reflect.ValueOf(v))
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	env.Set(name, obj)
+	return nil
+}
+
+// ApplyGoFunction calls `fn` with `args` converted to the Go types it wants and
+// converts the result back. problems come back as an `*object.Error`. the vm
+// uses it too, so both engines treat Go functions the same
+func ApplyGoFunction(fn *object.GoFunction, args []object.Object) object.Object {
+	t := fn.Fn.Type()
+
+	if t.IsVariadic() {
+		if len(args) < t.NumIn()-1 {
+			return newError("wrong number of arguments to %s: want at least %d, got %d",
+				fn.Name, t.NumIn()-1, len(args))
+		}
+	} else if len(args) != t.NumIn() {
+		return newError("wrong number of arguments to %s: want %d, got %d",
+			fn.Name, t.NumIn(), len(args))
+	}
+
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		var pt reflect.Type
+		if t.IsVariadic() && i >= t.NumIn()-1 {
+			pt = t.In(t.NumIn() - 1).Elem()
+		} else {
+			pt = t.In(i)
+		}
+
+		v, err := objectToGo(arg, pt)
+		if err != nil {
+			return newError("argument %d to %s: %s", i+1, fn.Name, err)
+		}
+		in[i] = v
+	}
+
+	out := fn.Fn.Call(in)
+
+	// a trailing non-nil `error` wins over any other result
+	if n := len(out); n > 0 && t.Out(n-1) == errorType {
+		if err, _ := out[n-1].Interface().(error); err != nil {
+			return newError("%s", err)
+		}
+		out = out[:n-1]
+	}
+
+	if len(out) == 0 {
+		return NULL
+	}
+
+	obj, err := goToObject(out[0])
+	if err != nil {
+		return newError("result of %s: %s", fn.Name, err)
+	}
+
+	return obj
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// Call applies `fn` to `args` the way a call in monkey code would, for Go code
+// that was handed a monkey function. hooks are not told about the call itself,
+// there is no call expression for it
+func Call(fn object.Object, args ...object.Object) object.Object {
+	return applyFunction(nil, fn, args)
+}
+
+// EvalFieldExpression reads field or method `name` of a Go struct
+func EvalFieldExpression(obj object.Object, name string) object.Object {
+	if obj == nil {
+		obj = NULL
+	}
+
+	s, ok := obj.(*object.GoStruct)
+	if !ok {
+		return newError("field access on non-struct: %s", obj.Type())
+	}
+
+	v, ok := s.Field(name)
+	if !ok {
+		return newError("unknown field: %s", name)
+	}
+
+	if v.Kind() == reflect.Func {
+		if v.IsNil() {
+			return NULL
+		}
+		fn, err := object.NewGoFunction(name, v.Interface())
+		if err != nil {
+			return newError("field %s: %s", name, err)
+		}
+		return fn
+	}
+
+	result, err := goToObject(v)
+	if err != nil {
+		return newError("field %s: %s", name, err)
+	}
+
+	return result
+}
+
+// goToObject converts a Go value in to the matching monkey object
+func goToObject(v reflect.Value) (object.Object, error) {
+	if !v.IsValid() {
+		return NULL, nil
+	}
+
+	if v.Type().Implements(objectType) && v.Kind() != reflect.Interface {
+		return v.Interface().(object.Object), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := v.Uint()
+		if u > 1<<63-1 {
+			return nil, fmt.Errorf("%d overflows INTEGER", u)
+		}
+		return &object.Integer{Value: int64(u)}, nil
+	case reflect.Bool:
+		return nativeBoolToBooleanObject(v.Bool()), nil
+	case reflect.String:
+		return &object.String{Value: v.String()}, nil
+	case reflect.Struct:
+		return &object.GoStruct{Value: v}, nil
+	case reflect.Func:
+		if v.IsNil() {
+			return NULL, nil
+		}
+		fn, err := object.NewGoFunction(v.Type().String(), v.Interface())
+		if err != nil {
+			return nil, err
+		}
+		return fn, nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return NULL, nil
+		}
+		if v.Kind() == reflect.Pointer && v.Elem().Kind() == reflect.Struct {
+			return &object.GoStruct{Value: v}, nil
+		}
+		return goToObject(v.Elem())
+	}
+
+	return nil, fmt.Errorf("unsupported Go type %s", v.Type())
+}
+
+// objectToGo converts a monkey object in to a Go value assignable to `t`
+func objectToGo(obj object.Object, t reflect.Type) (reflect.Value, error) {
+	if obj == nil {
+		// what an empty block evaluates to
+		obj = NULL
+	}
+
+	if t.Kind() == reflect.Interface && t.NumMethod() > 0 && reflect.TypeOf(obj).Implements(t) {
+		// the callee wants monkey objects as they are
+		return reflect.ValueOf(obj), nil
+	}
+
+	switch obj := obj.(type) {
+	case *object.Integer:
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			v := reflect.New(t).Elem()
+			if v.OverflowInt(obj.Value) {
+				return reflect.Value{}, fmt.Errorf("%d overflows %s", obj.Value, t)
+			}
+			v.SetInt(obj.Value)
+			return v, nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			v := reflect.New(t).Elem()
+			if obj.Value < 0 || v.OverflowUint(uint64(obj.Value)) {
+				return reflect.Value{}, fmt.Errorf("%d overflows %s", obj.Value, t)
+			}
+			v.SetUint(uint64(obj.Value))
+			return v, nil
+		case reflect.Float32, reflect.Float64:
+			return reflect.ValueOf(float64(obj.Value)).Convert(t), nil
+		case reflect.Interface:
+			return assignable(reflect.ValueOf(obj.Value), t)
+		}
+	case *object.Boolean:
+		return assignable(reflect.ValueOf(obj.Value), t)
+	case *object.String:
+		return assignable(reflect.ValueOf(obj.Value), t)
+	case *object.GoStruct:
+		return assignable(obj.Value, t)
+	case *object.GoFunction:
+		return assignable(obj.Fn, t)
+	case *object.Null:
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Func, reflect.Map, reflect.Slice:
+			return reflect.Zero(t), nil
+		}
+	}
+
+	return reflect.Value{}, fmt.Errorf("cannot use %s as %s", obj.Type(), t)
+}
+
+func assignable(v reflect.Value, t reflect.Type) (reflect.Value, error) {
+	switch {
+	case v.Type().AssignableTo(t):
+		return v, nil
+	case v.Kind() == reflect.Pointer && v.Elem().Type().AssignableTo(t):
+		return v.Elem(), nil
+	case v.Kind() != reflect.Func && v.Type().ConvertibleTo(t) && v.Kind() == t.Kind():
+		// named types like `type Celsius int`
+		return v.Convert(t), nil
+	}
+
+	return reflect.Value{}, fmt.Errorf("cannot use %s as %s", v.Type(), t)
+}
@@ -0,0 +1,211 @@
+package evaluator
+
+import (
+	"errors"
+	"testing"
+
+	"monkey-lang.z9fr.xyz/internal/lexer"
+	"monkey-lang.z9fr.xyz/internal/object"
+	"monkey-lang.z9fr.xyz/internal/parser"
+)
+
+type point struct {
+	X, Y   int
+	hidden int
+}
+
+func (p point) Sum() int { return p.X + p.Y }
+
+func (p point) Both() (int, int) { return p.X, p.Y }
+
+type widget struct {
+	OnClick func() int
+}
+
+func testEvalWithBindings(t *testing.T, input string, bindings map[string]interface{}) object.Object {
+	env := object.NewEnvironment()
+	for name, v := range bindings {
+		if err := Bind(env, name, v); err != nil {
+			t.Fatalf("Bind(%q) failed: %s", name, err)
+		}
+	}
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	return Eval(program, env)
+}
+
+func TestGoFunctionCalls(t *testing.T) {
+	bindings := map[string]interface{}{
+		"repeat": func(n int64, s string) (bool, error) {
+			if n < 0 {
+				return false, errors.New("negative count")
+			}
+			return len(s)*int(n) > 3, nil
+		},
+		"add":     func(a, b int) int { return a + b },
+		"nothing": func() {},
+		"sum": func(xs ...int) int {
+			total := 0
+			for _, x := range xs {
+				total += x
+			}
+			return total
+		},
+		"small": func(b int8) int8 { return b },
+		"show":  func(o object.Object) string { return o.Inspect() },
+		"isNil": func(p *point) bool { return p == nil },
+	}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`repeat(2, "ab")`, true},
+		{`repeat(1, "ab")`, false},
+		{`repeat(-1, "ab")`, "negative count"},
+		{`add(2, 3) * 2`, 10},
+		{`let f = add; f(1, 1)`, 2},
+		{`nothing()`, nil},
+		{`sum()`, 0},
+		{`sum(1, 2, 3)`, 6},
+		{`add(1)`, "wrong number of arguments to add: want 2, got 1"},
+		{`add(1, true)`, "argument 2 to add: cannot use bool as int"},
+		{`small(300)`, "argument 1 to small: 300 overflows int8"},
+		// an empty function body evaluates to nil, Go sees it as null
+		{`let f = fn() { }; isNil(f())`, true},
+		{`let f = fn() { }; show(f()) == "null"`, true},
+		{`let f = fn() { }; add(f(), 1)`, "argument 1 to add: cannot use NULL as int"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithBindings(t, tt.input, bindings)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%s: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		case nil:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestGoStructFields(t *testing.T) {
+	bindings := map[string]interface{}{
+		"p":      point{X: 3, Y: 4},
+		"origin": &point{},
+		"name":   "monkey",
+		"w":      widget{},
+		"click":  widget{OnClick: func() int { return 1 }},
+	}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"p.X", 3},
+		{"p.X * p.Y", 12},
+		{"p.Sum()", 7},
+		{"origin.Y", 0},
+		{`name + "!"`, "monkey!"},
+		{"p.hidden", "unknown field: hidden"},
+		{"p.Z", "unknown field: Z"},
+		{"5.X", "field access on non-struct: INTEGER"},
+		{"let f = fn() { }; f().X", "field access on non-struct: NULL"},
+		{"click.OnClick()", 1},
+		{"w.OnClick", nil},
+		{"p.Both", "field Both: Both: second result must be an error, got int"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithBindings(t, tt.input, bindings)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("String has wrong value. got=%q, want=%q", obj.Value, expected)
+				}
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, obj.Message)
+				}
+			default:
+				t.Errorf("%s: unexpected object. got=%T(%+v)", tt.input, evaluated, evaluated)
+			}
+		case nil:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestBindRejectsUnsupportedValues(t *testing.T) {
+	env := object.NewEnvironment()
+
+	if err := Bind(env, "m", map[string]int{}); err == nil {
+		t.Errorf("expected error binding a map")
+	}
+
+	if err := Bind(env, "f", func() (int, int) { return 0, 0 }); err == nil {
+		t.Errorf("expected error binding a function with two non-error results")
+	}
+
+	var nilFunc func() int
+	if err := Bind(env, "g", nilFunc); err == nil {
+		t.Errorf("expected error binding a nil function")
+	}
+}
+
+func TestCall(t *testing.T) {
+	add := testEvalWithBindings(t, "fn(a, b) { a + b }", nil)
+	loop := testEvalWithBindings(t, "let loop = fn(n) { if (n == 0) { 7 } else { loop(n - 1) } }; loop", nil)
+	double, err := object.NewGoFunction("double", func(n int) int { return 2 * n })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		fn       object.Object
+		args     []object.Object
+		expected interface{}
+	}{
+		{add, []object.Object{&object.Integer{Value: 1}, &object.Integer{Value: 2}}, 3},
+		// tail calls made by the function still run in a loop
+		{loop, []object.Object{&object.Integer{Value: 100000}}, 7},
+		{double, []object.Object{&object.Integer{Value: 4}}, 8},
+		{add, []object.Object{&object.Integer{Value: 1}}, "wrong number of arguments: want 2, got 1"},
+		{&object.Integer{Value: 1}, nil, "not a function: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		result := Call(tt.fn, tt.args...)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, result, int64(expected))
+		case string:
+			errObj, ok := result.(*object.Error)
+			if !ok || errObj.Message != expected {
+				t.Errorf("got %v, want error %q", result, expected)
+			}
+		}
+	}
+}
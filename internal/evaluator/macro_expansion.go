@@ -0,0 +1,138 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/object"
+)
+
+// DefineMacros finds top level `let name = macro(...) {...};` statements, binds
+// them in `env` and removes them from the program, so the evaluator never sees
+// them. macros can only be defined at the top level
+func DefineMacros(program *ast.Program, env *object.Environment) {
+	definitions := []int{}
+
+	for i, statement := range program.Statements {
+		if isMacroDefinition(statement) {
+			addMacro(statement, env)
+			definitions = append(definitions, i)
+		}
+	}
+
+	for i := len(definitions) - 1; i >= 0; i = i - 1 {
+		definitionIndex := definitions[i]
+		program.Statements = append(
+			program.Statements[:definitionIndex],
+			program.Statements[definitionIndex+1:]...,
+		)
+	}
+}
+
+func isMacroDefinition(node ast.Statement) bool {
+	letStatement, ok := node.(*ast.LetStatement)
+	if !ok {
+		return false
+	}
+
+	_, ok = letStatement.Value.(*ast.MacroLiteral)
+	return ok
+}
+
+func addMacro(stmt ast.Statement, env *object.Environment) {
+	letStatement, _ := stmt.(*ast.LetStatement)
+	macroLiteral, _ := letStatement.Value.(*ast.MacroLiteral)
+
+	macro := &object.Macro{
+		Parameters: macroLiteral.Parameters,
+		Env:        env,
+		Body:       macroLiteral.Body,
+	}
+
+	env.Set(letStatement.Name.Value, macro)
+}
+
+// ExpandMacros replaces every call to a macro defined in `env` with the AST the
+// macro returns. the arguments are passed to the macro quoted, not evaluated.
+// a macro that fails, or returns something other than a quote, is an error and
+// the call is left where it was
+func ExpandMacros(program ast.Node, env *object.Environment) (ast.Node, error) {
+	var err error
+
+	expanded := ast.Modify(program, func(node ast.Node) ast.Node {
+		callExpression, ok := node.(*ast.CallExpression)
+		if !ok || err != nil {
+			return node
+		}
+
+		macro, ok := isMacroCall(callExpression, env)
+		if !ok {
+			return node
+		}
+		name := callExpression.Function.String()
+
+		args := quoteArgs(callExpression)
+		evalEnv, argErr := extendMacroEnv(macro, args)
+		if argErr != nil {
+			err = fmt.Errorf("macro %s: %w", name, argErr)
+			return node
+		}
+
+		switch evaluated := unwrapReturnValue(Eval(macro.Body, evalEnv)).(type) {
+		case *object.Quote:
+			return evaluated.Node
+		case *object.Error:
+			err = fmt.Errorf("macro %s: %s", name, evaluated.Message)
+		case nil:
+			err = fmt.Errorf("macro %s returned nothing, want a quote", name)
+		default:
+			err = fmt.Errorf("macro %s returned %s, want a quote", name, evaluated.Type())
+		}
+		return node
+	})
+
+	return expanded, err
+}
+
+func isMacroCall(exp *ast.CallExpression, env *object.Environment) (*object.Macro, bool) {
+	identifier, ok := exp.Function.(*ast.Identifier)
+	if !ok {
+		return nil, false
+	}
+
+	obj, ok := env.Get(identifier.Value)
+	if !ok {
+		return nil, false
+	}
+
+	macro, ok := obj.(*object.Macro)
+	if !ok {
+		return nil, false
+	}
+
+	return macro, true
+}
+
+func quoteArgs(exp *ast.CallExpression) []*object.Quote {
+	args := []*object.Quote{}
+
+	for _, a := range exp.Arguments {
+		args = append(args, &object.Quote{Node: a})
+	}
+
+	return args
+}
+
+func extendMacroEnv(macro *object.Macro, args []*object.Quote) (*object.Environment, error) {
+	if len(macro.Parameters) != len(args) {
+		return nil, fmt.Errorf("wrong number of arguments: want %d, got %d",
+			len(macro.Parameters), len(args))
+	}
+
+	extended := object.NewEnclosedEnvironment(macro.Env)
+	for paramIdx, param := range macro.Parameters {
+		extended.Set(param.Value, args[paramIdx])
+	}
+
+	return extended, nil
+}
@@ -0,0 +1,82 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/object"
+	"monkey-lang.z9fr.xyz/internal/token"
+)
+
+// quote returns `node` unevaluated, except for `unquote(...)` calls inside of it.
+// those are evaluated and their result is put back in to the tree as AST nodes.
+// that happens on a copy, a macro body is quoted again on every expansion
+func quote(node ast.Node, env *object.Environment) object.Object {
+	node = evalUnquoteCalls(ast.Copy(node), env)
+	return &object.Quote{Node: node}
+}
+
+func evalUnquoteCalls(quoted ast.Node, env *object.Environment) ast.Node {
+	return ast.Modify(quoted, func(node ast.Node) ast.Node {
+		if !isUnquoteCall(node) {
+			return node
+		}
+
+		call, ok := node.(*ast.CallExpression)
+		if !ok {
+			return node
+		}
+
+		if len(call.Arguments) != 1 {
+			return node
+		}
+
+		unquoted := Eval(call.Arguments[0], env)
+		return convertObjectToASTNode(unquoted)
+	})
+}
+
+func isUnquoteCall(node ast.Node) bool {
+	callExpression, ok := node.(*ast.CallExpression)
+	if !ok {
+		return false
+	}
+
+	return callExpression.Function.TokenLiteral() == "unquote"
+}
+
+// convertObjectToASTNode turns the result of an `unquote` back in to source. for
+// objects that have no literal form we return nil, `ast.Modify` then keeps the
+// `unquote` call where it was
+func convertObjectToASTNode(obj object.Object) ast.Node {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		t := token.Token{
+			Type:    token.INT,
+			Literal: fmt.Sprintf("%d", obj.Value),
+		}
+		return &ast.IntegerLiteral{Token: t, Value: obj.Value}
+
+	case *object.Boolean:
+		var t token.Token
+		if obj.Value {
+			t = token.Token{Type: token.TRUE, Literal: "true"}
+		} else {
+			t = token.Token{Type: token.FALSE, Literal: "false"}
+		}
+		return &ast.Boolean{Token: t, Value: obj.Value}
+
+	case *object.String:
+		t := token.Token{Type: token.STRING, Literal: obj.Value}
+		return &ast.StringLiteral{Token: t, Value: obj.Value}
+
+	case *object.Quote:
+		// the same quote can be unquoted more than once, like a macro argument
+		// that is used twice. each place gets its own nodes, the resolver
+		// writes a different address in to each of them
+		return ast.Copy(obj.Node)
+
+	default:
+		return nil
+	}
+}
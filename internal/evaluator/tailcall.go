@@ -0,0 +1,105 @@
+package evaluator
+
+import (
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/object"
+)
+
+// a call in tail position is not made where it is evaluated. it is handed back
+// to `applyFunction` as a `tailCall`, which then makes it in place of the
+// current call. this way a loop written as recursion does not grow the Go stack.
+//
+// tail positions are the last statement of a function body, both branches of
+// an `if` in tail position and the value of any `return` in a function body
+
+// tailCall is a call that has not been made yet, it never leaves `applyFunction`
+type tailCall struct {
+	call *ast.CallExpression
+	fn   object.Object
+	args []object.Object
+}
+
+func (tc *tailCall) Type() object.ObjectType { return "TAIL_CALL" }
+func (tc *tailCall) Inspect() string         { return "tail call" }
+
+// evalTailBlock evaluates a block of a function body like `evalBlockStatement`.
+// `tail` is set when the value of the block is the value of the call
+func evalTailBlock(block *ast.BlockStatement, env *object.Environment, tail bool) object.Object {
+	var result object.Object
+
+	for i, statement := range block.Statements {
+		hooks := env.Hooks()
+		if hooks != nil {
+			hooks.BeforeStatement(statement, env)
+		}
+		result = evalTailStatement(statement, env, tail && i == len(block.Statements)-1)
+		if hooks != nil {
+			hooks.AfterStatement(statement, env)
+		}
+
+		if result != nil {
+			rt := result.Type()
+
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+				return result
+			}
+		}
+	}
+
+	return result
+}
+
+func evalTailStatement(stmt ast.Statement, env *object.Environment, tail bool) object.Object {
+	switch stmt := stmt.(type) {
+	case *ast.ReturnStatement:
+		// whatever `return` gives back is the value of the call
+		val := evalTailExpression(stmt.ReturnValue, env, true)
+		if isError(val) {
+			return val
+		}
+		return &object.ReturnValue{Value: val}
+	case *ast.ExpressionStatement:
+		return evalTailExpression(stmt.Expression, env, tail)
+	}
+
+	return Eval(stmt, env)
+}
+
+func evalTailExpression(exp ast.Expression, env *object.Environment, tail bool) object.Object {
+	switch exp := exp.(type) {
+	case *ast.IfExpression:
+		// an `if` that is not in tail position may still hold a `return`
+		condition := Eval(exp.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+
+		if hooks := env.Hooks(); hooks != nil {
+			hooks.Branch(exp, isTruthy(condition))
+		}
+
+		if isTruthy(condition) {
+			return evalTailBlock(exp.Consequence, env, tail)
+		} else if exp.Alternative != nil {
+			return evalTailBlock(exp.Alternative, env, tail)
+		}
+		return NULL
+	case *ast.CallExpression:
+		if !tail || exp.Function.TokenLiteral() == "quote" {
+			break
+		}
+
+		function := Eval(exp.Function, env)
+		if isError(function) {
+			return function
+		}
+		args := evalExpression(exp.Arguments, env)
+		if len(args) == 1 && isError(args[0]) {
+			return args[0]
+		}
+
+		return &tailCall{call: exp, fn: function, args: args}
+	}
+
+	return Eval(exp, env)
+}
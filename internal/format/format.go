@@ -0,0 +1,257 @@
+package format
+
+/*
+format
+
+prints an AST back as monkey source in one canonical layout:
+
+  - one statement per line, `let`, `return` and expression statements end in `;`
+  - blocks are indented with a tab
+  - only the parentheses that precedence needs are kept: `(a + (b * c))` is
+    printed as `a + b * c`
+  - top level statements that span several lines are separated by a blank line
+
+the output parses back to the same AST, and formatting it again changes nothing
+*/
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/lexer"
+	"monkey-lang.z9fr.xyz/internal/parser"
+)
+
+// primary is the precedence of expressions that never need parentheses around
+// them: literals, identifiers, calls, field access, `if` and `fn`
+const primary = parser.FIELD + 1
+
+// Source formats monkey source code. it fails with the parser errors when `src`
+// doesn't parse
+func Source(src []byte) ([]byte, error) {
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		return nil, errors.New(strings.Join(p.Errors(), "\n"))
+	}
+
+	var out bytes.Buffer
+	if err := Node(&out, program); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+// Node writes `node` to `w` as formatted source. a `*ast.Program` ends with a
+// newline, any other node is printed as it would appear at the top level
+func Node(w io.Writer, node ast.Node) error {
+	p := &printer{}
+
+	switch node := node.(type) {
+	case *ast.Program:
+		p.program(node)
+	case ast.Statement:
+		p.statement(node)
+	case ast.Expression:
+		p.expr(node, parser.LOWEST)
+	}
+
+	_, err := w.Write(p.buf.Bytes())
+	return err
+}
+
+type printer struct {
+	buf    bytes.Buffer
+	indent int
+}
+
+func (p *printer) print(s string) {
+	p.buf.WriteString(s)
+}
+
+func (p *printer) newline() {
+	p.buf.WriteByte('\n')
+	p.buf.WriteString(strings.Repeat("\t", p.indent))
+}
+
+func (p *printer) program(program *ast.Program) {
+	for i, stmt := range program.Statements {
+		text := p.render(stmt)
+
+		if i > 0 {
+			prev := p.render(program.Statements[i-1])
+			if strings.Contains(prev, "\n") || strings.Contains(text, "\n") {
+				p.print("\n")
+			}
+		}
+
+		p.print(text)
+		p.print(p.terminator(stmt, program.Statements[i+1:]))
+		p.print("\n")
+	}
+}
+
+// render prints a statement on its own, used to look at it before committing
+// to a layout
+func (p *printer) render(stmt ast.Statement) string {
+	sub := &printer{indent: p.indent}
+	sub.statement(stmt)
+	return sub.buf.String()
+}
+
+func (p *printer) statements(list []ast.Statement) {
+	for i, stmt := range list {
+		p.newline()
+		p.statement(stmt)
+		p.print(p.terminator(stmt, list[i+1:]))
+	}
+}
+
+// terminator decides whether `stmt` ends in `;`. an `if` reads better without
+// one, but only when the next statement can't be mistaken for a continuation of
+// it, like `-1` being parsed as `if (...) {...} - 1`
+func (p *printer) terminator(stmt ast.Statement, rest []ast.Statement) string {
+	es, ok := stmt.(*ast.ExpressionStatement)
+	if !ok {
+		return ";"
+	}
+
+	if _, ok := es.Expression.(*ast.IfExpression); !ok {
+		return ";"
+	}
+
+	if len(rest) > 0 {
+		next := p.render(rest[0])
+		if strings.HasPrefix(next, "-") || strings.HasPrefix(next, "(") {
+			return ";"
+		}
+	}
+
+	return ""
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		p.print("let ")
+		p.print(stmt.Name.Value)
+		p.print(" = ")
+		p.expr(stmt.Value, parser.LOWEST)
+	case *ast.ReturnStatement:
+		p.print("return ")
+		p.expr(stmt.ReturnValue, parser.LOWEST)
+	case *ast.ExpressionStatement:
+		p.expr(stmt.Expression, parser.LOWEST)
+	case *ast.BlockStatement:
+		p.block(stmt)
+	}
+}
+
+func (p *printer) block(block *ast.BlockStatement) {
+	if len(block.Statements) == 0 {
+		p.print("{}")
+		return
+	}
+
+	p.print("{")
+	p.indent++
+	p.statements(block.Statements)
+	p.indent--
+	p.newline()
+	p.print("}")
+}
+
+// precedence of the operator at the root of `e`
+func precedence(e ast.Expression) int {
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(e.Token.Type)
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	default:
+		return primary
+	}
+}
+
+// expr prints `e` in a place where the parser only accepts operators binding
+// at least as tight as `min`; anything looser is wrapped in parentheses
+func (p *printer) expr(e ast.Expression, min int) {
+	if e == nil {
+		return
+	}
+
+	if precedence(e) < min {
+		p.print("(")
+		defer p.print(")")
+	}
+
+	switch e := e.(type) {
+	case *ast.Identifier:
+		p.print(e.Value)
+	case *ast.IntegerLiteral:
+		p.print(e.Token.Literal)
+	case *ast.Boolean:
+		p.print(e.Token.Literal)
+	case *ast.StringLiteral:
+		p.print(`"` + e.Value + `"`)
+	case *ast.PrefixExpression:
+		p.print(e.Operator)
+		p.expr(e.Right, parser.PREFIX)
+	case *ast.InfixExpression:
+		// operators are left associative, so on the right an operator of the
+		// same precedence needs parentheses: `a - (b - c)`
+		prec := precedence(e)
+		p.expr(e.Left, prec)
+		p.print(" " + e.Operator + " ")
+		p.expr(e.Right, prec+1)
+	case *ast.IfExpression:
+		p.print("if (")
+		p.expr(e.Condition, parser.LOWEST)
+		p.print(") ")
+		p.block(e.Consequence)
+		if e.Alternative != nil {
+			p.print(" else ")
+			p.block(e.Alternative)
+		}
+	case *ast.FunctionLiteral:
+		p.print("fn")
+		p.parameters(e.Parameters)
+		p.print(" ")
+		p.block(e.Body)
+	case *ast.MacroLiteral:
+		p.print("macro")
+		p.parameters(e.Parameters)
+		p.print(" ")
+		p.block(e.Body)
+	case *ast.CallExpression:
+		p.expr(e.Function, primary)
+		p.print("(")
+		for i, arg := range e.Arguments {
+			if i > 0 {
+				p.print(", ")
+			}
+			p.expr(arg, parser.LOWEST)
+		}
+		p.print(")")
+	case *ast.FieldExpression:
+		p.expr(e.Object, primary)
+		p.print(".")
+		p.print(e.Field.Value)
+	}
+}
+
+func (p *printer) parameters(params []*ast.Identifier) {
+	p.print("(")
+	for i, param := range params {
+		if i > 0 {
+			p.print(", ")
+		}
+		p.print(param.Value)
+	}
+	p.print(")")
+}
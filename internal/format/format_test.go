@@ -0,0 +1,147 @@
+package format
+
+import (
+	"strings"
+	"testing"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/lexer"
+	"monkey-lang.z9fr.xyz/internal/parser"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=5", "let x = 5;\n"},
+		{"return   x", "return x;\n"},
+		{"a + b * c", "a + b * c;\n"},
+		{"(a + b) * c", "(a + b) * c;\n"},
+		{"a - (b - c)", "a - (b - c);\n"},
+		{"(a - b) - c", "a - b - c;\n"},
+		{"-(a + b)", "-(a + b);\n"},
+		{"-(-a)", "--a;\n"},
+		{"(-a) * b", "-a * b;\n"},
+		{"!(true == false)", "!(true == false);\n"},
+		{"(5 > 4) == (3 < 4)", "5 > 4 == 3 < 4;\n"},
+		{"a == (b == c)", "a == (b == c);\n"},
+		{"(f)(x)", "f(x);\n"},
+		{"(a + b)(x)", "(a + b)(x);\n"},
+		{"(-a)(x)", "(-a)(x);\n"},
+		{"-a(x)", "-a(x);\n"},
+		{"(a(1)).b", "a(1).b;\n"},
+		{"(a + b).c", "(a + b).c;\n"},
+		{"add(a + b, (c * d))", "add(a + b, c * d);\n"},
+		{`"hello" + " " + name`, `"hello" + " " + name;` + "\n"},
+		{"fn() {}", "fn() {};\n"},
+		{"fn(x, y) { x + y; }", "fn(x, y) {\n\tx + y;\n};\n"},
+		{"fn(x) { x }(5)", "fn(x) {\n\tx;\n}(5);\n"},
+		{
+			"if (x < y) { x } else { if (y) { return y; } }",
+			"if (x < y) {\n\tx;\n} else {\n\tif (y) {\n\t\treturn y;\n\t}\n}\n",
+		},
+		{
+			"if (x) { 1 }; -1",
+			"if (x) {\n\t1;\n};\n\n-1;\n",
+		},
+		{
+			"let a = 1; let b = 2; let add = fn(x, y) { x + y }; add(a, b);",
+			"let a = 1;\nlet b = 2;\n\nlet add = fn(x, y) {\n\tx + y;\n};\n\nadd(a, b);\n",
+		},
+		{
+			"let m = macro(a) { quote(unquote(a) + 1) };",
+			"let m = macro(a) {\n\tquote(unquote(a) + 1);\n};\n",
+		},
+	}
+
+	for _, tt := range tests {
+		out, err := Source([]byte(tt.input))
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", tt.input, err)
+			continue
+		}
+
+		if string(out) != tt.expected {
+			t.Errorf("%q: wrong output.\nexpected=%q\ngot=     %q", tt.input, tt.expected, string(out))
+		}
+	}
+}
+
+// inputs from the parser tests. each one must survive a round trip through the
+// formatter unchanged, and formatting twice must not change anything
+var roundTripInputs = []string{
+	"-a * b", "!-a", "a + b + c", "a + b - c", "a * b * c", "a * b / c",
+	"a + b / c", "a + b * c + d / e - f", "3 + 4; -5 * 5", "5 > 4 == 3 < 4",
+	"5 < 4 != 3 > 4", "3 + 4 * 5 == 3 * 1 + 4 * 5", "true", "false",
+	"3 > 5 == false", "3 < 5 == true", "1 + (2 + 3) + 4", "(5 + 5) * 2",
+	"2 / (5 + 5)", "-(5 + 5)", "!(true == true)", "a + add(b * c) + d",
+	"add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8))", "add(a + b + c * d / f + g)",
+	"a.b(c) * d.e", "let x = 5;", "let y = true;", "let foobar = y;",
+	"return 5;", "return 10;", "return add(15);",
+	"if (x < y) { x }", "if (x < y) { x } else { y }",
+	"fn(x, y) { x + y; }", "fn() {};", "fn(x) {};", "fn(x, y, z) {};",
+	"add(1, 2 * 3, 4 + 5);", `"hello world";`, "macro(x, y) { x + y; }",
+	`let unless = macro(condition, consequence, alternative) {
+		quote(if (!(unquote(condition))) { unquote(consequence); } else { unquote(alternative); });
+	};
+	unless(10 > 5, puts("not greater"), puts("greater"));`,
+	`let newAdder = fn(x) { fn(y) { x + y }; }; let addTwo = newAdder(2); addTwo(2);`,
+	`if (10 > 1) { if (10 > 1) { return true + false; } return 1; }`,
+	"if (a) { 1 } else { 2 } + 3",
+	"if (a) { 1 } (2)",
+	"if (a) { 1 }; -2",
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, input := range roundTripInputs {
+		original := parse(t, input)
+
+		formatted, err := Source([]byte(input))
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", input, err)
+			continue
+		}
+
+		if got := tree(t, parse(t, string(formatted))); got != tree(t, original) {
+			t.Errorf("%q: formatting changed the AST.\nformatted:\n%s\nbefore:\n%s\nafter:\n%s",
+				input, formatted, tree(t, original), got)
+		}
+
+		again, err := Source(formatted)
+		if err != nil {
+			t.Errorf("%q: formatted output does not parse: %s", input, err)
+			continue
+		}
+
+		if string(again) != string(formatted) {
+			t.Errorf("%q: formatting is not idempotent.\nfirst=%q\nsecond=%q", input, formatted, again)
+		}
+	}
+}
+
+func TestSourceParseError(t *testing.T) {
+	if _, err := Source([]byte("let = 5;")); err == nil {
+		t.Errorf("expected an error for invalid source")
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("%q: parser errors: %v", input, p.Errors())
+	}
+	return program
+}
+
+// tree renders the structure of the AST, without anything about layout
+func tree(t *testing.T, node ast.Node) string {
+	var out strings.Builder
+	if err := ast.Fprint(&out, node); err != nil {
+		t.Fatalf("Fprint: %s", err)
+	}
+	return out.String()
+}
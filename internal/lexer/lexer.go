@@ -18,16 +18,26 @@ type Lexer struct {
 	position     int  // current position in input (points to current char)
 	readPosition int  // current reading position in input (after current char)
 	ch           byte // current char under examination
+
+	// line and column of `ch`, both counting from 1
+	line   int
+	column int
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
 
 // readChar is used to get the next character and move in to next char of the input
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+	l.column++
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -37,11 +47,14 @@ func (l *Lexer) readChar() {
 	l.readPosition += 1
 }
 
-func (l *Lexer) NextToken() token.Token {
-	var t token.Token
+func (l *Lexer) NextToken() (t token.Token) {
 
 	l.skipWhitespace()
 
+	// every token is stamped with where it starts
+	pos := token.Position{Offset: l.position, Line: l.line, Column: l.column}
+	defer func() { t.Pos = pos }()
+
 	switch l.ch {
 	// we are going to extend `=` and `!` to support operations like
 	// `==`, `!=`
@@ -73,6 +86,11 @@ func (l *Lexer) NextToken() token.Token {
 		t = NewToken(token.RPAREN, l.ch)
 	case ',':
 		t = NewToken(token.COMMA, l.ch)
+	case '.':
+		t = NewToken(token.DOT, l.ch)
+	case '"':
+		t.Type = token.STRING
+		t.Literal = l.readString()
 	case '+':
 		t = NewToken(token.PLUS, l.ch)
 	case '-':
@@ -122,6 +140,19 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
+// readString reads until the closing `"` or the end of input. the surrounding
+// quotes are not part of the literal
+func (l *Lexer) readString() string {
+	position := l.position + 1
+	for {
+		l.readChar()
+		if l.ch == '"' || l.ch == 0 {
+			break
+		}
+	}
+	return l.input[position:l.position]
+}
+
 func isLetter(ch byte) bool {
 	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
 }
@@ -27,6 +27,10 @@ if(5 < 10) {
 
 10 == 10;
 10 != 9;
+"foobar"
+"foo bar"
+point.x;
+macro(x, y) { x + y; };
 `
 
 	tests := []struct {
@@ -109,6 +113,26 @@ if(5 < 10) {
 		{token.INT, "9"},
 		{token.SEMICOLON, ";"},
 
+		{token.STRING, "foobar"},
+		{token.STRING, "foo bar"},
+		{token.IDENT, "point"},
+		{token.DOT, "."},
+		{token.IDENT, "x"},
+		{token.SEMICOLON, ";"},
+		{token.MACRO, "macro"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.COMMA, ","},
+		{token.IDENT, "y"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "x"},
+		{token.PLUS, "+"},
+		{token.IDENT, "y"},
+		{token.SEMICOLON, ";"},
+		{token.RBRACE, "}"},
+		{token.SEMICOLON, ";"},
+
 		{token.EOF, ""},
 	}
 
@@ -131,3 +155,45 @@ if(5 < 10) {
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 5;\n  add(x,\n\t\"a b\");"
+
+	tests := []struct {
+		literal string
+		pos     token.Position
+		end     token.Position
+	}{
+		{"let", token.Position{Offset: 0, Line: 1, Column: 1}, token.Position{Offset: 3, Line: 1, Column: 4}},
+		{"x", token.Position{Offset: 4, Line: 1, Column: 5}, token.Position{Offset: 5, Line: 1, Column: 6}},
+		{"=", token.Position{Offset: 6, Line: 1, Column: 7}, token.Position{Offset: 7, Line: 1, Column: 8}},
+		{"5", token.Position{Offset: 8, Line: 1, Column: 9}, token.Position{Offset: 9, Line: 1, Column: 10}},
+		{";", token.Position{Offset: 9, Line: 1, Column: 10}, token.Position{Offset: 10, Line: 1, Column: 11}},
+		{"add", token.Position{Offset: 13, Line: 2, Column: 3}, token.Position{Offset: 16, Line: 2, Column: 6}},
+		{"(", token.Position{Offset: 16, Line: 2, Column: 6}, token.Position{Offset: 17, Line: 2, Column: 7}},
+		{"x", token.Position{Offset: 17, Line: 2, Column: 7}, token.Position{Offset: 18, Line: 2, Column: 8}},
+		{",", token.Position{Offset: 18, Line: 2, Column: 8}, token.Position{Offset: 19, Line: 2, Column: 9}},
+		{"a b", token.Position{Offset: 21, Line: 3, Column: 2}, token.Position{Offset: 26, Line: 3, Column: 7}},
+		{")", token.Position{Offset: 26, Line: 3, Column: 7}, token.Position{Offset: 27, Line: 3, Column: 8}},
+		{";", token.Position{Offset: 27, Line: 3, Column: 8}, token.Position{Offset: 28, Line: 3, Column: 9}},
+		{"", token.Position{Offset: 28, Line: 3, Column: 9}, token.Position{Offset: 28, Line: 3, Column: 9}},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Literal != tt.literal {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.literal, tok.Literal)
+		}
+
+		if tok.Pos != tt.pos {
+			t.Errorf("tests[%d] - %q position wrong. expected=%+v, got=%+v", i, tok.Literal, tt.pos, tok.Pos)
+		}
+
+		if tok.End() != tt.end {
+			t.Errorf("tests[%d] - %q end wrong. expected=%+v, got=%+v", i, tok.Literal, tt.end, tok.End())
+		}
+	}
+}
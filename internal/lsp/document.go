@@ -0,0 +1,181 @@
+package lsp
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/lexer"
+	"monkey-lang.z9fr.xyz/internal/parser"
+	"monkey-lang.z9fr.xyz/internal/resolver"
+	"monkey-lang.z9fr.xyz/internal/token"
+)
+
+// document is an open file and what is known about it. it is analysed again
+// from scratch on every change, monkey files are small
+type document struct {
+	uri  string
+	text string
+	// byte offset of the start of every line
+	lines []int
+
+	program *ast.Program
+	errors  []parser.Error
+	// resolved even when there are parser errors, completion works on code
+	// that is being typed
+	result *resolver.Result
+	// every identifier with a position, in source order
+	idents []*ast.Identifier
+}
+
+func newDocument(uri, text string, predeclared []string) *document {
+	d := &document{uri: uri, text: text, lines: []int{0}}
+
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			d.lines = append(d.lines, i+1)
+		}
+	}
+
+	p := parser.New(lexer.New(text))
+	d.program = p.ParseProgram()
+	d.errors = p.ErrorList()
+	d.result = resolver.Resolve(d.program, predeclared...)
+
+	ast.Inspect(d.program, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Identifier); ok && ident != nil && ident.Token.Pos.IsValid() {
+			d.idents = append(d.idents, ident)
+		}
+		return true
+	})
+	sort.SliceStable(d.idents, func(i, j int) bool {
+		return d.idents[i].Token.Pos.Offset < d.idents[j].Token.Pos.Offset
+	})
+
+	return d
+}
+
+// positionAt turns a byte offset in to a position
+func (d *document) positionAt(offset int) position {
+	if offset > len(d.text) {
+		offset = len(d.text)
+	}
+
+	line := sort.Search(len(d.lines), func(i int) bool { return d.lines[i] > offset }) - 1
+	return position{Line: line, Character: utf16Len(d.text[d.lines[line]:offset])}
+}
+
+// offset turns a position in to a byte offset. positions past the end of a
+// line are the end of the line
+func (d *document) offset(p position) int {
+	if p.Line < 0 {
+		return 0
+	}
+	if p.Line >= len(d.lines) {
+		return len(d.text)
+	}
+
+	offset := d.lines[p.Line]
+	for units := 0; units < p.Character && offset < len(d.text); {
+		r, size := utf8.DecodeRuneInString(d.text[offset:])
+		if r == '\n' {
+			break
+		}
+		units += utf16Len(string(r))
+		offset += size
+	}
+	return offset
+}
+
+func (d *document) span(start, end token.Position) span {
+	return span{Start: d.positionAt(start.Offset), End: d.positionAt(end.Offset)}
+}
+
+func (d *document) identSpan(ident *ast.Identifier) span {
+	return d.span(ident.Token.Pos, ident.Token.End())
+}
+
+// identAt returns the identifier under `offset`, a cursor just after one
+// counts as well
+func (d *document) identAt(offset int) *ast.Identifier {
+	for _, ident := range d.idents {
+		if ident.Token.Pos.Offset <= offset && offset <= ident.Token.End().Offset {
+			return ident
+		}
+	}
+	return nil
+}
+
+// wordSpan is the span of the word starting at `pos`, or of one character
+// when there is none. errors only come with a start
+func (d *document) wordSpan(pos token.Position) span {
+	start := pos.Offset
+	if start > len(d.text) {
+		start = len(d.text)
+	}
+
+	end := start
+	for end < len(d.text) && isWordByte(d.text[end]) {
+		end++
+	}
+	if end == start && end < len(d.text) && d.text[end] != '\n' {
+		_, size := utf8.DecodeRuneInString(d.text[end:])
+		end += size
+	}
+
+	return span{Start: d.positionAt(start), End: d.positionAt(end)}
+}
+
+// end is the position after the last character
+func (d *document) end() position {
+	return d.positionAt(len(d.text))
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9'
+}
+
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// scopeAt returns the innermost scope whose function or macro literal holds
+// `offset`, or the program's scope
+func (d *document) scopeAt(offset int) *resolver.Scope {
+	best := d.result.Scopes[d.program]
+	bestSize := len(d.text) + 1
+
+	for node, s := range d.result.Scopes {
+		if _, ok := node.(*ast.Program); ok {
+			continue
+		}
+
+		start, end := ast.Span(node)
+		if !start.IsValid() || offset < start.Offset || offset > end.Offset {
+			continue
+		}
+		if size := end.Offset - start.Offset; size < bestSize {
+			best, bestSize = s, size
+		}
+	}
+
+	return best
+}
+
+// functionSignature is `fn(a, b)` for a function literal
+func functionSignature(fn *ast.FunctionLiteral) string {
+	params := make([]string, len(fn.Parameters))
+	for i, p := range fn.Parameters {
+		params[i] = p.Value
+	}
+	return "fn(" + strings.Join(params, ", ") + ")"
+}
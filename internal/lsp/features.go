@@ -0,0 +1,274 @@
+package lsp
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/format"
+	"monkey-lang.z9fr.xyz/internal/resolver"
+)
+
+var keywords = []string{"fn", "let", "true", "false", "if", "else", "return", "macro"}
+
+// diagnostics are the parser errors, or when the document parses what the
+// resolver found. resolving half parsed code would mostly report the parser
+// errors again
+func (d *document) diagnostics() []diagnostic {
+	diags := []diagnostic{}
+
+	for _, err := range d.errors {
+		diags = append(diags, diagnostic{
+			Range:    d.wordSpan(err.Pos),
+			Severity: severityError,
+			Source:   "monkey",
+			Message:  err.Msg,
+		})
+	}
+	if len(diags) != 0 {
+		return diags
+	}
+
+	for _, diag := range d.result.Diagnostics {
+		severity := severityError
+		if diag.Severity == resolver.Warning {
+			severity = severityWarning
+		}
+		diags = append(diags, diagnostic{
+			Range:    d.wordSpan(diag.Pos),
+			Severity: severity,
+			Source:   "monkey",
+			Message:  diag.Message,
+		})
+	}
+	return diags
+}
+
+// at returns the document and the binding of the identifier under the cursor
+// of a request. both the identifier and binding are nil when there is none
+func (s *server) at(params json.RawMessage) (*document, *ast.Identifier, *resolver.Binding, error) {
+	var p textDocumentPosition
+	if err := decode(params, &p); err != nil {
+		return nil, nil, nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	ident := d.identAt(d.offset(p.Position))
+	if ident == nil {
+		return d, nil, nil, nil
+	}
+	return d, ident, d.result.Uses[ident], nil
+}
+
+func (s *server) hover(params json.RawMessage) (interface{}, error) {
+	d, ident, b, err := s.at(params)
+	if err != nil || b == nil {
+		return nil, err
+	}
+
+	var text string
+	switch b.Kind {
+	case resolver.Predeclared:
+		text = "```monkey\n" + b.Name + "\n```\npredeclared"
+	case resolver.Parameter:
+		text = "```monkey\n" + b.Name + "\n```\nparameter of `" + declSignature(b.Decl) + "`"
+	case resolver.Let:
+		text = "```monkey\n" + d.letDefinition(b.Decl.(*ast.LetStatement)) + "\n```"
+	}
+
+	return &hover{
+		Contents: markupContent{Kind: "markdown", Value: text},
+		Range:    d.identSpan(ident),
+	}, nil
+}
+
+// letDefinition is how a `let` is shown in a hover. functions only show
+// their parameters, their bodies are in the source
+func (d *document) letDefinition(let *ast.LetStatement) string {
+	if fn, ok := let.Value.(*ast.FunctionLiteral); ok {
+		return "let " + let.Name.Value + " = " + functionSignature(fn)
+	}
+
+	// the printer expects complete nodes, a document that doesn't parse shows
+	// the source as it is
+	if len(d.errors) == 0 {
+		var out bytes.Buffer
+		if err := format.Node(&out, let); err == nil {
+			return strings.TrimSpace(out.String())
+		}
+	}
+
+	start, end := ast.Span(let)
+	return d.text[start.Offset:end.Offset]
+}
+
+// declSignature is `fn(a, b)` or `macro(a, b)` for the literal a parameter
+// belongs to
+func declSignature(decl ast.Node) string {
+	switch decl := decl.(type) {
+	case *ast.FunctionLiteral:
+		return functionSignature(decl)
+	case *ast.MacroLiteral:
+		return "macro" + strings.TrimPrefix(functionSignature(&ast.FunctionLiteral{Parameters: decl.Parameters}), "fn")
+	}
+	return ""
+}
+
+func (s *server) definition(params json.RawMessage) (interface{}, error) {
+	d, _, b, err := s.at(params)
+	if err != nil || b == nil || b.Ident == nil {
+		return nil, err
+	}
+
+	return []location{{URI: d.uri, Range: d.identSpan(b.Ident)}}, nil
+}
+
+func (s *server) references(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Context struct {
+			IncludeDeclaration bool `json:"includeDeclaration"`
+		} `json:"context"`
+	}
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+
+	d, _, b, err := s.at(params)
+	if err != nil || b == nil {
+		return nil, err
+	}
+
+	locations := []location{}
+	if p.Context.IncludeDeclaration && b.Ident != nil {
+		locations = append(locations, location{URI: d.uri, Range: d.identSpan(b.Ident)})
+	}
+	for _, use := range b.Uses {
+		if use.Token.Pos.IsValid() {
+			locations = append(locations, location{URI: d.uri, Range: d.identSpan(use)})
+		}
+	}
+	return locations, nil
+}
+
+// documentSymbol lists the functions bound at the top level
+func (s *server) documentSymbol(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		TextDocument struct {
+			URI string `json:"uri"`
+		} `json:"textDocument"`
+	}
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	symbols := []documentSymbol{}
+	for _, stmt := range d.program.Statements {
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok || let.Name == nil {
+			continue
+		}
+		fn, ok := let.Value.(*ast.FunctionLiteral)
+		if !ok {
+			continue
+		}
+
+		start, end := ast.Span(let)
+		symbols = append(symbols, documentSymbol{
+			Name:           let.Name.Value,
+			Detail:         functionSignature(fn),
+			Kind:           symbolFunction,
+			Range:          d.span(start, end),
+			SelectionRange: d.identSpan(let.Name),
+		})
+	}
+	return symbols, nil
+}
+
+// completion offers the keywords and every name in scope at the cursor. the
+// editor narrows them down by what has been typed
+func (s *server) completion(params json.RawMessage) (interface{}, error) {
+	var p textDocumentPosition
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []completionItem{}
+	for _, kw := range keywords {
+		items = append(items, completionItem{Label: kw, Kind: completionKeyword})
+	}
+
+	seen := map[string]bool{}
+	for scope := d.scopeAt(d.offset(p.Position)); scope != nil; scope = scope.Parent {
+		var names []completionItem
+		for _, b := range scope.Bindings {
+			// inner scopes come first, they shadow the outer ones
+			if seen[b.Name] {
+				continue
+			}
+			seen[b.Name] = true
+			names = append(names, bindingItem(b))
+		}
+		sort.Slice(names, func(i, j int) bool { return names[i].Label < names[j].Label })
+		items = append(items, names...)
+	}
+	return items, nil
+}
+
+func bindingItem(b *resolver.Binding) completionItem {
+	item := completionItem{Label: b.Name, Kind: completionVariable}
+
+	switch b.Kind {
+	case resolver.Predeclared:
+		item.Detail = "predeclared"
+	case resolver.Parameter:
+		item.Detail = "parameter"
+	case resolver.Let:
+		if fn, ok := b.Decl.(*ast.LetStatement).Value.(*ast.FunctionLiteral); ok {
+			item.Kind = completionFunction
+			item.Detail = functionSignature(fn)
+		}
+	}
+	return item
+}
+
+// formatting replaces the whole document with `format.Source` of it
+func (s *server) formatting(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		TextDocument struct {
+			URI string `json:"uri"`
+		} `json:"textDocument"`
+	}
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	formatted, err := format.Source([]byte(d.text))
+	if err != nil {
+		return nil, err
+	}
+	if string(formatted) == d.text {
+		return []textEdit{}, nil
+	}
+
+	return []textEdit{{
+		Range:   span{Start: position{}, End: d.end()},
+		NewText: string(formatted),
+	}}, nil
+}
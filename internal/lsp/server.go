@@ -0,0 +1,228 @@
+package lsp
+
+/*
+lsp
+
+serves the Language Server Protocol, which is how editors get diagnostics,
+hovers, go to definition and the like. documents are synced whole on every
+change and analysed again: parsed, then resolved with `resolver.Resolve`,
+whose bindings answer most requests.
+
+everything happens in one goroutine, one message at a time
+*/
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Serve answers requests from `in` on `out` until the editor sends `exit` or
+// `in` runs out. `predeclared` are the names programs can use without
+// defining them, like builtins bound from Go
+func Serve(in io.Reader, out io.Writer, predeclared []string) error {
+	s := &server{
+		in:          bufio.NewReader(in),
+		out:         out,
+		predeclared: predeclared,
+		docs:        map[string]*document{},
+	}
+	return s.serve()
+}
+
+type server struct {
+	in          *bufio.Reader
+	out         io.Writer
+	predeclared []string
+
+	docs map[string]*document
+}
+
+type requestHandler func(s *server, params json.RawMessage) (interface{}, error)
+
+var requests = map[string]requestHandler{
+	"initialize":                  (*server).initialize,
+	"shutdown":                    (*server).shutdown,
+	"textDocument/hover":          (*server).hover,
+	"textDocument/definition":     (*server).definition,
+	"textDocument/references":     (*server).references,
+	"textDocument/documentSymbol": (*server).documentSymbol,
+	"textDocument/completion":     (*server).completion,
+	"textDocument/formatting":     (*server).formatting,
+}
+
+type notificationHandler func(s *server, params json.RawMessage) error
+
+var notifications = map[string]notificationHandler{
+	"textDocument/didOpen":   (*server).didOpen,
+	"textDocument/didChange": (*server).didChange,
+	"textDocument/didClose":  (*server).didClose,
+}
+
+func (s *server) serve() error {
+	for {
+		data, err := readMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var msg message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			s.send(&errorResponse{
+				JSONRPC: "2.0",
+				ID:      json.RawMessage("null"),
+				Error:   responseError{Code: codeParseError, Message: err.Error()},
+			})
+			continue
+		}
+
+		if msg.Method == "exit" {
+			return nil
+		}
+
+		// notifications get no answer, not even for mistakes
+		if len(msg.ID) == 0 {
+			if handler, ok := notifications[msg.Method]; ok {
+				handler(s, msg.Params)
+			}
+			continue
+		}
+
+		handler, ok := requests[msg.Method]
+		if !ok {
+			s.fail(msg.ID, &responseError{Code: codeMethodNotFound, Message: fmt.Sprintf("unsupported method %q", msg.Method)})
+			continue
+		}
+
+		result, err := handler(s, msg.Params)
+		if err != nil {
+			s.fail(msg.ID, err)
+			continue
+		}
+		s.send(&response{JSONRPC: "2.0", ID: msg.ID, Result: result})
+	}
+}
+
+func (s *server) send(msg interface{}) {
+	// a write error means the editor is gone, the next read says so
+	writeMessage(s.out, msg)
+}
+
+func (s *server) fail(id json.RawMessage, err error) {
+	respErr, ok := err.(*responseError)
+	if !ok {
+		respErr = &responseError{Code: codeRequestFailed, Message: err.Error()}
+	}
+	s.send(&errorResponse{JSONRPC: "2.0", ID: id, Error: *respErr})
+}
+
+func (s *server) notify(method string, params interface{}) {
+	s.send(&notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// decode reads the parameters of a request in to `v`
+func decode(params json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *server) initialize(params json.RawMessage) (interface{}, error) {
+	return map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			// whole documents on every change
+			"textDocumentSync":           1,
+			"hoverProvider":              true,
+			"definitionProvider":         true,
+			"referencesProvider":         true,
+			"documentSymbolProvider":     true,
+			"completionProvider":         map[string]interface{}{},
+			"documentFormattingProvider": true,
+		},
+		"serverInfo": map[string]string{"name": "monkey"},
+	}, nil
+}
+
+func (s *server) shutdown(params json.RawMessage) (interface{}, error) {
+	return nil, nil
+}
+
+func (s *server) didOpen(params json.RawMessage) error {
+	var p struct {
+		TextDocument struct {
+			URI  string `json:"uri"`
+			Text string `json:"text"`
+		} `json:"textDocument"`
+	}
+	if err := decode(params, &p); err != nil {
+		return err
+	}
+
+	s.update(p.TextDocument.URI, p.TextDocument.Text)
+	return nil
+}
+
+func (s *server) didChange(params json.RawMessage) error {
+	var p struct {
+		TextDocument struct {
+			URI string `json:"uri"`
+		} `json:"textDocument"`
+		ContentChanges []struct {
+			Text string `json:"text"`
+		} `json:"contentChanges"`
+	}
+	if err := decode(params, &p); err != nil {
+		return err
+	}
+	if len(p.ContentChanges) == 0 {
+		return nil
+	}
+
+	// with full sync the last change is the whole document
+	s.update(p.TextDocument.URI, p.ContentChanges[len(p.ContentChanges)-1].Text)
+	return nil
+}
+
+func (s *server) didClose(params json.RawMessage) error {
+	var p struct {
+		TextDocument struct {
+			URI string `json:"uri"`
+		} `json:"textDocument"`
+	}
+	if err := decode(params, &p); err != nil {
+		return err
+	}
+
+	delete(s.docs, p.TextDocument.URI)
+	s.notify("textDocument/publishDiagnostics", map[string]interface{}{
+		"uri":         p.TextDocument.URI,
+		"diagnostics": []diagnostic{},
+	})
+	return nil
+}
+
+// update analyses the new text of a document and publishes what is wrong
+// with it
+func (s *server) update(uri, text string) {
+	d := newDocument(uri, text, s.predeclared)
+	s.docs[uri] = d
+
+	s.notify("textDocument/publishDiagnostics", map[string]interface{}{
+		"uri":         uri,
+		"diagnostics": d.diagnostics(),
+	})
+}
+
+// document returns the open document `uri`
+func (s *server) document(uri string) (*document, error) {
+	d, ok := s.docs[uri]
+	if !ok {
+		return nil, &responseError{Code: codeRequestFailed, Message: fmt.Sprintf("%s is not open", uri)}
+	}
+	return d, nil
+}
@@ -0,0 +1,379 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+)
+
+const uri = "file:///test.mk"
+
+const source = `let add = fn(a, b) {
+  let sum = a + b;
+  sum
+};
+let two = 2;
+puts(add(two, 3));
+`
+
+// session sends `msgs` in one go and returns what the server answered. the
+// server is synchronous, so the answers come in order
+func session(t *testing.T, msgs ...interface{}) []map[string]interface{} {
+	t.Helper()
+
+	var in bytes.Buffer
+	for _, msg := range msgs {
+		if err := writeMessage(&in, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := Serve(&in, &out, []string{"puts"}); err != nil {
+		t.Fatalf("Serve: %s", err)
+	}
+
+	var replies []map[string]interface{}
+	r := bufio.NewReader(&out)
+	for {
+		data, err := readMessage(r)
+		if err == io.EOF {
+			return replies
+		}
+		if err != nil {
+			t.Fatalf("reading replies: %s", err)
+		}
+		var reply map[string]interface{}
+		if err := json.Unmarshal(data, &reply); err != nil {
+			t.Fatalf("server sent bad JSON: %s", data)
+		}
+		replies = append(replies, reply)
+	}
+}
+
+func request(id int, method string, params interface{}) map[string]interface{} {
+	return map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params}
+}
+
+func notify(method string, params interface{}) map[string]interface{} {
+	return map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params}
+}
+
+func open(text string) map[string]interface{} {
+	return notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "languageId": "monkey", "version": 1, "text": text},
+	})
+}
+
+func at(line, character int) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri},
+		"position":     map[string]interface{}{"line": line, "character": character},
+	}
+}
+
+// reply finds the answer to request `id`
+func reply(t *testing.T, replies []map[string]interface{}, id int) map[string]interface{} {
+	t.Helper()
+
+	for _, r := range replies {
+		if n, ok := r["id"].(float64); ok && int(n) == id {
+			return r
+		}
+	}
+	t.Fatalf("no reply to request %d in %v", id, replies)
+	return nil
+}
+
+// roundTrip turns `v` in to what it looks like after JSON decoding, to
+// compare it with replies
+func roundTrip(t *testing.T, v interface{}) interface{} {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func rng(line, start, endLine, end int) span {
+	return span{Start: position{line, start}, End: position{endLine, end}}
+}
+
+func TestInitialize(t *testing.T) {
+	replies := session(t,
+		request(1, "initialize", map[string]interface{}{"capabilities": map[string]interface{}{}}),
+		notify("initialized", map[string]interface{}{}),
+		request(2, "workspace/symbol", map[string]interface{}{}),
+		request(3, "shutdown", nil),
+		notify("exit", nil),
+	)
+
+	caps := reply(t, replies, 1)["result"].(map[string]interface{})["capabilities"].(map[string]interface{})
+	for _, capability := range []string{"hoverProvider", "definitionProvider", "referencesProvider", "documentSymbolProvider", "completionProvider", "documentFormattingProvider"} {
+		if caps[capability] == nil {
+			t.Errorf("%s is missing from %v", capability, caps)
+		}
+	}
+
+	e, ok := reply(t, replies, 2)["error"].(map[string]interface{})
+	if !ok || int(e["code"].(float64)) != codeMethodNotFound {
+		t.Errorf("unknown method: got %v", reply(t, replies, 2))
+	}
+
+	if len(replies) != 3 {
+		t.Errorf("got %d replies, want 3: %v", len(replies), replies)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected []diagnostic
+	}{
+		{source, []diagnostic{}},
+		{
+			"let x = 5;\nlet = 1;",
+			[]diagnostic{
+				{Range: rng(1, 4, 1, 5), Severity: severityError, Source: "monkey", Message: "expected next token to be IDENT, got = instead"},
+				{Range: rng(1, 4, 1, 5), Severity: severityError, Source: "monkey", Message: "no prefix parse function for = found"},
+			},
+		},
+		{
+			"let f = fn(x) {\n  x + y\n};\nf(1);",
+			[]diagnostic{
+				{Range: rng(1, 6, 1, 7), Severity: severityError, Source: "monkey", Message: "undefined: y"},
+			},
+		},
+		{
+			"let f = fn() {\n  let unused = 1;\n  2\n};\nf();",
+			[]diagnostic{
+				{Range: rng(1, 6, 1, 12), Severity: severityWarning, Source: "monkey", Message: "unused declared and not used"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		replies := session(t, open(tt.text))
+		if len(replies) != 1 || replies[0]["method"] != "textDocument/publishDiagnostics" {
+			t.Fatalf("%q: got %v, want diagnostics", tt.text, replies)
+		}
+
+		params := replies[0]["params"].(map[string]interface{})
+		if got, want := params["diagnostics"], roundTrip(t, tt.expected); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: wrong diagnostics.\ngot=%v\nwant=%v", tt.text, got, want)
+		}
+	}
+}
+
+func TestDidChange(t *testing.T) {
+	replies := session(t,
+		open("let x = y;"),
+		notify("textDocument/didChange", map[string]interface{}{
+			"textDocument":   map[string]interface{}{"uri": uri, "version": 2},
+			"contentChanges": []interface{}{map[string]interface{}{"text": "let y = 1;\nlet x = y;\nx;"}},
+		}),
+		notify("textDocument/didClose", map[string]interface{}{"textDocument": map[string]interface{}{"uri": uri}}),
+		request(1, "textDocument/hover", at(0, 4)),
+	)
+
+	counts := []int{}
+	for _, r := range replies {
+		if r["method"] == "textDocument/publishDiagnostics" {
+			counts = append(counts, len(r["params"].(map[string]interface{})["diagnostics"].([]interface{})))
+		}
+	}
+	if !reflect.DeepEqual(counts, []int{1, 0, 0}) {
+		t.Errorf("diagnostics after open, change and close: got %v, want [1 0 0]", counts)
+	}
+
+	if reply(t, replies, 1)["error"] == nil {
+		t.Errorf("hover on a closed document: got %v, want an error", reply(t, replies, 1))
+	}
+}
+
+func TestHover(t *testing.T) {
+	tests := []struct {
+		line, character int
+		expected        interface{}
+	}{
+		// `add` where it is called
+		{5, 6, "```monkey\nlet add = fn(a, b)\n```"},
+		// `two`, just after it
+		{5, 12, "```monkey\nlet two = 2\n```"},
+		{1, 12, "```monkey\na\n```\nparameter of `fn(a, b)`"},
+		{5, 1, "```monkey\nputs\n```\npredeclared"},
+		// on `let`, not a name
+		{0, 1, nil},
+	}
+
+	for _, tt := range tests {
+		replies := session(t, open(source), request(1, "textDocument/hover", at(tt.line, tt.character)))
+		result := reply(t, replies, 1)["result"]
+
+		if tt.expected == nil {
+			if result != nil {
+				t.Errorf("%d:%d: got %v, want no hover", tt.line, tt.character, result)
+			}
+			continue
+		}
+
+		h, ok := result.(map[string]interface{})
+		if !ok {
+			t.Errorf("%d:%d: got %v, want a hover", tt.line, tt.character, result)
+			continue
+		}
+		if got := h["contents"].(map[string]interface{})["value"]; got != tt.expected {
+			t.Errorf("%d:%d: got %q, want %q", tt.line, tt.character, got, tt.expected)
+		}
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	tests := []struct {
+		line, character int
+		expected        interface{}
+	}{
+		{5, 5, []location{{URI: uri, Range: rng(0, 4, 0, 7)}}},
+		{2, 3, []location{{URI: uri, Range: rng(1, 6, 1, 9)}}},
+		{1, 16, []location{{URI: uri, Range: rng(0, 16, 0, 17)}}},
+		// predeclared names are not in the document
+		{5, 0, nil},
+	}
+
+	for _, tt := range tests {
+		replies := session(t, open(source), request(1, "textDocument/definition", at(tt.line, tt.character)))
+		got := reply(t, replies, 1)["result"]
+		if want := roundTrip(t, tt.expected); !reflect.DeepEqual(got, want) {
+			t.Errorf("%d:%d: got %v, want %v", tt.line, tt.character, got, want)
+		}
+	}
+}
+
+func TestReferences(t *testing.T) {
+	params := at(0, 5)
+	params["context"] = map[string]interface{}{"includeDeclaration": true}
+	without := at(1, 12)
+	without["context"] = map[string]interface{}{"includeDeclaration": false}
+
+	replies := session(t,
+		open(source),
+		request(1, "textDocument/references", params),
+		request(2, "textDocument/references", without),
+	)
+
+	tests := []struct {
+		id       int
+		expected []location
+	}{
+		{1, []location{{URI: uri, Range: rng(0, 4, 0, 7)}, {URI: uri, Range: rng(5, 5, 5, 8)}}},
+		{2, []location{{URI: uri, Range: rng(1, 12, 1, 13)}}},
+	}
+
+	for _, tt := range tests {
+		got := reply(t, replies, tt.id)["result"]
+		if want := roundTrip(t, tt.expected); !reflect.DeepEqual(got, want) {
+			t.Errorf("request %d: got %v, want %v", tt.id, got, want)
+		}
+	}
+}
+
+func TestDocumentSymbol(t *testing.T) {
+	replies := session(t,
+		open(source),
+		request(1, "textDocument/documentSymbol", map[string]interface{}{"textDocument": map[string]interface{}{"uri": uri}}),
+	)
+
+	expected := []documentSymbol{
+		{Name: "add", Detail: "fn(a, b)", Kind: symbolFunction, Range: rng(0, 0, 3, 1), SelectionRange: rng(0, 4, 0, 7)},
+	}
+	if got, want := reply(t, replies, 1)["result"], roundTrip(t, expected); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	tests := []struct {
+		line, character int
+		expected        []string
+	}{
+		// in the body of `add`
+		{2, 2, []string{"a", "b", "sum", "add", "two", "puts", "quote", "unquote"}},
+		{5, 0, []string{"add", "two", "puts", "quote", "unquote"}},
+	}
+
+	for _, tt := range tests {
+		replies := session(t, open(source), request(1, "textDocument/completion", at(tt.line, tt.character)))
+
+		var got []string
+		for _, item := range reply(t, replies, 1)["result"].([]interface{}) {
+			item := item.(map[string]interface{})
+			if int(item["kind"].(float64)) != completionKeyword {
+				got = append(got, item["label"].(string))
+			}
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%d:%d: got %v, want %v", tt.line, tt.character, got, tt.expected)
+		}
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	params := map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri},
+		"options":      map[string]interface{}{"tabSize": 2, "insertSpaces": true},
+	}
+
+	replies := session(t, open("let x=1;\nputs(x)"), request(1, "textDocument/formatting", params))
+	expected := []textEdit{{Range: rng(0, 0, 1, 7), NewText: "let x = 1;\nputs(x);\n"}}
+	if got, want := reply(t, replies, 1)["result"], roundTrip(t, expected); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	replies = session(t, open("let x = ;"), request(1, "textDocument/formatting", params))
+	if reply(t, replies, 1)["error"] == nil {
+		t.Errorf("formatting code that doesn't parse: got %v, want an error", reply(t, replies, 1))
+	}
+}
+
+// editors send whatever is in the buffer, half typed and broken sources
+// included. every request still gets an answer
+func TestBrokenSources(t *testing.T) {
+	texts := []string{
+		"add((z, 1)",
+		"let = fn(, b) { a +",
+		"fn(x, { x }(",
+		"if (x) { 1 } else {",
+		"let f = fn(a) { a.b.(c) };\nf(1)(2",
+		"}}}{{{ ))) let let ;;",
+		"puts(\"open",
+	}
+	// and every prefix of a working one, as if it was being typed
+	for i := 0; i < len(source); i++ {
+		texts = append(texts, source[:i])
+	}
+
+	for _, text := range texts {
+		replies := session(t,
+			open(text),
+			request(1, "textDocument/hover", at(0, 4)),
+			request(2, "textDocument/definition", at(0, 1)),
+			request(3, "textDocument/references", at(0, 4)),
+			request(4, "textDocument/documentSymbol", map[string]interface{}{"textDocument": map[string]interface{}{"uri": uri}}),
+			request(5, "textDocument/completion", at(0, 2)),
+			request(6, "textDocument/formatting", map[string]interface{}{"textDocument": map[string]interface{}{"uri": uri}}),
+		)
+
+		if len(replies) != 7 {
+			t.Errorf("%q: got %d replies, want diagnostics and 6 answers: %v", text, len(replies), replies)
+		}
+	}
+}
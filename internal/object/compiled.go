@@ -0,0 +1,37 @@
+package object
+
+import (
+	"fmt"
+
+	"monkey-lang.z9fr.xyz/internal/code"
+)
+
+const COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
+
+// `CompiledFunction` is a function literal after the compiler is done with it.
+// it lives in the constant pool, `OpClosure` turns it in to a `Closure`
+type CompiledFunction struct {
+	Instructions code.Instructions
+	// the names of the function's slots and the slot of each parameter, as
+	// laid out by `resolver.Annotate`
+	Locals     []string
+	Parameters []int
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+// `Closure` is a compiled function together with the environment it was
+// created in, the same environment a tree walked `Function` keeps. to monkey
+// code it is a FUNCTION like the other one, error messages name it that way
+type Closure struct {
+	Fn  *CompiledFunction
+	Env *Environment
+}
+
+func (c *Closure) Type() ObjectType { return FUNCTION_OBJ }
+func (c *Closure) Inspect() string {
+	return fmt.Sprintf("Closure[%p]", c)
+}
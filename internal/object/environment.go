@@ -1,16 +1,41 @@
 package object
 
+import "sort"
+
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
+	if outer != nil {
+		env.hooks = outer.hooks
+	}
+	return env
+}
+
+// NewFrame returns the environment for a call to a function whose locals have
+// slots. `names` are the names of the slots, it is shared by every call so the
+// only allocations are the environment and its slots
+func NewFrame(outer *Environment, names []string) *Environment {
+	env := &Environment{
+		names: names,
+		slots: make([]Object, len(names)),
+		outer: outer,
+	}
+	if outer != nil {
+		env.hooks = outer.hooks
+	}
 	return env
 }
 
 type Environment struct {
 	store map[string]Object
+	// a frame keeps its bindings in `slots` instead of `store`. `names[i]` is
+	// the name of `slots[i]`, a nil slot has not been bound yet
+	names []string
+	slots []Object
 	// we are adding a new field called `outer` this contains a reference to another
 	// `object.Environment` which is the enclosing env, the only one its extending
 	outer *Environment
+	hooks Hooks
 }
 
 func NewEnvironment() *Environment {
@@ -21,6 +46,12 @@ func NewEnvironment() *Environment {
 func (e *Environment) Get(name string) (Object, bool) {
 	obj, ok := e.store[name]
 
+	if !ok {
+		if i := e.slotIndex(name); i >= 0 && e.slots[i] != nil {
+			obj, ok = e.slots[i], true
+		}
+	}
+
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.Get(name)
 	}
@@ -29,6 +60,78 @@ func (e *Environment) Get(name string) (Object, bool) {
 }
 
 func (e *Environment) Set(name string, val Object) Object {
+	if i := e.slotIndex(name); i >= 0 {
+		e.slots[i] = val
+		return val
+	}
+
+	if e.store == nil {
+		e.store = make(map[string]Object)
+	}
 	e.store[name] = val
 	return val
 }
+
+// Slot returns the value in slot `slot` of the frame `depth` levels out. it
+// reports false when there is no such frame or the slot is not bound yet
+func (e *Environment) Slot(depth, slot int) (Object, bool) {
+	for ; depth > 0 && e != nil; depth-- {
+		e = e.outer
+	}
+
+	if e == nil || slot >= len(e.slots) || e.slots[slot] == nil {
+		return nil, false
+	}
+
+	return e.slots[slot], true
+}
+
+// SlotName returns the name of slot `slot` in the frame `depth` levels out, or
+// "" when there is no such slot
+func (e *Environment) SlotName(depth, slot int) string {
+	for ; depth > 0 && e != nil; depth-- {
+		e = e.outer
+	}
+
+	if e == nil || slot >= len(e.names) {
+		return ""
+	}
+
+	return e.names[slot]
+}
+
+// SetSlot binds slot `slot` of this frame
+func (e *Environment) SetSlot(slot int, val Object) Object {
+	e.slots[slot] = val
+	return val
+}
+
+func (e *Environment) slotIndex(name string) int {
+	for i, n := range e.names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Names returns the names bound directly in this environment, sorted. bindings
+// from `outer` are not included
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.store)+len(e.slots))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	for i, name := range e.names {
+		if e.slots[i] != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Outer returns the enclosing environment, or nil for the top level one
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
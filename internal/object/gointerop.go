@@ -0,0 +1,82 @@
+package object
+
+import (
+	"fmt"
+	"reflect"
+)
+
+const (
+	GO_FUNCTION_OBJ = "GO_FUNCTION"
+	GO_STRUCT_OBJ   = "GO_STRUCT"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// `GoFunction` wraps a plain Go function so it can be called from monkey code.
+// arguments and results are converted with reflection when the function is applied
+type GoFunction struct {
+	Name string
+	Fn   reflect.Value
+}
+
+// NewGoFunction checks that `fn` is a function whose results we know how to hand
+// back to monkey: nothing, a single value, an `error`, or a value followed by an `error`.
+// nil functions are rejected, calling one would panic
+func NewGoFunction(name string, fn interface{}) (*GoFunction, error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		return nil, fmt.Errorf("%s is not a function, got %T", name, fn)
+	}
+	if v.IsNil() {
+		return nil, fmt.Errorf("%s is a nil function", name)
+	}
+
+	t := v.Type()
+	switch t.NumOut() {
+	case 0, 1:
+	case 2:
+		if t.Out(1) != errorType {
+			return nil, fmt.Errorf("%s: second result must be an error, got %s", name, t.Out(1))
+		}
+	default:
+		return nil, fmt.Errorf("%s: too many results (%d)", name, t.NumOut())
+	}
+
+	return &GoFunction{Name: name, Fn: v}, nil
+}
+
+func (gf *GoFunction) Type() ObjectType { return GO_FUNCTION_OBJ }
+func (gf *GoFunction) Inspect() string {
+	return fmt.Sprintf("go:%s %s", gf.Name, gf.Fn.Type())
+}
+
+// `GoStruct` exposes a Go struct (or a pointer to one) as a read-only object.
+// exported fields and methods can be read with `value.Field`
+type GoStruct struct {
+	Value reflect.Value
+}
+
+func (gs *GoStruct) Type() ObjectType { return GO_STRUCT_OBJ }
+func (gs *GoStruct) Inspect() string  { return fmt.Sprintf("%+v", gs.Value.Interface()) }
+
+// Field looks up an exported field or method by name. methods are bound to the
+// struct value so they can be called like any other function
+func (gs *GoStruct) Field(name string) (reflect.Value, bool) {
+	if m := gs.Value.MethodByName(name); m.IsValid() {
+		return m, true
+	}
+
+	v := reflect.Indirect(gs.Value)
+	sf, ok := v.Type().FieldByName(name)
+	if !ok || !sf.IsExported() {
+		return reflect.Value{}, false
+	}
+
+	fv, err := v.FieldByIndexErr(sf.Index)
+	if err != nil {
+		// promoted through a nil embedded pointer
+		return reflect.Value{}, false
+	}
+
+	return fv, true
+}
@@ -0,0 +1,36 @@
+package object
+
+import "monkey-lang.z9fr.xyz/internal/ast"
+
+// Hooks are told about the statements and calls of a program while the
+// evaluator runs it, a debugger uses them to stop the program and look around.
+// environments made inside an environment with hooks get the same hooks, so
+// setting them on the top level environment covers the whole program
+type Hooks interface {
+	// BeforeStatement is called with every statement that is about to run,
+	// and the environment it runs in
+	BeforeStatement(stmt ast.Statement, env *Environment)
+	// AfterStatement is called once the statement is done
+	AfterStatement(stmt ast.Statement, env *Environment)
+	// BeforeCall is called when `fn` is about to be applied to `args`. `tail`
+	// is set for a call in tail position, it takes the place of the call that
+	// made it so that call gets no `AfterCall` of its own
+	BeforeCall(call *ast.CallExpression, fn Object, args []Object, tail bool)
+	// AfterCall is called with the value of a call once it, and every tail
+	// call that took its place, returned
+	AfterCall(call *ast.CallExpression, result Object)
+	// Branch is called once the condition of `ie` decided which way it goes.
+	// `consequence` is false for the alternative, also when `ie` has none
+	Branch(ie *ast.IfExpression, consequence bool)
+}
+
+// SetHooks sets the hooks of this environment, nil removes them. environments
+// that already exist inside it keep what they had
+func (e *Environment) SetHooks(h Hooks) {
+	e.hooks = h
+}
+
+// Hooks returns the hooks of this environment, or nil
+func (e *Environment) Hooks() Hooks {
+	return e.hooks
+}
@@ -0,0 +1,310 @@
+package optimizer
+
+/*
+optimizer
+
+rewrites a program in to one that gives the same results with less work:
+
+  - infix and prefix expressions on literals are folded, `2 * 3 + 1` becomes
+    `7` and `!true` becomes `false`
+  - an `if` whose condition is a literal keeps only the branch that runs
+
+anything that would be a runtime error, like `1 / 0` or `-true`, is left alone
+so it is still reported when the program runs. the arguments of `quote` are
+data and are not touched, and neither are macro literals. run it after macro
+expansion, before `resolver.Annotate`
+*/
+
+import (
+	"strconv"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/token"
+)
+
+// Optimize rewrites `program` in place and returns it
+func Optimize(program *ast.Program) *ast.Program {
+	program.Statements = statements(program.Statements)
+	return program
+}
+
+func statements(list []ast.Statement) []ast.Statement {
+	out := make([]ast.Statement, 0, len(list))
+
+	for i, s := range list {
+		s = statement(s)
+
+		// an `if` that always takes the same branch is replaced by the
+		// statements of that branch, if-blocks don't have a scope of their own.
+		// the last statement gives the value of the list though, an empty
+		// branch there has to stay to give `null`
+		if branch, ok := constantBranch(s); ok && (i != len(list)-1 || len(branch) != 0) {
+			out = append(out, branch...)
+			continue
+		}
+
+		out = append(out, s)
+	}
+
+	return out
+}
+
+func statement(s ast.Statement) ast.Statement {
+	switch s := s.(type) {
+	case *ast.ExpressionStatement:
+		s.Expression = expression(s.Expression)
+	case *ast.LetStatement:
+		s.Value = expression(s.Value)
+	case *ast.ReturnStatement:
+		s.ReturnValue = expression(s.ReturnValue)
+	case *ast.BlockStatement:
+		block(s)
+	}
+
+	return s
+}
+
+func block(b *ast.BlockStatement) *ast.BlockStatement {
+	if b != nil {
+		b.Statements = statements(b.Statements)
+	}
+	return b
+}
+
+func expression(e ast.Expression) ast.Expression {
+	switch e := e.(type) {
+	case *ast.PrefixExpression:
+		e.Right = expression(e.Right)
+		return foldPrefix(e)
+
+	case *ast.InfixExpression:
+		e.Left = expression(e.Left)
+		e.Right = expression(e.Right)
+		return foldInfix(e)
+
+	case *ast.IfExpression:
+		e.Condition = expression(e.Condition)
+		e.Consequence = block(e.Consequence)
+		e.Alternative = block(e.Alternative)
+		return eliminateBranch(e)
+
+	case *ast.FunctionLiteral:
+		block(e.Body)
+
+	case *ast.CallExpression:
+		// same test the evaluator uses to find `quote`
+		if e.Function.TokenLiteral() == "quote" {
+			return e
+		}
+		e.Function = expression(e.Function)
+		for i, arg := range e.Arguments {
+			e.Arguments[i] = expression(arg)
+		}
+
+	case *ast.FieldExpression:
+		e.Object = expression(e.Object)
+	}
+
+	return e
+}
+
+func foldPrefix(e *ast.PrefixExpression) ast.Expression {
+	pos := e.Token.Pos
+
+	switch e.Operator {
+	case "!":
+		// the evaluator's `!` is false for everything but `false` and `null`
+		switch right := e.Right.(type) {
+		case *ast.Boolean:
+			return boolean(!right.Value, pos)
+		case *ast.IntegerLiteral, *ast.StringLiteral:
+			return boolean(false, pos)
+		}
+	case "-":
+		if right, ok := e.Right.(*ast.IntegerLiteral); ok {
+			return integer(-right.Value, pos)
+		}
+	}
+
+	return e
+}
+
+func foldInfix(e *ast.InfixExpression) ast.Expression {
+	pos, ok := literalPos(e.Left)
+	if _, isLit := literalPos(e.Right); !ok || !isLit {
+		return e
+	}
+
+	switch left := e.Left.(type) {
+	case *ast.IntegerLiteral:
+		if right, ok := e.Right.(*ast.IntegerLiteral); ok {
+			return foldIntegers(e, left.Value, right.Value, pos)
+		}
+	case *ast.StringLiteral:
+		if right, ok := e.Right.(*ast.StringLiteral); ok {
+			switch e.Operator {
+			case "+":
+				return str(left.Value+right.Value, pos)
+			case "==":
+				return boolean(left.Value == right.Value, pos)
+			case "!=":
+				return boolean(left.Value != right.Value, pos)
+			}
+			return e
+		}
+	}
+
+	// the evaluator compares anything else by identity. booleans are shared,
+	// so they are equal when their values are. values of different types are
+	// never equal
+	switch e.Operator {
+	case "==", "!=":
+		equal := false
+		if left, ok := e.Left.(*ast.Boolean); ok {
+			right, ok := e.Right.(*ast.Boolean)
+			equal = ok && left.Value == right.Value
+		}
+		return boolean(equal == (e.Operator == "=="), pos)
+	}
+
+	return e
+}
+
+func foldIntegers(e *ast.InfixExpression, left, right int64, pos token.Position) ast.Expression {
+	switch e.Operator {
+	case "+":
+		return integer(left+right, pos)
+	case "-":
+		return integer(left-right, pos)
+	case "*":
+		return integer(left*right, pos)
+	case "/":
+		// dividing by zero has to fail when the program runs
+		if right == 0 {
+			return e
+		}
+		return integer(left/right, pos)
+	case "<":
+		return boolean(left < right, pos)
+	case ">":
+		return boolean(left > right, pos)
+	case "==":
+		return boolean(left == right, pos)
+	case "!=":
+		return boolean(left != right, pos)
+	}
+
+	return e
+}
+
+// eliminateBranch drops the branch of `e` that never runs. when the other one
+// is a single expression that expression takes the place of the `if`.
+// otherwise what is left is an `if (true)` with only that branch, or an
+// `if (false)` with an empty one, which `statements` can take apart
+func eliminateBranch(e *ast.IfExpression) ast.Expression {
+	truthy, ok := isTruthy(e.Condition)
+	if !ok {
+		return e
+	}
+
+	taken := e.Consequence
+	if !truthy {
+		taken = e.Alternative
+	}
+
+	if taken == nil {
+		// the value is `null`, which has no literal
+		e.Consequence = &ast.BlockStatement{
+			Token:      e.Consequence.Token,
+			Statements: []ast.Statement{},
+			Rbrace:     e.Consequence.Rbrace,
+		}
+		e.Alternative = nil
+		return e
+	}
+
+	if len(taken.Statements) == 1 {
+		if s, ok := taken.Statements[0].(*ast.ExpressionStatement); ok && s.Expression != nil {
+			return s.Expression
+		}
+	}
+
+	pos, _ := literalPos(e.Condition)
+	e.Condition = boolean(true, pos)
+	e.Consequence = taken
+	e.Alternative = nil
+	return e
+}
+
+// constantBranch gives the statements that run in place of `s` when it is an
+// `if` with a literal condition
+func constantBranch(s ast.Statement) ([]ast.Statement, bool) {
+	es, ok := s.(*ast.ExpressionStatement)
+	if !ok {
+		return nil, false
+	}
+	e, ok := es.Expression.(*ast.IfExpression)
+	if !ok {
+		return nil, false
+	}
+
+	truthy, ok := isTruthy(e.Condition)
+	if !ok {
+		return nil, false
+	}
+
+	if truthy {
+		return e.Consequence.Statements, true
+	}
+	if e.Alternative != nil {
+		return e.Alternative.Statements, true
+	}
+	return nil, true
+}
+
+// isTruthy follows `evaluator.isTruthy` for literals, `ok` is false for
+// anything else
+func isTruthy(e ast.Expression) (truthy, ok bool) {
+	switch e := e.(type) {
+	case *ast.Boolean:
+		return e.Value, true
+	case *ast.IntegerLiteral, *ast.StringLiteral:
+		return true, true
+	}
+	return false, false
+}
+
+// literalPos gives where `e` is when it is a literal
+func literalPos(e ast.Expression) (token.Position, bool) {
+	switch e := e.(type) {
+	case *ast.IntegerLiteral:
+		return e.Token.Pos, true
+	case *ast.StringLiteral:
+		return e.Token.Pos, true
+	case *ast.Boolean:
+		return e.Token.Pos, true
+	}
+	return token.Position{}, false
+}
+
+func integer(value int64, pos token.Position) *ast.IntegerLiteral {
+	return &ast.IntegerLiteral{
+		Token: token.Token{Type: token.INT, Literal: strconv.FormatInt(value, 10), Pos: pos},
+		Value: value,
+	}
+}
+
+func str(value string, pos token.Position) *ast.StringLiteral {
+	return &ast.StringLiteral{
+		Token: token.Token{Type: token.STRING, Literal: value, Pos: pos},
+		Value: value,
+	}
+}
+
+func boolean(value bool, pos token.Position) *ast.Boolean {
+	tok := token.Token{Type: token.FALSE, Literal: "false", Pos: pos}
+	if value {
+		tok = token.Token{Type: token.TRUE, Literal: "true", Pos: pos}
+	}
+	return &ast.Boolean{Token: tok, Value: value}
+}
@@ -0,0 +1,165 @@
+package optimizer
+
+import (
+	"testing"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/conformance"
+	"monkey-lang.z9fr.xyz/internal/evaluator"
+	"monkey-lang.z9fr.xyz/internal/lexer"
+	"monkey-lang.z9fr.xyz/internal/object"
+	"monkey-lang.z9fr.xyz/internal/parser"
+	"monkey-lang.z9fr.xyz/internal/resolver"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program
+}
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2 * 3 + 1", "7"},
+		{"-5 + 10", "5"},
+		{"!true", "false"},
+		{"!!5", "true"},
+		{"!\"\"", "false"},
+		{"1 < 2 == true", "true"},
+		{"\"a\" + \"b\" == \"ab\"", "true"},
+		{"1 == true", "false"},
+		{"\"1\" != 1", "true"},
+		{"true == !false", "true"},
+		{"7 / 2", "3"},
+		{"-7 / 2", "-3"},
+		{"x + 2 * 3", "(x + 6)"},
+		{"1 + x + 2", "((1 + x) + 2)"},
+		// these fail when the program runs and have to stay
+		{"1 / 0", "(1 / 0)"},
+		{"10 / (5 - 5)", "(10 / 0)"},
+		{"-true", "(-true)"},
+		{"true + false", "(true + false)"},
+		{"\"a\" - \"b\"", "(a - b)"},
+		{"1 + \"a\"", "(1 + a)"},
+		// quoted code is data
+		{"quote(1 + 2)", "quote((1 + 2))"},
+		{"quote(unquote(1 + 2))", "quote(unquote((1 + 2)))"},
+		{"let f = fn(x) { x * (2 + 2) }", "let f = fn(x) (x * 4);"},
+		{"let m = macro(x) { 1 + 2 }", "let m = macro(x) (1 + 2);"},
+		// dead branches
+		{"if (true) { 1 } else { 2 }", "1"},
+		{"if (1 > 2) { 1 } else { 2 }", "2"},
+		{"if (0) { x }", "x"},
+		{"if (\"\") { x } else { y }", "x"},
+		{"if (false) { x }", "iffalse "},
+		{"if (x) { 1 + 1 } else { 3 - 1 }", "ifx 2else 2"},
+		{"if (false) { x }; y", "y"},
+		{"if (true) { let a = 1; a }; y", "let a = 1;ay"},
+		{"if (true) { let a = 1; a }", "let a = 1;a"},
+		{"let v = if (true) { let a = 1; a };", "let v = iftrue let a = 1;a;"},
+		{"let v = if (false) { x } else { y };", "let v = y;"},
+		{"let f = fn() { if (true) { return 1; }; 2 }", "let f = fn() return 1;2;"},
+	}
+
+	for _, tt := range tests {
+		program := Optimize(parse(t, tt.input))
+		if got := program.String(); got != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestFoldedPositions(t *testing.T) {
+	program := Optimize(parse(t, "let x = 1;\n  2 * 3 + 1"))
+
+	stmt := program.Statements[1].(*ast.ExpressionStatement)
+	lit, ok := stmt.Expression.(*ast.IntegerLiteral)
+	if !ok {
+		t.Fatalf("expression is not *ast.IntegerLiteral. got=%T", stmt.Expression)
+	}
+	if lit.Token.Pos.Line != 2 || lit.Token.Pos.Column != 3 {
+		t.Errorf("folded literal is at %d:%d, want 2:3", lit.Token.Pos.Line, lit.Token.Pos.Column)
+	}
+}
+
+func run(program *ast.Program) object.Object {
+	resolver.Annotate(program)
+	return evaluator.Eval(program, object.NewEnvironment())
+}
+
+// every program has to give the same result with and without optimizing
+func TestDifferential(t *testing.T) {
+	tests := []string{
+		"2 * 3 + 1",
+		"-(-9223372036854775807 - 1)",
+		"9223372036854775807 + 1",
+		"-9223372036854775807 - 1 / -1",
+		"1 / 0",
+		"let f = fn(n) { n / (2 - 2) }; f(1)",
+		"-true",
+		"!-5",
+		"\"a\" - \"b\"",
+		"1 + \"a\"",
+		"true == 1",
+		"\"a\" == \"a\"",
+		"true != false",
+		"if (true) {}",
+		"if (false) {}",
+		"if (1) { 10 }",
+		"if (false) { 10 }",
+		"let x = 1; if (true) {}",
+		"let x = 1; if (false) { 2 }",
+		"let x = 1; if (false) { 2 }; x",
+		"let a = 5; if (true) { let a = 10; }; a",
+		"let f = fn() { if (true) { return 1; } 2 }; f()",
+		"let f = fn() { if (false) { return 1; } 2 }; f()",
+		"let f = fn(x) { if (1 < 2) { if (x) { return 3; } } 4 }; f(true) * 10 + f(false)",
+		"if (true) { return 1 + 1; }; 5",
+		"let v = if (false) { 1 }; v",
+		"let v = if (true) { let w = 2 * 2; w }; v + w",
+		"let loop = fn(n) { if (n == 0) { 0 } else { if (true) { loop(n - 1) } } }; loop(1000)",
+		"quote(1 + 2)",
+		"let x = 3; quote(unquote(x + 2 * 2) + 1)",
+		"let f = fn(x) { fn(y) { x * (1 + 1) + y } }; f(2)(3)",
+		"if (true) { foo }",
+		"if (false) { foo } else { 1 }",
+	}
+
+	for _, input := range tests {
+		want := run(parse(t, input))
+		got := run(Optimize(parse(t, input)))
+
+		if inspect(want) != inspect(got) {
+			t.Errorf("%q: results differ. plain=%s, optimized=%s", input, inspect(want), inspect(got))
+		}
+	}
+}
+
+func inspect(obj object.Object) string {
+	if obj == nil {
+		return "<nil>"
+	}
+	return obj.Inspect()
+}
+
+func TestConformance(t *testing.T) {
+	cases, err := conformance.Cases()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range cases {
+		program := parser.New(lexer.New(c.Input)).ParseProgram()
+		if err := c.Check(run(Optimize(program))); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -18,6 +18,7 @@ const (
 	PRODUCT     // *
 	PREFIX      // -X or !X
 	CALL        // myFunction(X)”
+	FIELD       // object.field
 )
 
 var precedences = map[token.TokenType]int{
@@ -35,6 +36,7 @@ var precedences = map[token.TokenType]int{
 	token.SLASH:    PRODUCT,
 	token.ASTERISK: PRODUCT,
 	token.LPAREN:   CALL,
+	token.DOT:      FIELD,
 }
 
 type Parser struct {
@@ -43,6 +45,15 @@ type Parser struct {
 	curToken  token.Token
 	peekToken token.Token
 	errors    []string
+	// the same errors with the position of the token each one is about
+	errorList []Error
+
+	// how many of the errors were caused by running out of input rather than
+	// by a wrong token. see `Incomplete`
+	eofErrors int
+
+	// nil unless tracing was asked for with `WithTrace`
+	tracer *tracer
 
 	// in order for our parser to get correct `prefixParseFn` or `infixParseFn`
 	// for current token type we need to add two maps to the parser struct
@@ -53,12 +64,19 @@ type Parser struct {
 	infixParseFns  map[token.TokenType]infixParseFn
 }
 
-func New(l *lexer.Lexer) *Parser {
+// Option configures a `Parser`, see `WithTrace`
+type Option func(*Parser)
+
+func New(l *lexer.Lexer, opts ...Option) *Parser {
 	p := &Parser{
 		l:      l,
 		errors: []string{},
 	}
 
+	for _, opt := range opts {
+		opt(p)
+	}
+
 	// Read two tokens, so curToken and peekToken are both set
 	p.nextToken()
 	p.nextToken()
@@ -70,6 +88,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.STRING, p.parseStringLiteral)
 
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
@@ -82,6 +101,8 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	// handle functions
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	// handle macros
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
 
 	// registers one infix parse function for all our infix operators
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
@@ -96,10 +117,23 @@ func New(l *lexer.Lexer) *Parser {
 
 	// handle function calls
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
+	// handle field access on values exposed from Go
+	p.registerInfix(token.DOT, p.parseFieldExpression)
 
 	return p
 }
 
+// Precedence returns how tightly the infix operator `t` binds, or LOWEST when `t`
+// is not an infix operator. tools that print code use it to decide where
+// parentheses are needed
+func Precedence(t token.TokenType) int {
+	if p, ok := precedences[t]; ok {
+		return p
+	}
+
+	return LOWEST
+}
+
 func (p *Parser) peekPrecedence() int {
 	if p, ok := precedences[p.peekToken.Type]; ok {
 		return p
@@ -117,12 +151,36 @@ func (p *Parser) curPrecedence() int {
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
+	if p.tracer != nil {
+		defer p.untrace(p.trace("parseCallExpression"))
+	}
+
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
 	exp.Arguments = p.parseCallArguments()
+	exp.Rparen = p.curToken
+	return exp
+}
+
+func (p *Parser) parseFieldExpression(object ast.Expression) ast.Expression {
+	if p.tracer != nil {
+		defer p.untrace(p.trace("parseFieldExpression"))
+	}
+
+	exp := &ast.FieldExpression{Token: p.curToken, Object: object}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	exp.Field = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 	return exp
 }
 
 func (p *Parser) parseCallArguments() []ast.Expression {
+	if p.tracer != nil {
+		defer p.untrace(p.trace("parseCallArguments"))
+	}
+
 	args := []ast.Expression{}
 
 	if p.peekTokenIs(token.RPAREN) {
@@ -147,6 +205,10 @@ func (p *Parser) parseCallArguments() []ast.Expression {
 }
 
 func (p *Parser) parseFunctionLiteral() ast.Expression {
+	if p.tracer != nil {
+		defer p.untrace(p.trace("parseFunctionLiteral"))
+	}
+
 	lit := &ast.FunctionLiteral{Token: p.curToken}
 
 	if !p.expectPeek(token.LPAREN) {
@@ -163,7 +225,32 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return lit
 }
 
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	if p.tracer != nil {
+		defer p.untrace(p.trace("parseMacroLiteral"))
+	}
+
+	lit := &ast.MacroLiteral{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	lit.Parameters = p.parseFunctionParameters()
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	lit.Body = p.parseBlockStatement()
+	return lit
+}
+
 func (p *Parser) parseFunctionParameters() []*ast.Identifier {
+	if p.tracer != nil {
+		defer p.untrace(p.trace("parseFunctionParameters"))
+	}
+
 	identifiers := []*ast.Identifier{}
 
 	if p.peekTokenIs(token.RPAREN) {
@@ -192,6 +279,10 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 }
 
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	if p.tracer != nil {
+		defer p.untrace(p.trace("parseGroupedExpression"))
+	}
+
 	p.nextToken()
 	exp := p.parseExpression(LOWEST)
 
@@ -203,6 +294,10 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 }
 
 func (p *Parser) parseIfExpression() ast.Expression {
+	if p.tracer != nil {
+		defer p.untrace(p.trace("parseIfExpression"))
+	}
+
 	expression := &ast.IfExpression{Token: p.curToken}
 
 	if !p.expectPeek(token.LPAREN) {
@@ -238,6 +333,10 @@ func (p *Parser) parseIfExpression() ast.Expression {
 }
 
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
+	if p.tracer != nil {
+		defer p.untrace(p.trace("parseBlockStatement"))
+	}
+
 	// calls `parseStatement` untill it enconters either a `}` or `token.EOF`
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
@@ -252,11 +351,20 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		p.nextToken()
 	}
 
+	if p.curTokenIs(token.EOF) {
+		p.eofErrors++
+		p.addError(p.curToken.Pos, "expected } to close block, got EOF instead")
+	}
+
+	block.Rbrace = p.curToken
 	return block
 }
 
 func (p *Parser) parsePrefixExpression() ast.Expression {
-	// defer untrace(trace("parsePrefixExpression"))
+	if p.tracer != nil {
+		defer p.untrace(p.trace("parsePrefixExpression"))
+	}
+
 	// builds an `AST` node, in this case `*ast.PrefixExpression`. but then it advances
 	// our tokens by calling `p.nextToken()`
 	//
@@ -276,7 +384,10 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 }
 
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
-	// defer untrace(trace("parseInfixExpression"))
+	if p.tracer != nil {
+		defer p.untrace(p.trace("parseInfixExpression"))
+	}
+
 	expression := &ast.InfixExpression{
 		Token:    p.curToken,
 		Operator: p.curToken.Literal,
@@ -291,13 +402,16 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 }
 
 func (p *Parser) parseIntegerLiteral() ast.Expression {
-	// defer untrace(trace("parseIntegerLiteral"))
+	if p.tracer != nil {
+		defer p.untrace(p.trace("parseIntegerLiteral"))
+	}
+
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(p.curToken.Pos, msg)
 		return nil
 	}
 
@@ -305,11 +419,27 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return lit
 }
 
+func (p *Parser) parseStringLiteral() ast.Expression {
+	if p.tracer != nil {
+		defer p.untrace(p.trace("parseStringLiteral"))
+	}
+
+	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
+	if p.tracer != nil {
+		defer p.untrace(p.trace("parseBoolean"))
+	}
+
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
+	if p.tracer != nil {
+		defer p.untrace(p.trace("parseIdentifier"))
+	}
+
 	// `parseIdentifier` only returns `ast.Expression` with current token in
 	// `Token` field. and literal value of the token in `Value`
 	//
@@ -326,6 +456,35 @@ func (p *Parser) Errors() []string {
 	return p.errors
 }
 
+// Error is a parser error and the position of the token it is about
+type Error struct {
+	Pos token.Position
+	Msg string
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+// ErrorList returns the same errors as `Errors`, with their positions
+func (p *Parser) ErrorList() []Error {
+	return p.errorList
+}
+
+func (p *Parser) addError(pos token.Position, msg string) {
+	p.errors = append(p.errors, msg)
+	p.errorList = append(p.errorList, Error{Pos: pos, Msg: msg})
+}
+
+// Incomplete reports whether parsing failed only because the input ended too
+// early, e.g. an unclosed `{` or `(` or a trailing operator. more input might
+// still turn it in to a valid program, which is how the REPL decides to keep
+// reading. input with a wrong token before the end never parses, whatever
+// follows it
+func (p *Parser) Incomplete() bool {
+	return p.eofErrors > 0 && p.eofErrors == len(p.errors)
+}
+
 type (
 	prefixParseFn func() ast.Expression               // `prefixParseFns` gets called when we encounter the associated token type in prefix position
 	infixParseFn  func(ast.Expression) ast.Expression // `infixParseFn` gets called when we encounter the token type in infix position
@@ -362,18 +521,34 @@ func (p *Parser) ParseProgram() *ast.Program {
 }
 
 func (p *Parser) parseStatement() ast.Statement {
+	if p.tracer != nil {
+		defer p.untrace(p.trace("parseStatement"))
+	}
+
+	// a statement that failed to parse comes back as a nil pointer, which has
+	// to be a nil `ast.Statement` or it ends up in the program
 	switch p.curToken.Type {
 	case token.LET:
-		return p.parseLetStatement()
+		if stmt := p.parseLetStatement(); stmt != nil {
+			return stmt
+		}
 	case token.RETURN:
-		return p.parseReturnStatement()
+		if stmt := p.parseReturnStatement(); stmt != nil {
+			return stmt
+		}
 	default:
-		return p.parseExpressionStatement()
+		if stmt := p.parseExpressionStatement(); stmt != nil {
+			return stmt
+		}
 	}
+	return nil
 }
 
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
-	// defer untrace(trace("parseExpressionStatement"))
+	if p.tracer != nil {
+		defer p.untrace(p.trace("parseExpressionStatement"))
+	}
+
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 
 	stmt.Expression = p.parseExpression(LOWEST)
@@ -386,12 +561,18 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
+	if t == token.EOF {
+		p.eofErrors++
+	}
 	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.addError(p.curToken.Pos, msg)
 }
 
 func (p *Parser) parseExpression(precedence int) ast.Expression {
-	// defer untrace(trace("parseExpression"))
+	if p.tracer != nil {
+		defer p.untrace(p.trace("parseExpression"))
+	}
+
 	// take the register prefix parser functions we register then when `New()`
 	// is called and we call that parser function
 	prefix := p.prefixParseFns[p.curToken.Type]
@@ -420,6 +601,10 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 }
 
 func (p *Parser) parseLetStatement() *ast.LetStatement {
+	if p.tracer != nil {
+		defer p.untrace(p.trace("parseLetStatement"))
+	}
+
 	stmt := &ast.LetStatement{Token: p.curToken}
 
 	if !p.expectPeek(token.IDENT) {
@@ -436,7 +621,7 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	stmt.Value = p.parseExpression(LOWEST)
 
-	for !p.curTokenIs(token.SEMICOLON) {
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
@@ -444,13 +629,17 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 }
 
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
+	if p.tracer != nil {
+		defer p.untrace(p.trace("parseReturnStatement"))
+	}
+
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 
 	p.nextToken()
 
 	stmt.ReturnValue = p.parseExpression(LOWEST)
 
-	for !p.curTokenIs(token.SEMICOLON) {
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
@@ -466,9 +655,13 @@ func (p *Parser) peekTokenIs(t token.TokenType) bool {
 }
 
 func (p *Parser) peekError(t token.TokenType) {
+	if p.peekTokenIs(token.EOF) {
+		p.eofErrors++
+	}
+
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead",
 		t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.addError(p.peekToken.Pos, msg)
 }
 
 func (p *Parser) expectPeek(t token.TokenType) bool {
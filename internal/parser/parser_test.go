@@ -2,6 +2,8 @@ package parser
 
 import (
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 
 	"monkey-lang.z9fr.xyz/internal/ast"
@@ -46,6 +48,94 @@ func TestCallExpressionParsing(t *testing.T) {
 	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
 }
 
+func TestStringLiteralExpression(t *testing.T) {
+	input := `"hello world";`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
+	}
+
+	if literal.Value != "hello world" {
+		t.Errorf("literal.Value not %q. got=%q", "hello world", literal.Value)
+	}
+}
+
+func TestFieldExpressionParsing(t *testing.T) {
+	input := "point.x;"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.FieldExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.FieldExpression. got=%T",
+			stmt.Expression)
+	}
+
+	if !testIdentifier(t, exp.Object, "point") {
+		return
+	}
+
+	testIdentifier(t, exp.Field, "x")
+}
+
+func TestMacroLiteralParsing(t *testing.T) {
+	input := `macro(x, y) { x + y; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("statement is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MacroLiteral. got=%T",
+			stmt.Expression)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("macro literal parameters wrong. want 2, got=%d\n",
+			len(macro.Parameters))
+	}
+
+	testLiteralExpression(t, macro.Parameters[0], "x")
+	testLiteralExpression(t, macro.Parameters[1], "y")
+
+	if len(macro.Body.Statements) != 1 {
+		t.Fatalf("macro.Body.Statements has not 1 statements. got=%d\n",
+			len(macro.Body.Statements))
+	}
+
+	bodyStmt, ok := macro.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("macro body stmt is not ast.ExpressionStatement. got=%T",
+			macro.Body.Statements[0])
+	}
+
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+}
+
 func TestFunctionParameterParsing(t *testing.T) {
 	tests := []struct {
 		input          string
@@ -286,6 +376,10 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"-a * b",
 			"((-a) * b)",
 		},
+		{
+			"a.b(c) * d.e",
+			"((a.b)(c) * (d.e))",
+		},
 		{
 			"!-a",
 			"(!(-a))",
@@ -693,6 +787,79 @@ func testLetStatement(t *testing.T, s ast.Statement, name string) bool {
 	return true
 }
 
+func TestIncompleteInput(t *testing.T) {
+	tests := []struct {
+		input      string
+		incomplete bool
+	}{
+		{"let add = fn(x, y) {", true},
+		{"let add = fn(x, y) { x + y", true},
+		{"add(1, 2", true},
+		{"1 +", true},
+		{"let x =", true},
+		{"if (x) { 1 } else", true},
+		{"(1 + 2", true},
+		{"let a = 5", false},
+		{"fn(x) { x }", false},
+		{"}", false},
+		{"let = 5;", false},
+		// a wrong token before the end, more input can't fix that
+		{"let = ; if (x {", false},
+		{"let x = 1 +; fn(", false},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if p.Incomplete() != tt.incomplete {
+			t.Errorf("%q: Incomplete() = %t, want %t (errors: %v)",
+				tt.input, p.Incomplete(), tt.incomplete, p.Errors())
+		}
+
+		if tt.incomplete && len(p.Errors()) == 0 {
+			t.Errorf("%q: incomplete input reported no errors", tt.input)
+		}
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let = 5;", []string{"1:5: expected next token to be IDENT, got = instead", "1:5: no prefix parse function for = found"}},
+		{"let x = 1;\n  let y 2;", []string{"2:9: expected next token to be =, got INT instead"}},
+		{"1 + ;", []string{"1:5: no prefix parse function for ; found"}},
+		{"99999999999999999999", []string{"1:1: could not parse \"99999999999999999999\" as integer"}},
+		{"fn() {\n", []string{"2:1: expected } to close block, got EOF instead"}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+
+		// tools keep working with what did parse, it must not hold nils
+		for i, stmt := range program.Statements {
+			if stmt == nil || reflect.ValueOf(stmt).IsNil() {
+				t.Errorf("%q: statement %d is nil", tt.input, i)
+			}
+		}
+
+		var got []string
+		for _, err := range p.ErrorList() {
+			got = append(got, err.Error())
+		}
+
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%q: wrong errors. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+		if len(p.ErrorList()) != len(p.Errors()) {
+			t.Errorf("%q: ErrorList has %d errors, Errors has %d", tt.input, len(p.ErrorList()), len(p.Errors()))
+		}
+	}
+}
+
 func checkParserErrors(t *testing.T, p *Parser) {
 	errors := p.Errors()
 	if len(errors) == 0 {
@@ -2,31 +2,46 @@ package parser
 
 import (
 	"fmt"
+	"io"
 	"strings"
 )
 
-var traceLevel int = 0
-
 const traceIdentPlaceholder string = "\t"
 
-func identLevel() string {
-	return strings.Repeat(traceIdentPlaceholder, traceLevel-1)
+// tracer belongs to a single parser, so parsers running on different goroutines
+// never share indentation or output
+type tracer struct {
+	w     io.Writer
+	level int
 }
 
-func tracePrint(fs string) {
-	fmt.Printf("%s%s\n", identLevel(), fs)
+// WithTrace makes the parser write a line to `w` every time a parse function is
+// entered or left, with the current and peek tokens at that moment:
+//
+//	BEGIN parseExpression cur=INT(1) peek=+(+)
+//		BEGIN parseIntegerLiteral cur=INT(1) peek=+(+)
+//		END parseIntegerLiteral cur=INT(1) peek=+(+)
+//
+// without this option the parse functions only pay for a nil check
+func WithTrace(w io.Writer) Option {
+	return func(p *Parser) {
+		p.tracer = &tracer{w: w}
+	}
 }
 
-func incIdent() { traceLevel = traceLevel + 1 }
-func decIdent() { traceLevel = traceLevel - 1 }
+func (p *Parser) tracePrint(event, msg string) {
+	fmt.Fprintf(p.tracer.w, "%s%s %s cur=%s(%s) peek=%s(%s)\n",
+		strings.Repeat(traceIdentPlaceholder, p.tracer.level-1), event, msg,
+		p.curToken.Type, p.curToken.Literal, p.peekToken.Type, p.peekToken.Literal)
+}
 
-func trace(msg string) string {
-	incIdent()
-	tracePrint("BEGIN " + msg)
+func (p *Parser) trace(msg string) string {
+	p.tracer.level++
+	p.tracePrint("BEGIN", msg)
 	return msg
 }
 
-func untrace(msg string) {
-	tracePrint("END " + msg)
-	decIdent()
+func (p *Parser) untrace(msg string) {
+	p.tracePrint("END", msg)
+	p.tracer.level--
 }
@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"monkey-lang.z9fr.xyz/internal/lexer"
+)
+
+func TestTrace(t *testing.T) {
+	var out bytes.Buffer
+	p := New(lexer.New("-1 + x"), WithTrace(&out))
+	p.ParseProgram()
+	checkParserErrors(t, p)
+
+	expected := `BEGIN parseStatement cur=-(-) peek=INT(1)
+	BEGIN parseExpressionStatement cur=-(-) peek=INT(1)
+		BEGIN parseExpression cur=-(-) peek=INT(1)
+			BEGIN parsePrefixExpression cur=-(-) peek=INT(1)
+				BEGIN parseExpression cur=INT(1) peek=+(+)
+					BEGIN parseIntegerLiteral cur=INT(1) peek=+(+)
+					END parseIntegerLiteral cur=INT(1) peek=+(+)
+				END parseExpression cur=INT(1) peek=+(+)
+			END parsePrefixExpression cur=INT(1) peek=+(+)
+			BEGIN parseInfixExpression cur=+(+) peek=IDENT(x)
+				BEGIN parseExpression cur=IDENT(x) peek=EOF()
+					BEGIN parseIdentifier cur=IDENT(x) peek=EOF()
+					END parseIdentifier cur=IDENT(x) peek=EOF()
+				END parseExpression cur=IDENT(x) peek=EOF()
+			END parseInfixExpression cur=IDENT(x) peek=EOF()
+		END parseExpression cur=IDENT(x) peek=EOF()
+	END parseExpressionStatement cur=IDENT(x) peek=EOF()
+END parseStatement cur=IDENT(x) peek=EOF()
+`
+
+	if out.String() != expected {
+		t.Errorf("wrong trace.\nexpected=\n%s\ngot=\n%s", expected, out.String())
+	}
+}
+
+func TestTraceIsPerParser(t *testing.T) {
+	input := "let add = fn(x, y) { if (x > y) { x } else { add(y, x) } };"
+
+	var reference bytes.Buffer
+	New(lexer.New(input), WithTrace(&reference)).ParseProgram()
+
+	// every parser keeps its own indentation, so the traces of parsers running
+	// at the same time must all match the one above
+	var wg sync.WaitGroup
+	outputs := make([]bytes.Buffer, 8)
+	for i := range outputs {
+		wg.Add(1)
+		go func(out *bytes.Buffer) {
+			defer wg.Done()
+			New(lexer.New(input), WithTrace(out)).ParseProgram()
+		}(&outputs[i])
+	}
+	wg.Wait()
+
+	for i := range outputs {
+		if outputs[i].String() != reference.String() {
+			t.Errorf("trace %d differs from the reference trace", i)
+		}
+	}
+}
+
+func TestNoTraceByDefault(t *testing.T) {
+	p := New(lexer.New("1 + 2"))
+	if p.tracer != nil {
+		t.Errorf("tracer set without WithTrace")
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		p := New(lexer.New("1"))
+		p.parseExpression(LOWEST)
+	})
+	traced := testing.AllocsPerRun(100, func() {
+		p := New(lexer.New("1"), WithTrace(&bytes.Buffer{}))
+		p.parseExpression(LOWEST)
+	})
+
+	if allocs >= traced {
+		t.Errorf("expected tracing to cost allocations only when enabled: without=%v with=%v", allocs, traced)
+	}
+}
@@ -0,0 +1,200 @@
+package profiler
+
+import (
+	"compress/gzip"
+	"io"
+	"sort"
+)
+
+// WritePprof writes what was measured as a gzipped profile in the protocol
+// buffer format of pprof, github.com/google/pprof/proto/profile.proto. every
+// path of calls is a sample, so `go tool pprof` shows the monkey functions
+// in its graphs. the sample values are the calls, the exclusive time and the
+// exclusive allocations of the last function of the path. it is for after
+// `Run`
+func (p *Profiler) WritePprof(w io.Writer) error {
+	var b protobuf
+	table := newStringTable()
+
+	// Profile.sample_type
+	for _, vt := range [][2]string{
+		{"calls", "count"},
+		{"time", "nanoseconds"},
+		{"alloc_objects", "count"},
+		{"alloc_space", "bytes"},
+	} {
+		var m protobuf
+		m.int(1, table.index(vt[0]))
+		m.int(2, table.index(vt[1]))
+		b.message(1, &m)
+	}
+
+	// Profile.sample, in a stable order
+	var visit func(n *node, stack []uint64)
+	visit = func(n *node, stack []uint64) {
+		// leaf first
+		stack = append([]uint64{n.fn.id}, stack...)
+
+		var sample protobuf
+		sample.packed(1, stack)
+		sample.packedInts(2, []int64{n.calls, int64(n.self), n.allocs, n.abytes})
+		b.message(2, &sample)
+
+		children := make([]*node, 0, len(n.children))
+		for _, c := range n.children {
+			children = append(children, c)
+		}
+		sort.Slice(children, func(i, j int) bool { return children[i].fn.id < children[j].fn.id })
+		for _, c := range children {
+			visit(c, stack)
+		}
+	}
+	visit(p.root, nil)
+
+	filename := table.index(p.filename)
+
+	// Profile.mapping, the script stands in for the binary the functions are in
+	var mapping protobuf
+	mapping.uint(1, 1)
+	mapping.int(5, filename)
+	for field := 7; field <= 9; field++ {
+		// has_functions, has_filenames, has_line_numbers
+		mapping.uint(field, 1)
+	}
+	b.message(3, &mapping)
+
+	// Profile.location, one for each function. the ids are the same
+	for _, fn := range p.order {
+		var line protobuf
+		line.uint(1, fn.id)
+		line.int(2, int64(fn.Pos.Line))
+
+		var loc protobuf
+		loc.uint(1, fn.id)
+		loc.uint(2, 1)
+		loc.message(4, &line)
+		b.message(4, &loc)
+	}
+
+	// Profile.function
+	for _, fn := range p.order {
+		// pprof takes `<...>` for C++ template arguments and drops it
+		name := fn.Name
+		if fn == p.root.fn {
+			name = "program"
+		}
+
+		var f protobuf
+		f.uint(1, fn.id)
+		f.int(2, table.index(name))
+		f.int(3, table.index(name))
+		if fn.Pos.IsValid() {
+			f.int(4, filename)
+			f.int(5, int64(fn.Pos.Line))
+		}
+		b.message(5, &f)
+	}
+
+	b.int(9, p.started.UnixNano())
+	b.int(10, int64(p.duration))
+	b.int(14, table.index("time"))
+
+	// Profile.string_table goes last, everything else added to it by now
+	for _, s := range table.list {
+		b.string(6, s)
+	}
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(b.buf); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// stringTable is the string_table of a profile, other messages refer to its
+// strings by index. the first string is always ""
+type stringTable struct {
+	list    []string
+	indexes map[string]int64
+}
+
+func newStringTable() *stringTable {
+	return &stringTable{list: []string{""}, indexes: map[string]int64{"": 0}}
+}
+
+func (t *stringTable) index(s string) int64 {
+	i, ok := t.indexes[s]
+	if !ok {
+		i = int64(len(t.list))
+		t.list = append(t.list, s)
+		t.indexes[s] = i
+	}
+	return i
+}
+
+// protobuf encodes a protocol buffer message, just the wire types a profile
+// needs
+type protobuf struct {
+	buf []byte
+}
+
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func (b *protobuf) varint(x uint64) {
+	for x >= 0x80 {
+		b.buf = append(b.buf, byte(x)|0x80)
+		x >>= 7
+	}
+	b.buf = append(b.buf, byte(x))
+}
+
+func (b *protobuf) key(field, wire int) {
+	b.varint(uint64(field)<<3 | uint64(wire))
+}
+
+// uint and int leave out zero values, like proto3 does
+func (b *protobuf) uint(field int, x uint64) {
+	if x == 0 {
+		return
+	}
+	b.key(field, wireVarint)
+	b.varint(x)
+}
+
+func (b *protobuf) int(field int, x int64) {
+	b.uint(field, uint64(x))
+}
+
+func (b *protobuf) bytes(field int, data []byte) {
+	b.key(field, wireBytes)
+	b.varint(uint64(len(data)))
+	b.buf = append(b.buf, data...)
+}
+
+// string always writes the string, even "": the string table counts on it
+func (b *protobuf) string(field int, s string) {
+	b.bytes(field, []byte(s))
+}
+
+func (b *protobuf) message(field int, m *protobuf) {
+	b.bytes(field, m.buf)
+}
+
+func (b *protobuf) packed(field int, xs []uint64) {
+	var p protobuf
+	for _, x := range xs {
+		p.varint(x)
+	}
+	b.bytes(field, p.buf)
+}
+
+func (b *protobuf) packedInts(field int, xs []int64) {
+	var p protobuf
+	for _, x := range xs {
+		p.varint(uint64(x))
+	}
+	b.bytes(field, p.buf)
+}
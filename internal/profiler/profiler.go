@@ -0,0 +1,282 @@
+package profiler
+
+/*
+profiler
+
+measures where a program spends its time while the tree walking evaluator runs
+it. the profiler puts itself in to the top level environment as its
+`object.Hooks` and keeps a stack of the calls being made, for every monkey
+function it records
+
+  - how often it was called
+  - inclusive time, from the call until it returned, and exclusive time, the
+    part that was not spent in functions it called
+  - the allocations made while it ran, not counting the functions it called
+
+functions are named by the `let` that binds them, other function literals by
+where they are defined, `fn@3:12`. Go functions by the name they were bound
+with.
+
+the calls are also kept as a tree, one node per path of calls, which is what
+`WritePprof` turns in to samples for `go tool pprof`
+*/
+
+import (
+	"fmt"
+	"runtime/metrics"
+	"sort"
+	"time"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/evaluator"
+	"monkey-lang.z9fr.xyz/internal/object"
+	"monkey-lang.z9fr.xyz/internal/token"
+)
+
+// Function is what the profiler measured for one function
+type Function struct {
+	// Name is the `let` name of the function, or `fn@line:column`
+	Name string
+	// Pos is where the function literal is, Go functions have none
+	Pos token.Position
+
+	Calls int64
+	// Inclusive counts a recursive function once, from its outermost call
+	Inclusive time.Duration
+	Exclusive time.Duration
+	// allocations on the Go heap. the runtime counts them in batches, short
+	// calls get the allocations of a whole batch or none of them. the totals
+	// add up
+	Allocs     int64
+	AllocBytes int64
+
+	id uint64
+	// calls to it that have not returned yet
+	active int
+}
+
+// node is a path of calls, from the program to `fn`
+type node struct {
+	fn       *Function
+	parent   *node
+	children map[*Function]*node
+
+	calls          int64
+	self           time.Duration
+	allocs, abytes int64
+}
+
+func (n *node) child(fn *Function) *node {
+	c, ok := n.children[fn]
+	if !ok {
+		c = &node{fn: fn, parent: n, children: map[*Function]*node{}}
+		n.children[fn] = c
+	}
+	return c
+}
+
+type frame struct {
+	node  *node
+	start time.Time
+	// what the calls made from this frame took
+	inner time.Duration
+
+	startAllocs, startBytes int64
+	innerAllocs, innerBytes int64
+}
+
+type Profiler struct {
+	filename string
+	program  *ast.Program
+
+	// function names, and where the literals are, by the body of the literal
+	names map[*ast.BlockStatement]string
+	sites map[*ast.BlockStatement]token.Position
+	// functions by the body of their literal, or by name for Go functions
+	funcs map[interface{}]*Function
+	order []*Function
+
+	root *node
+	// frames are kept by value, the profiler should allocate as little as
+	// possible itself
+	stack []frame
+
+	started  time.Time
+	duration time.Duration
+
+	// replaced in tests
+	now    func() time.Time
+	allocs func() (objects, bytes int64)
+}
+
+var _ object.Hooks = (*Profiler)(nil)
+
+// New returns a profiler for `program`, which should have been through macro
+// expansion and `resolver.Annotate` like any program about to be evaluated.
+// `filename` is where it came from
+func New(filename string, program *ast.Program) *Profiler {
+	p := &Profiler{
+		filename: filename,
+		program:  program,
+		names:    map[*ast.BlockStatement]string{},
+		sites:    map[*ast.BlockStatement]token.Position{},
+		funcs:    map[interface{}]*Function{},
+		now:      time.Now,
+		allocs:   readAllocs(),
+	}
+
+	ast.Inspect(program, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.LetStatement:
+			if fn, ok := n.Value.(*ast.FunctionLiteral); ok && n.Name != nil {
+				p.names[fn.Body] = n.Name.Value
+			}
+		case *ast.FunctionLiteral:
+			p.sites[n.Body] = n.Token.Pos
+		}
+		return true
+	})
+
+	return p
+}
+
+// readAllocs returns a function that reads how much the Go heap allocated so
+// far
+func readAllocs() func() (int64, int64) {
+	samples := []metrics.Sample{
+		{Name: "/gc/heap/allocs:objects"},
+		{Name: "/gc/heap/allocs:bytes"},
+	}
+
+	return func() (int64, int64) {
+		metrics.Read(samples)
+		return int64(samples[0].Value.Uint64()), int64(samples[1].Value.Uint64())
+	}
+}
+
+// Run evaluates the program in `env` and measures it. it returns the value of
+// the program like `evaluator.Eval`
+func (p *Profiler) Run(env *object.Environment) object.Object {
+	program := p.function("<program>", "<program>", token.Position{})
+	p.root = &node{fn: program, children: map[*Function]*node{}}
+
+	env.SetHooks(p)
+	defer env.SetHooks(nil)
+
+	p.started = p.now()
+	p.enter(p.root)
+	result := evaluator.Eval(p.program, env)
+
+	// calls stay open when the program ends with an error in them
+	for len(p.stack) > 0 {
+		p.exit()
+	}
+	p.duration = p.now().Sub(p.started)
+
+	return result
+}
+
+// Functions returns what was measured for each function that was called,
+// the most exclusive time first. the first run of the program is measured as
+// a function named `<program>`
+func (p *Profiler) Functions() []*Function {
+	funcs := make([]*Function, len(p.order))
+	copy(funcs, p.order)
+
+	sort.SliceStable(funcs, func(i, j int) bool {
+		return funcs[i].Exclusive > funcs[j].Exclusive
+	})
+	return funcs
+}
+
+// function returns the `Function` for `key`, making it the first time
+func (p *Profiler) function(key interface{}, name string, pos token.Position) *Function {
+	fn, ok := p.funcs[key]
+	if !ok {
+		fn = &Function{Name: name, Pos: pos, id: uint64(len(p.order) + 1)}
+		p.funcs[key] = fn
+		p.order = append(p.order, fn)
+	}
+	return fn
+}
+
+// called returns the `Function` for a call of `fn`
+func (p *Profiler) called(call *ast.CallExpression, fn object.Object) *Function {
+	switch fn := fn.(type) {
+	case *object.Function:
+		pos := p.sites[fn.Body]
+		if name, ok := p.names[fn.Body]; ok {
+			return p.function(fn.Body, name, pos)
+		}
+
+		// literals made by macros have no position
+		name := "fn"
+		if pos.IsValid() {
+			name = fmt.Sprintf("fn@%s", pos)
+		}
+		return p.function(fn.Body, name, pos)
+	case *object.GoFunction:
+		return p.function("go:"+fn.Name, fn.Name, token.Position{})
+	default:
+		// not a function, the call fails right away
+		name := call.Function.String()
+		return p.function("go:"+name, name, token.Position{})
+	}
+}
+
+func (p *Profiler) enter(n *node) {
+	objects, bytes := p.allocs()
+	p.stack = append(p.stack, frame{node: n, start: p.now(), startAllocs: objects, startBytes: bytes})
+
+	n.calls++
+	n.fn.Calls++
+	n.fn.active++
+}
+
+func (p *Profiler) exit() {
+	f := p.stack[len(p.stack)-1]
+	p.stack = p.stack[:len(p.stack)-1]
+
+	total := p.now().Sub(f.start)
+	objects, bytes := p.allocs()
+	objects, bytes = objects-f.startAllocs, bytes-f.startBytes
+
+	n, fn := f.node, f.node.fn
+	n.self += total - f.inner
+	n.allocs += objects - f.innerAllocs
+	n.abytes += bytes - f.innerBytes
+
+	fn.Exclusive += total - f.inner
+	fn.Allocs += objects - f.innerAllocs
+	fn.AllocBytes += bytes - f.innerBytes
+	if fn.active--; fn.active == 0 {
+		fn.Inclusive += total
+	}
+
+	if len(p.stack) > 0 {
+		caller := &p.stack[len(p.stack)-1]
+		caller.inner += total
+		caller.innerAllocs += objects
+		caller.innerBytes += bytes
+	}
+}
+
+func (p *Profiler) BeforeStatement(stmt ast.Statement, env *object.Environment) {}
+
+func (p *Profiler) AfterStatement(stmt ast.Statement, env *object.Environment) {}
+
+func (p *Profiler) Branch(ie *ast.IfExpression, consequence bool) {}
+
+func (p *Profiler) BeforeCall(call *ast.CallExpression, fn object.Object, args []object.Object, tail bool) {
+	// a tail call takes the place of the call that made it, which is done
+	if tail {
+		p.exit()
+	}
+
+	caller := p.stack[len(p.stack)-1].node
+	p.enter(caller.child(p.called(call, fn)))
+}
+
+func (p *Profiler) AfterCall(call *ast.CallExpression, result object.Object) {
+	p.exit()
+}
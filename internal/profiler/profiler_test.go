@@ -0,0 +1,210 @@
+package profiler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+
+	"monkey-lang.z9fr.xyz/internal/evaluator"
+	"monkey-lang.z9fr.xyz/internal/lexer"
+	"monkey-lang.z9fr.xyz/internal/object"
+	"monkey-lang.z9fr.xyz/internal/parser"
+	"monkey-lang.z9fr.xyz/internal/resolver"
+)
+
+const program = `let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+let loop = fn(n) { if (n == 0) { 0 } else { loop(n - 1) } };
+let makeAdder = fn(x) { fn(y) { x + y } };
+fib(5);
+loop(3);
+makeAdder(1)(2);
+len("abc");
+`
+
+// profile runs `src` under a profiler whose clock moves a millisecond and
+// whose heap allocates one object each time it is looked at
+func profile(t *testing.T, src string) *Profiler {
+	t.Helper()
+
+	p := parser.New(lexer.New(src))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	resolver.Annotate(prog)
+
+	prof := New("test.mk", prog)
+	var clock time.Time
+	prof.now = func() time.Time {
+		clock = clock.Add(time.Millisecond)
+		return clock
+	}
+	var objects int64
+	prof.allocs = func() (int64, int64) {
+		objects++
+		return objects, objects * 8
+	}
+
+	env := object.NewEnvironment()
+	evaluator.Bind(env, "len", func(s string) int { return len(s) })
+	if result := prof.Run(env); result == nil || result.Type() == object.ERROR_OBJ {
+		t.Fatalf("program failed: %v", result)
+	}
+	return prof
+}
+
+func TestFunctions(t *testing.T) {
+	prof := profile(t, program)
+
+	calls := map[string]int64{}
+	for _, fn := range prof.Functions() {
+		calls[fn.Name] = fn.Calls
+	}
+
+	expected := map[string]int64{
+		"<program>": 1,
+		"fib":       15,
+		// one call, and the tail calls that took its place
+		"loop":      4,
+		"makeAdder": 1,
+		"fn@3:25":   1,
+		"len":       1,
+	}
+	if len(calls) != len(expected) {
+		t.Errorf("got functions %v, want %v", calls, expected)
+	}
+	for name, want := range expected {
+		if calls[name] != want {
+			t.Errorf("%s: got %d calls, want %d", name, calls[name], want)
+		}
+	}
+}
+
+func TestTimes(t *testing.T) {
+	prof := profile(t, program)
+
+	var exclusive time.Duration
+	for _, fn := range prof.Functions() {
+		if fn.Exclusive <= 0 || fn.Exclusive > fn.Inclusive {
+			t.Errorf("%s: exclusive %s, inclusive %s", fn.Name, fn.Exclusive, fn.Inclusive)
+		}
+		// the clock and the heap are read in pairs and move together
+		if fn.Allocs != int64(fn.Exclusive/time.Millisecond) || fn.AllocBytes != 8*fn.Allocs {
+			t.Errorf("%s: exclusive %s, %d allocations of %d bytes", fn.Name, fn.Exclusive, fn.Allocs, fn.AllocBytes)
+		}
+		exclusive += fn.Exclusive
+	}
+
+	// every moment is in exactly one function
+	if root := prof.root.fn; root.Inclusive != exclusive {
+		t.Errorf("the program took %s, the exclusive times add up to %s", root.Inclusive, exclusive)
+	}
+
+	functions := prof.Functions()
+	for i := 1; i < len(functions); i++ {
+		if functions[i-1].Exclusive < functions[i].Exclusive {
+			t.Errorf("Functions is not sorted by exclusive time: %s before %s", functions[i-1].Name, functions[i].Name)
+		}
+	}
+}
+
+func TestRecursionInclusive(t *testing.T) {
+	prof := profile(t, "let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(3);")
+
+	for _, fn := range prof.Functions() {
+		if fn.Name != "f" {
+			continue
+		}
+		// the outermost call covers the inner ones, they are not added again
+		if fn.Inclusive > prof.root.fn.Inclusive {
+			t.Errorf("f: inclusive %s is more than the whole program, %s", fn.Inclusive, prof.root.fn.Inclusive)
+		}
+		return
+	}
+	t.Errorf("f was not profiled")
+}
+
+// field is one field of a protocol buffer message
+type field struct {
+	num   int
+	value uint64
+	bytes []byte
+}
+
+// decode splits a protocol buffer message in to its fields, it knows the wire
+// types a profile uses
+func decode(t *testing.T, data []byte) []field {
+	t.Helper()
+
+	var fields []field
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		data = data[n:]
+
+		f := field{num: int(key >> 3)}
+		switch key & 7 {
+		case wireVarint:
+			f.value, n = binary.Uvarint(data)
+			data = data[n:]
+		case wireBytes:
+			size, n := binary.Uvarint(data)
+			f.bytes, data = data[n:n+int(size)], data[n+int(size):]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+func TestWritePprof(t *testing.T) {
+	prof := profile(t, program)
+
+	var out bytes.Buffer
+	if err := prof.WritePprof(&out); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(&out)
+	if err != nil {
+		t.Fatalf("the profile is not gzipped: %s", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var strings []string
+	counts := map[int]int{}
+	for _, f := range decode(t, data) {
+		counts[f.num]++
+		if f.num == 6 {
+			strings = append(strings, string(f.bytes))
+		}
+	}
+
+	if len(strings) == 0 || strings[0] != "" {
+		t.Fatalf("the string table must start with \"\", got %q", strings)
+	}
+	for _, s := range []string{"time", "nanoseconds", "program", "fib", "fn@3:25", "len", "test.mk"} {
+		found := false
+		for _, got := range strings {
+			found = found || got == s
+		}
+		if !found {
+			t.Errorf("%q is not in the string table %q", s, strings)
+		}
+	}
+
+	// sample types, one sample for every path of calls (fib goes five deep,
+	// loop only makes tail calls), a location and a function for every
+	// function
+	expected := map[int]int{1: 4, 2: 10, 3: 1, 4: 6, 5: 6}
+	for num, want := range expected {
+		if counts[num] != want {
+			t.Errorf("field %d: got %d, want %d", num, counts[num], want)
+		}
+	}
+}
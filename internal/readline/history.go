@@ -0,0 +1,88 @@
+package readline
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxHistory is how many entries are kept, older ones are dropped
+const maxHistory = 1000
+
+// DefaultHistoryFile is where the REPL keeps its history: `monkey/history` in the
+// user's config directory
+func DefaultHistoryFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "monkey", "history"), nil
+}
+
+// LoadHistory reads history from `path` and remembers it so `AddHistory` appends
+// new entries to the same file. a missing file is not an error
+func (e *Editor) LoadHistory(path string) error {
+	e.historyFile = path
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		e.history = append(e.history, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if len(e.history) > maxHistory {
+		e.history = e.history[len(e.history)-maxHistory:]
+		// rewrite the file so it doesn't keep growing
+		return os.WriteFile(path, []byte(strings.Join(e.history, "\n")+"\n"), 0o600)
+	}
+
+	return nil
+}
+
+// AddHistory records a line. blank lines and repeats of the previous entry are
+// skipped
+func (e *Editor) AddHistory(line string) error {
+	if strings.TrimSpace(line) == "" {
+		return nil
+	}
+	if n := len(e.history); n > 0 && e.history[n-1] == line {
+		return nil
+	}
+
+	e.history = append(e.history, line)
+	if len(e.history) > maxHistory {
+		e.history = e.history[1:]
+	}
+
+	if e.historyFile == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(e.historyFile), 0o700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(e.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.WriteString(line + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,454 @@
+package readline
+
+/*
+readline
+
+a small line editor for the REPL. the terminal is switched to raw mode while a
+line is being read, so we get every key press and draw the line ourselves.
+
+supported keys:
+
+	left/right, ctrl-b/ctrl-f   move the cursor
+	home/end, ctrl-a/ctrl-e     jump to start/end of the line
+	up/down, ctrl-p/ctrl-n      walk through history
+	ctrl-r                      reverse search through history
+	tab                         complete the word before the cursor
+	backspace, delete, ctrl-d   delete a character (ctrl-d on an empty line is EOF)
+	ctrl-w, ctrl-u, ctrl-k      delete word before cursor, to start, to end
+	ctrl-l                      clear the screen
+	ctrl-c                      give up on the line
+*/
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// ErrInterrupted is returned by `ReadLine` when ctrl-c is pressed
+var ErrInterrupted = errors.New("interrupted")
+
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlG     = 7
+	keyCtrlH     = 8
+	keyTab       = 9
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlR     = 18
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyBackspace = 127
+
+	// escape sequences are mapped to values outside of the unicode range
+	keyUp rune = unicode.MaxRune + 1 + iota
+	keyDown
+	keyLeft
+	keyRight
+	keyHome
+	keyEnd
+	keyDelete
+	keyUnknown
+)
+
+type Editor struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	// file descriptor of the terminal, -1 when reading from something else
+	fd int
+
+	// Complete returns the candidates for the word that ends at the cursor.
+	// tab does nothing when it is nil
+	Complete func(word string) []string
+
+	// Highlight decorates the line before it is drawn, e.g. with colors. it must
+	// not change the visible width of the line
+	Highlight func(line string) string
+
+	history     []string
+	historyFile string
+}
+
+// New returns an editor that reads keys from `in` as they are. it does not touch
+// terminal modes, which makes it usable with plain readers
+func New(in io.Reader, out io.Writer) *Editor {
+	return &Editor{in: bufio.NewReader(in), out: out, fd: -1}
+}
+
+// NewTerminal returns an editor for an interactive terminal. it fails when `f`
+// is not a terminal, in which case callers should fall back to reading lines
+func NewTerminal(f *os.File, out io.Writer) (*Editor, error) {
+	if !IsTerminal(int(f.Fd())) {
+		return nil, fmt.Errorf("%s is not a terminal", f.Name())
+	}
+
+	e := New(f, out)
+	e.fd = int(f.Fd())
+	return e, nil
+}
+
+// ReadLine shows `prompt` and returns the line once enter is pressed. it returns
+// `io.EOF` for ctrl-d on an empty line and `ErrInterrupted` for ctrl-c
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	if e.fd >= 0 {
+		restore, err := makeRaw(e.fd)
+		if err != nil {
+			return "", err
+		}
+		defer restore()
+	}
+
+	s := &state{e: e, prompt: prompt, histIdx: len(e.history)}
+	s.refresh()
+
+	for {
+		r, err := s.readKey()
+		if err != nil {
+			if err == io.EOF && len(s.buf) > 0 {
+				// input ran out without a newline, hand back what we have
+				return string(s.buf), nil
+			}
+			return "", err
+		}
+
+		line, done, err := s.handle(r)
+		if done {
+			s.write("\r\n")
+			return line, err
+		}
+	}
+}
+
+// state is everything about the line currently being edited
+type state struct {
+	e      *Editor
+	prompt string
+	buf    []rune
+	pos    int
+
+	// history navigation. `saved` holds the line that was being typed before
+	// moving in to history so it can be restored
+	histIdx int
+	saved   []rune
+
+	// reverse search. the line from before the search is kept so cancelling
+	// can put it back
+	searching    bool
+	query        []rune
+	searchMatch  int
+	beforeSearch []rune
+}
+
+func (s *state) handle(r rune) (line string, done bool, err error) {
+	if s.searching {
+		if !s.handleSearch(r) {
+			return "", false, nil
+		}
+		// the key ended the search, fall through and treat it as a normal key
+	}
+
+	switch r {
+	case keyEnter, '\n':
+		return string(s.buf), true, nil
+	case keyCtrlC:
+		return "", true, ErrInterrupted
+	case keyCtrlD:
+		if len(s.buf) == 0 {
+			return "", true, io.EOF
+		}
+		s.delete()
+	case keyDelete:
+		s.delete()
+	case keyBackspace, keyCtrlH:
+		if s.pos > 0 {
+			s.buf = append(s.buf[:s.pos-1], s.buf[s.pos:]...)
+			s.pos--
+		}
+	case keyLeft, keyCtrlB:
+		if s.pos > 0 {
+			s.pos--
+		}
+	case keyRight, keyCtrlF:
+		if s.pos < len(s.buf) {
+			s.pos++
+		}
+	case keyHome, keyCtrlA:
+		s.pos = 0
+	case keyEnd, keyCtrlE:
+		s.pos = len(s.buf)
+	case keyUp, keyCtrlP:
+		s.historyMove(-1)
+	case keyDown, keyCtrlN:
+		s.historyMove(1)
+	case keyCtrlK:
+		s.buf = s.buf[:s.pos]
+	case keyCtrlU:
+		s.buf = s.buf[s.pos:]
+		s.pos = 0
+	case keyCtrlW:
+		start := s.pos
+		for start > 0 && s.buf[start-1] == ' ' {
+			start--
+		}
+		for start > 0 && s.buf[start-1] != ' ' {
+			start--
+		}
+		s.buf = append(s.buf[:start], s.buf[s.pos:]...)
+		s.pos = start
+	case keyCtrlL:
+		s.write("\x1b[H\x1b[2J")
+	case keyCtrlR:
+		s.searching = true
+		s.query = nil
+		s.searchMatch = len(s.e.history)
+		s.beforeSearch = s.buf
+	case keyTab:
+		s.complete()
+	case keyEscape, keyUnknown, keyCtrlG:
+	default:
+		if unicode.IsPrint(r) {
+			s.buf = append(s.buf[:s.pos], append([]rune{r}, s.buf[s.pos:]...)...)
+			s.pos++
+		}
+	}
+
+	s.refresh()
+	return "", false, nil
+}
+
+func (s *state) delete() {
+	if s.pos < len(s.buf) {
+		s.buf = append(s.buf[:s.pos], s.buf[s.pos+1:]...)
+	}
+}
+
+func (s *state) historyMove(delta int) {
+	idx := s.histIdx + delta
+	if idx < 0 || idx > len(s.e.history) {
+		return
+	}
+
+	if s.histIdx == len(s.e.history) {
+		s.saved = s.buf
+	}
+
+	s.histIdx = idx
+	if idx == len(s.e.history) {
+		s.buf = s.saved
+	} else {
+		s.buf = []rune(s.e.history[idx])
+	}
+	s.pos = len(s.buf)
+}
+
+// handleSearch processes a key while in reverse search. it returns true when the
+// key ended the search and should also be handled as a normal key
+func (s *state) handleSearch(r rune) bool {
+	switch r {
+	case keyCtrlR:
+		s.search(s.searchMatch - 1)
+	case keyBackspace, keyCtrlH:
+		if len(s.query) > 0 {
+			s.query = s.query[:len(s.query)-1]
+			s.search(len(s.e.history) - 1)
+		}
+	case keyCtrlG, keyEscape:
+		s.searching = false
+		s.buf = s.beforeSearch
+		s.pos = len(s.buf)
+	default:
+		if unicode.IsPrint(r) {
+			s.query = append(s.query, r)
+			s.search(s.searchMatch)
+			return false
+		}
+		s.searching = false
+		return true
+	}
+
+	s.refresh()
+	return false
+}
+
+// search looks backwards through history from `from` for an entry containing the
+// query and puts it in the buffer
+func (s *state) search(from int) {
+	if from >= len(s.e.history) {
+		from = len(s.e.history) - 1
+	}
+
+	for i := from; i >= 0; i-- {
+		if idx := strings.Index(s.e.history[i], string(s.query)); idx >= 0 {
+			s.searchMatch = i
+			s.buf = []rune(s.e.history[i])
+			s.pos = len([]rune(s.e.history[i][:idx]))
+			return
+		}
+	}
+}
+
+func (s *state) complete() {
+	if s.e.Complete == nil {
+		return
+	}
+
+	start := s.pos
+	for start > 0 && isWordRune(s.buf[start-1]) {
+		start--
+	}
+
+	word := string(s.buf[start:s.pos])
+	candidates := s.e.Complete(word)
+	if len(candidates) == 0 {
+		return
+	}
+
+	prefix := commonPrefix(candidates)
+	if len(prefix) > len(word) {
+		rest := []rune(prefix[len(word):])
+		s.buf = append(s.buf[:s.pos], append(rest, s.buf[s.pos:]...)...)
+		s.pos += len(rest)
+		return
+	}
+
+	if len(candidates) > 1 {
+		s.write("\r\n" + strings.Join(candidates, "  ") + "\r\n")
+	}
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, w := range words[1:] {
+		for !strings.HasPrefix(w, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+func (s *state) refresh() {
+	var b strings.Builder
+
+	prompt, line, cursor := s.prompt, string(s.buf), s.pos
+	if s.searching {
+		prompt = fmt.Sprintf("(reverse-i-search)'%s': ", string(s.query))
+	}
+	if s.e.Highlight != nil {
+		line = s.e.Highlight(line)
+	}
+
+	b.WriteString("\r")
+	b.WriteString(prompt)
+	b.WriteString(line)
+	b.WriteString("\x1b[K\r")
+
+	if col := displayWidth(prompt) + cursor; col > 0 {
+		fmt.Fprintf(&b, "\x1b[%dC", col)
+	}
+
+	s.write(b.String())
+}
+
+func (s *state) write(str string) {
+	io.WriteString(s.e.out, str)
+}
+
+// displayWidth is the number of columns `str` takes up, skipping over ANSI
+// escape sequences such as colors
+func displayWidth(str string) int {
+	width := 0
+	inEscape := false
+	for _, r := range str {
+		switch {
+		case r == '\x1b':
+			inEscape = true
+		case inEscape:
+			if r >= 0x40 && r <= 0x7e && r != '[' {
+				inEscape = false
+			}
+		default:
+			width++
+		}
+	}
+	return width
+}
+
+// readKey reads a single key press, turning escape sequences in to the `key*`
+// constants above
+func (s *state) readKey() (rune, error) {
+	r, _, err := s.e.in.ReadRune()
+	if err != nil || r != keyEscape {
+		return r, err
+	}
+
+	// a lone escape has nothing buffered after it
+	if s.e.in.Buffered() == 0 {
+		return keyEscape, nil
+	}
+
+	next, _, err := s.e.in.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	if next != '[' && next != 'O' {
+		return keyUnknown, nil
+	}
+
+	// read the parameters up to the final byte of the sequence
+	var params []rune
+	for {
+		c, _, err := s.e.in.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+		if c >= 0x40 && c <= 0x7e {
+			return escapeKey(string(params), c), nil
+		}
+		params = append(params, c)
+	}
+}
+
+func escapeKey(params string, final rune) rune {
+	switch final {
+	case 'A':
+		return keyUp
+	case 'B':
+		return keyDown
+	case 'C':
+		return keyRight
+	case 'D':
+		return keyLeft
+	case 'H':
+		return keyHome
+	case 'F':
+		return keyEnd
+	case '~':
+		switch params {
+		case "1", "7":
+			return keyHome
+		case "4", "8":
+			return keyEnd
+		case "3":
+			return keyDelete
+		}
+	}
+	return keyUnknown
+}
@@ -0,0 +1,149 @@
+package readline
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		history  []string
+		keys     string
+		expected string
+	}{
+		{"plain", nil, "let x = 5;\r", "let x = 5;"},
+		{"backspace", nil, "lex\x7ft\r", "let"},
+		{"left arrow insert", nil, "ac\x1b[Db\r", "abc"},
+		{"home and end", nil, "bc\x01a\x05d\r", "abcd"},
+		{"home and end sequences", nil, "bc\x1b[Ha\x1b[4~d\r", "abcd"},
+		{"delete", nil, "abc\x1b[D\x1b[D\x1b[3~\r", "ac"},
+		{"kill to end", nil, "abcdef\x1b[D\x1b[D\x0b\r", "abcd"},
+		{"kill to start", nil, "abcdef\x1b[D\x1b[D\x15\r", "ef"},
+		{"kill word", nil, "let foo bar\x17\r", "let foo "},
+		{"history up", []string{"one", "two"}, "\x1b[A\x1b[A\r", "one"},
+		{"history down restores typing", []string{"one"}, "tw\x1b[A\x1b[Bo\r", "two"},
+		{"history past oldest", []string{"one"}, "\x10\x10\x10\r", "one"},
+		{"reverse search", []string{"let a = 1;", "add(1, 2)", "let b = 2;"}, "\x12let\r", "let b = 2;"},
+		{"reverse search again", []string{"let a = 1;", "add(1, 2)", "let b = 2;"}, "\x12let\x12\r", "let a = 1;"},
+		{"reverse search then edit", []string{"add(1, 2)"}, "\x12add\x05;\r", "add(1, 2);"},
+		{"reverse search cancel", []string{"add(1, 2)"}, "x\x12add\x07\r", "x"},
+		{"no newline at EOF", nil, "abc", "abc"},
+	}
+
+	for _, tt := range tests {
+		e := New(strings.NewReader(tt.keys), io.Discard)
+		e.history = tt.history
+
+		line, err := e.ReadLine(">> ")
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", tt.name, err)
+			continue
+		}
+
+		if line != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.name, tt.expected, line)
+		}
+	}
+}
+
+func TestReadLineControl(t *testing.T) {
+	tests := []struct {
+		keys     string
+		expected error
+	}{
+		{"\x04", io.EOF},
+		{"", io.EOF},
+		{"abc\x03", ErrInterrupted},
+	}
+
+	for _, tt := range tests {
+		e := New(strings.NewReader(tt.keys), io.Discard)
+
+		if _, err := e.ReadLine(">> "); err != tt.expected {
+			t.Errorf("%q: expected error %v, got %v", tt.keys, tt.expected, err)
+		}
+	}
+}
+
+func TestComplete(t *testing.T) {
+	words := []string{"let", "return", "result", "fn"}
+	complete := func(word string) []string {
+		var out []string
+		for _, w := range words {
+			if strings.HasPrefix(w, word) {
+				out = append(out, w)
+			}
+		}
+		return out
+	}
+
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"l\t x\r", "let x"},
+		{"re\t\r", "re"},
+		{"res\t\r", "result"},
+		{"1 + f\t(\r", "1 + fn("},
+		{"zz\t\r", "zz"},
+	}
+
+	for _, tt := range tests {
+		e := New(strings.NewReader(tt.keys), io.Discard)
+		e.Complete = complete
+
+		line, err := e.ReadLine(">> ")
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+
+		if line != tt.expected {
+			t.Errorf("%q: expected=%q, got=%q", tt.keys, tt.expected, line)
+		}
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "monkey", "history")
+
+	e := New(strings.NewReader(""), io.Discard)
+	if err := e.LoadHistory(path); err != nil {
+		t.Fatalf("LoadHistory on missing file: %s", err)
+	}
+
+	for _, line := range []string{"let a = 1;", "", "a", "a", "a + 1"} {
+		if err := e.AddHistory(line); err != nil {
+			t.Fatalf("AddHistory: %s", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading history file: %s", err)
+	}
+
+	expected := "let a = 1;\na\na + 1\n"
+	if string(data) != expected {
+		t.Errorf("history file wrong. expected=%q, got=%q", expected, string(data))
+	}
+
+	reloaded := New(strings.NewReader("\x1b[A\x1b[A\r"), io.Discard)
+	if err := reloaded.LoadHistory(path); err != nil {
+		t.Fatalf("LoadHistory: %s", err)
+	}
+
+	line, _ := reloaded.ReadLine(">> ")
+	if line != "a" {
+		t.Errorf("expected history entry %q, got %q", "a", line)
+	}
+}
+
+func TestDisplayWidth(t *testing.T) {
+	if w := displayWidth("\x1b[1;32m>> \x1b[0m"); w != 3 {
+		t.Errorf("displayWidth of colored prompt = %d, want 3", w)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+
+package readline
+
+import "errors"
+
+// IsTerminal always reports false where raw mode is not supported, so the REPL
+// falls back to reading plain lines
+func IsTerminal(fd int) bool { return false }
+
+func makeRaw(fd int) (func(), error) {
+	return nil, errors.New("raw terminal mode is not supported on this platform")
+}
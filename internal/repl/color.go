@@ -0,0 +1,135 @@
+package repl
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"monkey-lang.z9fr.xyz/internal/lexer"
+	"monkey-lang.z9fr.xyz/internal/object"
+	"monkey-lang.z9fr.xyz/internal/readline"
+	"monkey-lang.z9fr.xyz/internal/token"
+)
+
+const (
+	colorReset   = "\x1b[0m"
+	colorRed     = "\x1b[31m"
+	colorGreen   = "\x1b[32m"
+	colorYellow  = "\x1b[33m"
+	colorBlue    = "\x1b[34m"
+	colorMagenta = "\x1b[35m"
+	colorCyan    = "\x1b[36m"
+	colorGray    = "\x1b[90m"
+)
+
+// colors wraps text in ANSI color codes. the zero value is disabled and returns
+// text unchanged
+type colors struct {
+	enabled bool
+}
+
+// colorsFor turns colors on only when `out` is a terminal and the user didn't ask
+// for plain output with NO_COLOR (https://no-color.org)
+func colorsFor(out io.Writer) colors {
+	f, ok := out.(*os.File)
+	if !ok || os.Getenv("NO_COLOR") != "" {
+		return colors{}
+	}
+	return colors{enabled: readline.IsTerminal(int(f.Fd()))}
+}
+
+func (c colors) paint(color, text string) string {
+	if !c.enabled || color == "" || text == "" {
+		return text
+	}
+	return color + text + colorReset
+}
+
+func (c colors) error(text string) string {
+	return c.paint(colorRed, text)
+}
+
+// value colors the result of an evaluation by its type
+func (c colors) value(obj object.Object) string {
+	switch obj.Type() {
+	case object.INTEGER_OBJ:
+		return c.paint(colorCyan, obj.Inspect())
+	case object.BOOLEAN_OBJ:
+		return c.paint(colorMagenta, obj.Inspect())
+	case object.STRING_OBJ:
+		return c.paint(colorGreen, obj.Inspect())
+	case object.NULL_OBJ:
+		return c.paint(colorGray, obj.Inspect())
+	case object.ERROR_OBJ:
+		return c.paint(colorRed, obj.Inspect())
+	case object.QUOTE_OBJ:
+		return c.paint(colorYellow, obj.Inspect())
+	case object.FUNCTION_OBJ, object.MACRO_OBJ, object.GO_FUNCTION_OBJ, object.GO_STRUCT_OBJ:
+		return c.paint(colorBlue, obj.Inspect())
+	default:
+		return obj.Inspect()
+	}
+}
+
+// tokenColor picks the color a token is highlighted with, "" leaves it as it is
+func tokenColor(t token.TokenType) string {
+	switch t {
+	case token.FUNCTION, token.LET, token.TRUE, token.FALSE, token.IF, token.ELSE, token.RETURN,
+		token.MACRO:
+		return colorMagenta
+	case token.INT:
+		return colorCyan
+	case token.STRING:
+		return colorGreen
+	case token.IDENT:
+		return colorBlue
+	case token.ASSIGN, token.PLUS, token.MINUS, token.BANG, token.ASTERISK, token.SLASH,
+		token.EQ, token.NOT_EQ, token.LT, token.GT, token.DOT:
+		return colorYellow
+	case token.ILLEGAL:
+		return colorRed
+	default:
+		return ""
+	}
+}
+
+// source highlights monkey source code. tokens come from the lexer, and the text
+// between them (whitespace) is copied over as it is
+func (c colors) source(src string) string {
+	if !c.enabled {
+		return src
+	}
+
+	var out strings.Builder
+	l := lexer.New(src)
+	rest := src
+
+	for {
+		tok := l.NextToken()
+		if tok.Type == token.EOF {
+			break
+		}
+
+		// find where the token starts in what is left of the input. string
+		// literals don't include their quotes, so we look for the opening one
+		text := tok.Literal
+		start := strings.Index(rest, text)
+		if tok.Type == token.STRING {
+			start = strings.Index(rest, `"`)
+			text = rest[start : start+1+len(tok.Literal)]
+			if strings.HasPrefix(rest[start+len(text):], `"`) {
+				text += `"`
+			}
+		}
+		if start < 0 {
+			break
+		}
+
+		out.WriteString(rest[:start])
+		out.WriteString(c.paint(tokenColor(tok.Type), text))
+		rest = rest[start+len(text):]
+	}
+
+	out.WriteString(rest)
+	return out.String()
+}
@@ -0,0 +1,64 @@
+package repl
+
+import (
+	"testing"
+
+	"monkey-lang.z9fr.xyz/internal/object"
+)
+
+func TestHighlightSource(t *testing.T) {
+	c := colors{enabled: true}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"let x = 10;",
+			colorMagenta + "let" + colorReset + " " +
+				colorBlue + "x" + colorReset + " " +
+				colorYellow + "=" + colorReset + " " +
+				colorCyan + "10" + colorReset + ";",
+		},
+		{
+			`  "a b" + s`,
+			"  " + colorGreen + `"a b"` + colorReset + " " +
+				colorYellow + "+" + colorReset + " " +
+				colorBlue + "s" + colorReset,
+		},
+		{
+			`"open`,
+			colorGreen + `"open` + colorReset,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := c.source(tt.input); got != tt.expected {
+			t.Errorf("source(%q) wrong.\nexpected=%q\ngot=     %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestColorsDisabled(t *testing.T) {
+	c := colors{}
+
+	if got := c.source("let x = 1;"); got != "let x = 1;" {
+		t.Errorf("disabled source() changed input: %q", got)
+	}
+
+	if got := c.value(&object.Integer{Value: 5}); got != "5" {
+		t.Errorf("disabled value() = %q, want %q", got, "5")
+	}
+
+	if got := c.error("oops"); got != "oops" {
+		t.Errorf("disabled error() = %q, want %q", got, "oops")
+	}
+}
+
+func TestColorValue(t *testing.T) {
+	c := colors{enabled: true}
+
+	if got := c.value(&object.Error{Message: "boom"}); got != colorRed+"ERROR: boom"+colorReset {
+		t.Errorf("error value not red: %q", got)
+	}
+}
@@ -0,0 +1,132 @@
+package repl
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/evaluator"
+	"monkey-lang.z9fr.xyz/internal/lexer"
+	"monkey-lang.z9fr.xyz/internal/object"
+	"monkey-lang.z9fr.xyz/internal/parser"
+	"monkey-lang.z9fr.xyz/internal/token"
+)
+
+// meta-commands start with `:` and are handled by the REPL itself instead of
+// being evaluated. they exist to look at what the lexer, parser and evaluator
+// are doing without writing a test for it
+type command struct {
+	name string
+	args string
+	help string
+	run  func(s *session, arg string)
+}
+
+var commands []command
+
+func init() {
+	// assigned in init because `:help` refers back to `commands`
+	commands = []command{
+		{"tokens", "<src>", "print the tokens the lexer produces for src", (*session).tokens},
+		{"ast", "<src>", "print the parsed AST of src as a tree", (*session).ast},
+		{"env", "", "list the bindings in the current environment", (*session).listEnv},
+		{"reset", "", "clear the environment and the defined macros", (*session).reset},
+		{"load", "<file>", "evaluate a file in to the current session", (*session).load},
+		{"help", "", "show this help", (*session).help},
+	}
+}
+
+func (s *session) runCommand(line string) {
+	name, arg, _ := strings.Cut(strings.TrimPrefix(line, ":"), " ")
+	arg = strings.TrimSpace(arg)
+
+	for _, c := range commands {
+		if c.name == name {
+			c.run(s, arg)
+			return
+		}
+	}
+
+	fmt.Fprintf(s.out, "unknown command :%s, try :help\n", name)
+}
+
+func (s *session) tokens(src string) {
+	l := lexer.New(src)
+	for {
+		tok := l.NextToken()
+		fmt.Fprintf(s.out, "%-10s %q\n", tok.Type, tok.Literal)
+		if tok.Type == token.EOF {
+			return
+		}
+	}
+}
+
+func (s *session) ast(src string) {
+	program, ok := s.parse(src)
+	if !ok {
+		return
+	}
+
+	ast.Fprint(s.out, program)
+}
+
+func (s *session) listEnv(string) {
+	for _, env := range []*object.Environment{s.macroEnv, s.env} {
+		for _, name := range env.Names() {
+			// an empty block binds nothing, that is shown like null
+			val, _ := env.Get(name)
+			if val == nil {
+				val = evaluator.NULL
+			}
+			fmt.Fprintf(s.out, "%s = %s\n", name, val.Inspect())
+		}
+	}
+}
+
+func (s *session) reset(string) {
+	s.env = object.NewEnvironment()
+	s.macroEnv = object.NewEnvironment()
+}
+
+func (s *session) load(filename string) {
+	if filename == "" {
+		fmt.Fprintln(s.out, "usage: :load <file>")
+		return
+	}
+
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintln(s.out, err)
+		return
+	}
+
+	program, ok := s.parse(string(src))
+	if !ok {
+		return
+	}
+
+	s.eval(program)
+}
+
+func (s *session) help(string) {
+	for _, c := range commands {
+		usage := ":" + c.name
+		if c.args != "" {
+			usage += " " + c.args
+		}
+		fmt.Fprintf(s.out, "  %-16s %s\n", usage, c.help)
+	}
+}
+
+func (s *session) parse(src string) (*ast.Program, bool) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		s.printParserErrors(p.Errors())
+		return nil, false
+	}
+
+	return program, true
+}
@@ -0,0 +1,97 @@
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"monkey-lang.z9fr.xyz/internal/object"
+	"monkey-lang.z9fr.xyz/internal/readline"
+	"monkey-lang.z9fr.xyz/internal/token"
+)
+
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+// newLineReader uses the line editor when `in` is a terminal, and plain line
+// reading otherwise (pipes, files and tests)
+func newLineReader(in io.Reader, out io.Writer, s *session) lineReader {
+	f, ok := in.(*os.File)
+	if !ok {
+		return &scannerReader{scanner: bufio.NewScanner(in), out: out}
+	}
+
+	editor, err := readline.NewTerminal(f, out)
+	if err != nil {
+		return &scannerReader{scanner: bufio.NewScanner(in), out: out}
+	}
+
+	editor.Complete = s.complete
+	editor.Highlight = s.colors.source
+
+	// history is a nice to have, the REPL works fine without it
+	if path, err := readline.DefaultHistoryFile(); err == nil {
+		editor.LoadHistory(path)
+	}
+
+	return &editorReader{editor}
+}
+
+type editorReader struct {
+	editor *readline.Editor
+}
+
+func (r *editorReader) ReadLine(prompt string) (string, error) {
+	line, err := r.editor.ReadLine(prompt)
+	if err == nil {
+		r.editor.AddHistory(line)
+	}
+	return line, err
+}
+
+type scannerReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (r *scannerReader) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(r.out, prompt)
+
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+
+	return r.scanner.Text(), nil
+}
+
+// complete offers keywords and every name visible from the session environment
+func (s *session) complete(word string) []string {
+	seen := map[string]bool{}
+	var candidates []string
+
+	add := func(name string) {
+		if strings.HasPrefix(name, word) && !seen[name] {
+			seen[name] = true
+			candidates = append(candidates, name)
+		}
+	}
+
+	for _, kw := range token.Keywords() {
+		add(kw)
+	}
+	for _, env := range []*object.Environment{s.env, s.macroEnv} {
+		for ; env != nil; env = env.Outer() {
+			for _, name := range env.Names() {
+				add(name)
+			}
+		}
+	}
+
+	return candidates
+}
@@ -1,17 +1,23 @@
 package repl
 
 import (
-	"bufio"
-	"fmt"
 	"io"
+	"strings"
 
+	"monkey-lang.z9fr.xyz/internal/ast"
 	"monkey-lang.z9fr.xyz/internal/evaluator"
 	"monkey-lang.z9fr.xyz/internal/lexer"
 	"monkey-lang.z9fr.xyz/internal/object"
 	"monkey-lang.z9fr.xyz/internal/parser"
+	"monkey-lang.z9fr.xyz/internal/readline"
+	"monkey-lang.z9fr.xyz/internal/resolver"
 )
 
 const PROMPT = ">> "
+
+// CONT_PROMPT is shown while the statement typed so far is incomplete
+const CONT_PROMPT = ".. "
+
 const MONKEY_FACE = `            __,__
    .--.  .-"     "-.  .--.
   / .. \/  .-. .-.  \/ .. \
@@ -26,42 +32,100 @@ const MONKEY_FACE = `            __,__
 `
 
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	s := &session{
+		env:      object.NewEnvironment(),
+		macroEnv: object.NewEnvironment(),
+		out:      out,
+		colors:   colorsFor(out),
+	}
+	reader := newLineReader(in, out, s)
+
+	// lines typed so far for the current statement
+	var input strings.Builder
 
 	for {
-		fmt.Fprintf(out, PROMPT)
-		scanned := scanner.Scan()
+		prompt := PROMPT
+		if input.Len() != 0 {
+			prompt = CONT_PROMPT
+		}
+
+		line, err := reader.ReadLine(prompt)
 
-		if !scanned {
+		if err == readline.ErrInterrupted {
+			// ctrl-c drops whatever was typed so far
+			input.Reset()
+			continue
+		}
+
+		if err != nil {
 			return
 		}
 
-		line := scanner.Text()
-		l := lexer.New(line)
+		if input.Len() == 0 && strings.HasPrefix(line, ":") {
+			s.runCommand(line)
+			continue
+		}
+
+		// an empty line while continuing gives up on waiting for more input,
+		// so the errors of what was typed so far get reported
+		giveUp := input.Len() != 0 && strings.TrimSpace(line) == ""
+
+		input.WriteString(line)
+		input.WriteString("\n")
+
+		l := lexer.New(input.String())
 		p := parser.New(l)
 
 		program := p.ParseProgram()
 
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+		if p.Incomplete() && !giveUp {
 			continue
 		}
 
-		eval := evaluator.Eval(program, env)
+		input.Reset()
 
-		if eval != nil {
-			io.WriteString(out, eval.Inspect())
-			io.WriteString(out, "\n")
+		if len(p.Errors()) != 0 {
+			s.printParserErrors(p.Errors())
+			continue
 		}
+
+		s.eval(program)
+	}
+}
+
+// session is the state that lives for as long as the REPL runs
+type session struct {
+	env *object.Environment
+	// macros live in their own environment, they are expanded before evaluation
+	macroEnv *object.Environment
+	out      io.Writer
+	colors   colors
+}
+
+func (s *session) eval(program *ast.Program) {
+	evaluator.DefineMacros(program, s.macroEnv)
+	expanded, err := evaluator.ExpandMacros(program, s.macroEnv)
+	if err != nil {
+		// shown like any other error the program runs in to
+		io.WriteString(s.out, s.colors.value(&object.Error{Message: err.Error()}))
+		io.WriteString(s.out, "\n")
+		return
+	}
+	resolver.Annotate(expanded.(*ast.Program))
+
+	eval := evaluator.Eval(expanded, s.env)
+
+	if eval != nil {
+		io.WriteString(s.out, s.colors.value(eval))
+		io.WriteString(s.out, "\n")
 	}
 }
 
-func printParserErrors(out io.Writer, errors []string) {
-	io.WriteString(out, MONKEY_FACE)
-	io.WriteString(out, "Woops! We ran into some monkey business here!\n")
-	io.WriteString(out, " parser errors:\n")
+func (s *session) printParserErrors(errors []string) {
+	io.WriteString(s.out, MONKEY_FACE)
+	io.WriteString(s.out, "Woops! We ran into some monkey business here!\n")
+	io.WriteString(s.out, " parser errors:\n")
 	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+		io.WriteString(s.out, "\t"+s.colors.error(msg)+"\n")
 	}
 }
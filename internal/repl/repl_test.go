@@ -0,0 +1,117 @@
+package repl
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStart(t *testing.T) {
+	loaded := filepath.Join(t.TempDir(), "lib.monkey")
+	if err := os.WriteFile(loaded, []byte("let double = fn(x) { x * 2 };\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			"continuation",
+			"let add = fn(x) {\n  x + 1\n};\nadd(1)\n",
+			">> .. .. >> 2\n>> ",
+		},
+		{
+			// an empty line gives up on the statement and reports what is wrong
+			"give up on continuation",
+			"let x = (1 +\n\n5\n",
+			">> .. " + MONKEY_FACE +
+				"Woops! We ran into some monkey business here!\n" +
+				" parser errors:\n" +
+				"\tno prefix parse function for EOF found\n" +
+				"\texpected next token to be ), got EOF instead\n" +
+				">> 5\n>> ",
+		},
+		{
+			// a wrong token before the end, no continuation can fix that
+			"no continuation for broken input",
+			"let x = 1 +; fn(\n",
+			">> " + MONKEY_FACE +
+				"Woops! We ran into some monkey business here!\n" +
+				" parser errors:\n" +
+				"\tno prefix parse function for ; found\n" +
+				"\texpected next token to be ), got EOF instead\n" +
+				"\texpected next token to be {, got EOF instead\n" +
+				">> ",
+		},
+		{
+			"tokens",
+			":tokens let x\n",
+			">> LET        \"let\"\nIDENT      \"x\"\nEOF        \"\"\n>> ",
+		},
+		{
+			"ast",
+			":ast 1 + 2\n",
+			">> Program (1)\n" +
+				"  Statements:\n" +
+				"    ExpressionStatement (1)\n" +
+				"      Expression: InfixExpression (+)\n" +
+				"        Left: IntegerLiteral (1)\n" +
+				"        Right: IntegerLiteral (2)\n" +
+				">> ",
+		},
+		{
+			"env",
+			"let a = 1;\nlet b = \"two\";\n:env\n",
+			">> >> >> a = 1\nb = two\n>> ",
+		},
+		{
+			"env with empty values",
+			"let f = fn() { };\nlet x = f();\nlet y = if (true) { };\n:env\n",
+			">> >> >> >> f = fn() {\n\n}\nx = null\ny = null\n>> ",
+		},
+		{
+			"load",
+			":load " + loaded + "\ndouble(21)\n",
+			">> >> 42\n>> ",
+		},
+		{
+			"load missing file",
+			":load\n",
+			">> usage: :load <file>\n>> ",
+		},
+		{
+			"reset",
+			"let a = 1;\n:reset\n:env\na\n",
+			">> >> >> >> ERROR: identifier not found: a\n>> ",
+		},
+		{
+			// macros are dropped too, the call is evaluated like any other
+			"reset clears macros",
+			"let inc = macro(x) { quote(unquote(x) + 1) };\ninc(1)\n:reset\n:env\ninc(1)\n",
+			">> >> 2\n>> >> >> ERROR: identifier not found: inc\n>> ",
+		},
+		{
+			"macro errors",
+			"let bad = macro(x) { 1 / 0 };\nbad(1)\n",
+			">> >> ERROR: macro bad: division by zero\n>> ",
+		},
+		{
+			"unknown command",
+			":nope\n",
+			">> unknown command :nope, try :help\n>> ",
+		},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		Start(strings.NewReader(tt.input), &out)
+
+		if out.String() != tt.expected {
+			t.Errorf("%s: wrong output.\ngot=%q\nwant=%q", tt.name, out.String(), tt.expected)
+		}
+	}
+}
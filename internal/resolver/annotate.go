@@ -0,0 +1,86 @@
+package resolver
+
+import (
+	"monkey-lang.z9fr.xyz/internal/ast"
+)
+
+// Annotate resolves `program` and writes the lexical address of every name
+// bound in a function in to its `*ast.Identifier`, and the slot names in to its
+// `*ast.FunctionLiteral`. the evaluator then finds those names by index
+// instead of walking maps.
+//
+// globals stay in the top level map, so do names bound by macros, which are
+// expanded before the program runs. run it after macro expansion, on the tree
+// that is about to be evaluated. a node that is in the tree more than once is
+// copied, each place gets its own
+func Annotate(program *ast.Program) *Result {
+	// clear what an earlier run left behind, a node can end up somewhere else
+	// after macro expansion. a node that is in the tree twice could only hold
+	// one address, so every place after the first gets a copy. `Modify` works
+	// from the bottom up, the copy is made of the outermost shared node
+	seen := map[ast.Node]bool{}
+	ast.Modify(program, func(n ast.Node) ast.Node {
+		switch n := n.(type) {
+		case nil:
+			return n
+		case *ast.Identifier:
+			n.Local, n.Depth, n.Slot = false, 0, 0
+		case *ast.FunctionLiteral:
+			n.Locals = nil
+		}
+
+		if seen[n] {
+			return ast.Copy(n)
+		}
+		seen[n] = true
+		return n
+	})
+
+	result := Resolve(program)
+
+	// a second `let` of a name in the same function reuses its slot, the
+	// evaluator used to overwrite the map entry the same way
+	slots := map[*Binding]int{}
+	for node, s := range result.Scopes {
+		fn, ok := node.(*ast.FunctionLiteral)
+		if !ok {
+			continue
+		}
+
+		index := map[string]int{}
+		for _, b := range s.Bindings {
+			i, ok := index[b.Name]
+			if !ok {
+				i = len(fn.Locals)
+				index[b.Name] = i
+				fn.Locals = append(fn.Locals, b.Name)
+			}
+			slots[b] = i
+		}
+	}
+
+	for ident, b := range result.Uses {
+		slot, ok := slots[b]
+		if !ok {
+			continue
+		}
+
+		// every scope between the use and the binding has to be a function,
+		// those are the frames the evaluator walks out of
+		depth := 0
+		s := result.useScopes[ident]
+		for ; s != b.Scope; s = s.Parent {
+			if _, ok := s.Node.(*ast.FunctionLiteral); !ok {
+				break
+			}
+			depth++
+		}
+		if s != b.Scope {
+			continue
+		}
+
+		ident.Local, ident.Depth, ident.Slot = true, depth, slot
+	}
+
+	return result
+}
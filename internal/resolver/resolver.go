@@ -0,0 +1,313 @@
+package resolver
+
+/*
+resolver
+
+a static pass over the AST that matches every identifier with the binding it
+refers to, before anything runs. it reports
+
+  - undefined names, which the evaluator would only find when it gets to them
+  - bindings in functions that are never used
+  - bindings that shadow one from an enclosing scope
+
+scopes follow the evaluator: only functions (and macros) get a new environment,
+the blocks of an `if` bind in to the function around them. a function body can
+run long after it was defined, so it is resolved once the scope around it is
+complete. that way recursion and functions calling ones defined after them are
+fine, while using a name before its `let` in the same scope is not
+*/
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/token"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+)
+
+func (s Severity) String() string {
+	if s == Error {
+		return "error"
+	}
+	return "warning"
+}
+
+type Diagnostic struct {
+	Pos      token.Position
+	Severity Severity
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s: %s", d.Pos, d.Severity, d.Message)
+}
+
+type BindingKind int
+
+const (
+	Predeclared BindingKind = iota // builtins such as `puts`
+	Let                            // `let x = ...`
+	Parameter                      // function or macro parameter
+)
+
+// Binding is a name introduced in a scope
+type Binding struct {
+	Name string
+	Kind BindingKind
+
+	// the identifier that introduces the name and the node it belongs to, the
+	// `*ast.LetStatement` or the `*ast.FunctionLiteral`/`*ast.MacroLiteral` of a
+	// parameter. both are nil for predeclared names
+	Ident *ast.Identifier
+	Decl  ast.Node
+
+	Scope *Scope
+	Uses  []*ast.Identifier
+}
+
+// Scope is the set of bindings of a program or a function
+type Scope struct {
+	Parent   *Scope
+	Node     ast.Node // *ast.Program, *ast.FunctionLiteral or *ast.MacroLiteral; nil for predeclared names
+	Bindings []*Binding
+
+	names map[string]*Binding
+	// function bodies waiting for this scope to be complete
+	pending []func()
+}
+
+func newScope(parent *Scope, node ast.Node) *Scope {
+	return &Scope{Parent: parent, Node: node, names: map[string]*Binding{}}
+}
+
+// Lookup finds the binding `name` refers to in this scope or the ones around it
+func (s *Scope) Lookup(name string) *Binding {
+	for ; s != nil; s = s.Parent {
+		if b, ok := s.names[name]; ok {
+			return b
+		}
+	}
+	return nil
+}
+
+type Result struct {
+	Diagnostics []Diagnostic
+
+	// Uses maps every identifier that was resolved to its binding, including
+	// the identifiers that declare a name
+	Uses map[*ast.Identifier]*Binding
+
+	// Scopes maps programs and function literals to the scope they create
+	Scopes map[ast.Node]*Scope
+
+	// the scope each identifier in `Uses` appears in
+	useScopes map[*ast.Identifier]*Scope
+}
+
+// HasErrors reports whether any diagnostic is an error rather than a warning
+func (r *Result) HasErrors() bool {
+	for _, d := range r.Diagnostics {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve resolves `program`. `predeclared` are the names the environment it
+// will run in already has, like builtins bound from Go
+func Resolve(program *ast.Program, predeclared ...string) *Result {
+	r := &resolver{
+		result: &Result{
+			Uses:      map[*ast.Identifier]*Binding{},
+			Scopes:    map[ast.Node]*Scope{},
+			useScopes: map[*ast.Identifier]*Scope{},
+		},
+	}
+
+	universe := newScope(nil, nil)
+	for _, name := range append([]string{"quote", "unquote"}, predeclared...) {
+		r.declare(universe, name, Predeclared, nil, nil)
+	}
+
+	r.scope = newScope(universe, program)
+	r.result.Scopes[program] = r.scope
+	r.statements(program.Statements)
+	r.closeScope(r.scope, false)
+
+	sort.SliceStable(r.result.Diagnostics, func(i, j int) bool {
+		return r.result.Diagnostics[i].Pos.Offset < r.result.Diagnostics[j].Pos.Offset
+	})
+
+	return r.result
+}
+
+type resolver struct {
+	scope  *Scope
+	result *Result
+}
+
+func (r *resolver) report(pos token.Position, severity Severity, format string, a ...interface{}) {
+	r.result.Diagnostics = append(r.result.Diagnostics, Diagnostic{
+		Pos:      pos,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, a...),
+	})
+}
+
+func (r *resolver) declare(s *Scope, name string, kind BindingKind, ident *ast.Identifier, decl ast.Node) {
+	b := &Binding{Name: name, Kind: kind, Ident: ident, Decl: decl, Scope: s}
+
+	if ident != nil {
+		if outer := s.Parent.Lookup(name); outer != nil && outer.Kind != Predeclared {
+			r.report(ident.Token.Pos, Warning, "%s shadows declaration at %s", name, outer.Ident.Token.Pos)
+		}
+		r.result.Uses[ident] = b
+		r.result.useScopes[ident] = s
+	}
+
+	// a second `let` of the same name in a scope replaces the binding from
+	// there on, the way `Environment.Set` overwrites it
+	s.names[name] = b
+	s.Bindings = append(s.Bindings, b)
+}
+
+// closeScope resolves the function bodies that were waiting for `s` and then
+// reports the bindings nobody used
+func (r *resolver) closeScope(s *Scope, reportUnused bool) {
+	for len(s.pending) > 0 {
+		fn := s.pending[0]
+		s.pending = s.pending[1:]
+		fn()
+	}
+
+	if !reportUnused {
+		return
+	}
+
+	for _, b := range s.Bindings {
+		if len(b.Uses) == 0 && !strings.HasPrefix(b.Name, "_") {
+			r.report(b.Ident.Token.Pos, Warning, "%s declared and not used", b.Name)
+		}
+	}
+}
+
+func (r *resolver) statements(list []ast.Statement) {
+	for _, s := range list {
+		r.statement(s)
+	}
+}
+
+func (r *resolver) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		r.expression(stmt.Value)
+		if stmt.Name != nil {
+			r.declare(r.scope, stmt.Name.Value, Let, stmt.Name, stmt)
+		}
+	case *ast.ReturnStatement:
+		r.expression(stmt.ReturnValue)
+	case *ast.ExpressionStatement:
+		r.expression(stmt.Expression)
+	case *ast.BlockStatement:
+		r.block(stmt)
+	}
+}
+
+func (r *resolver) block(block *ast.BlockStatement) {
+	if block != nil {
+		r.statements(block.Statements)
+	}
+}
+
+func (r *resolver) expression(e ast.Expression) {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		r.use(e)
+	case *ast.PrefixExpression:
+		r.expression(e.Right)
+	case *ast.InfixExpression:
+		r.expression(e.Left)
+		r.expression(e.Right)
+	case *ast.IfExpression:
+		r.expression(e.Condition)
+		r.block(e.Consequence)
+		r.block(e.Alternative)
+	case *ast.FunctionLiteral:
+		r.function(e, e.Parameters, e.Body)
+	case *ast.MacroLiteral:
+		r.function(e, e.Parameters, e.Body)
+	case *ast.CallExpression:
+		r.expression(e.Function)
+		if ident, ok := e.Function.(*ast.Identifier); ok && ident.Value == "quote" {
+			// quoted code is data, only what gets unquoted is evaluated here
+			for _, arg := range e.Arguments {
+				r.unquoted(arg)
+			}
+			return
+		}
+		for _, arg := range e.Arguments {
+			r.expression(arg)
+		}
+	case *ast.FieldExpression:
+		// the field name is looked up in the Go value, not in scope
+		r.expression(e.Object)
+	}
+}
+
+func (r *resolver) unquoted(node ast.Node) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpression)
+		if !ok {
+			return true
+		}
+		if ident, ok := call.Function.(*ast.Identifier); ok && ident.Value == "unquote" {
+			r.use(ident)
+			for _, arg := range call.Arguments {
+				r.expression(arg)
+			}
+			return false
+		}
+		return true
+	})
+}
+
+func (r *resolver) use(ident *ast.Identifier) {
+	b := r.scope.Lookup(ident.Value)
+	if b == nil {
+		r.report(ident.Token.Pos, Error, "undefined: %s", ident.Value)
+		return
+	}
+
+	b.Uses = append(b.Uses, ident)
+	r.result.Uses[ident] = b
+	r.result.useScopes[ident] = r.scope
+}
+
+// function declares the parameters in a new scope and queues the body until the
+// enclosing scope is complete
+func (r *resolver) function(node ast.Node, params []*ast.Identifier, body *ast.BlockStatement) {
+	outer := r.scope
+	s := newScope(outer, node)
+	r.result.Scopes[node] = s
+
+	for _, p := range params {
+		r.declare(s, p.Value, Parameter, p, node)
+	}
+
+	outer.pending = append(outer.pending, func() {
+		saved := r.scope
+		r.scope = s
+		r.block(body)
+		r.closeScope(s, true)
+		r.scope = saved
+	})
+}
@@ -0,0 +1,239 @@
+package resolver
+
+import (
+	"strings"
+	"testing"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/lexer"
+	"monkey-lang.z9fr.xyz/internal/parser"
+)
+
+func resolve(t *testing.T, input string, predeclared ...string) (*ast.Program, *Result) {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+
+	return program, Resolve(program, predeclared...)
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 5; x;", nil},
+		{"y;", []string{"1:1: error: undefined: y"}},
+		{"let x = x + 1;", []string{"1:9: error: undefined: x"}},
+		{"x; let x = 1;", []string{"1:1: error: undefined: x"}},
+		{"puts(1);", []string{"1:1: error: undefined: puts"}},
+		// recursion and calling functions defined later are fine
+		{"let f = fn(n) { if (n > 0) { f(n - 1) } }; f(3);", nil},
+		{"let a = fn() { b() }; let b = fn() { 1 }; a();", nil},
+		// an `if` block binds in the scope around it
+		{"if (true) { let z = 1; } z;", nil},
+		{"let f = fn(x) { 1 };", []string{"1:12: warning: x declared and not used"}},
+		{"let f = fn(_x) { 1 };", nil},
+		{"let f = fn() { let y = 1; 2 };", []string{"1:20: warning: y declared and not used"}},
+		{
+			"let x = 1; let f = fn(x) { x }; f(x);",
+			[]string{"1:23: warning: x shadows declaration at 1:5"},
+		},
+		{
+			"let f = fn(a) { fn(b) { a + b + c } };",
+			[]string{"1:33: error: undefined: c"},
+		},
+		// fields are looked up in the Go value, not in scope
+		{"let s = 1; s.Name;", nil},
+		// quoted code is not evaluated, unquoted code is
+		{"quote(foo + bar);", nil},
+		{"quote(foo + unquote(bar));", []string{"1:21: error: undefined: bar"}},
+		{
+			"let unless = macro(cond, then) { quote(if (!(unquote(cond))) { unquote(then) }) }; unless(false, 1);",
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		_, result := resolve(t, tt.input)
+
+		if len(result.Diagnostics) != len(tt.expected) {
+			t.Errorf("%q: wrong number of diagnostics. want=%v, got=%v", tt.input, tt.expected, result.Diagnostics)
+			continue
+		}
+
+		for i, d := range result.Diagnostics {
+			if d.String() != tt.expected[i] {
+				t.Errorf("%q: diagnostic %d wrong. want=%q, got=%q", tt.input, i, tt.expected[i], d.String())
+			}
+		}
+	}
+}
+
+func TestPredeclared(t *testing.T) {
+	_, result := resolve(t, `puts("hello");`, "puts")
+
+	if len(result.Diagnostics) != 0 {
+		t.Fatalf("unexpected diagnostics: %v", result.Diagnostics)
+	}
+}
+
+func TestUses(t *testing.T) {
+	program, result := resolve(t, "let x = 1; let f = fn(x) { x }; f(x);")
+
+	var idents []*ast.Identifier
+	ast.Inspect(program, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Identifier); ok && ident.Value == "x" {
+			idents = append(idents, ident)
+		}
+		return true
+	})
+
+	if len(idents) != 4 {
+		t.Fatalf("expected 4 identifiers named x, got=%d", len(idents))
+	}
+
+	outer, param := result.Uses[idents[0]], result.Uses[idents[1]]
+	if outer == nil || outer.Kind != Let {
+		t.Fatalf("first x is not a let binding: %+v", outer)
+	}
+	if param == nil || param.Kind != Parameter {
+		t.Fatalf("second x is not a parameter: %+v", param)
+	}
+
+	if result.Uses[idents[2]] != param {
+		t.Errorf("x in the function body does not resolve to the parameter")
+	}
+	if result.Uses[idents[3]] != outer {
+		t.Errorf("x in the call does not resolve to the let binding")
+	}
+
+	if len(outer.Uses) != 1 || len(param.Uses) != 1 {
+		t.Errorf("wrong number of uses. outer=%d, param=%d", len(outer.Uses), len(param.Uses))
+	}
+
+	if result.HasErrors() {
+		t.Errorf("shadowing should only be a warning: %v", result.Diagnostics)
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	input := `
+let g = 1;
+let f = fn(a, b) {
+	let c = a;
+	if (b) { let d = 2; }
+	let c = b;
+	fn(x) { a + x + c + g }
+};
+`
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	Annotate(program)
+
+	var fns []*ast.FunctionLiteral
+	var idents []*ast.Identifier
+	ast.Inspect(program, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FunctionLiteral:
+			fns = append(fns, n)
+		case *ast.Identifier:
+			idents = append(idents, n)
+		}
+		return true
+	})
+
+	if len(fns) != 2 {
+		t.Fatalf("expected 2 function literals, got=%d", len(fns))
+	}
+
+	// parameters come first, a second `let c` reuses the slot
+	if got := strings.Join(fns[0].Locals, ","); got != "a,b,c,d" {
+		t.Errorf("wrong slots for outer function. want=%q, got=%q", "a,b,c,d", got)
+	}
+	if got := strings.Join(fns[1].Locals, ","); got != "x" {
+		t.Errorf("wrong slots for inner function. want=%q, got=%q", "x", got)
+	}
+
+	type address struct {
+		local       bool
+		depth, slot int
+	}
+
+	expected := []struct {
+		name string
+		address
+	}{
+		{"g", address{}},
+		{"f", address{}},
+		{"a", address{true, 0, 0}},
+		{"b", address{true, 0, 1}},
+		{"c", address{true, 0, 2}},
+		{"a", address{true, 0, 0}},
+		{"b", address{true, 0, 1}},
+		{"d", address{true, 0, 3}},
+		{"c", address{true, 0, 2}},
+		{"b", address{true, 0, 1}},
+		{"x", address{true, 0, 0}},
+		{"a", address{true, 1, 0}},
+		{"x", address{true, 0, 0}},
+		{"c", address{true, 1, 2}},
+		{"g", address{}},
+	}
+
+	if len(idents) != len(expected) {
+		t.Fatalf("wrong number of identifiers. want=%d, got=%d", len(expected), len(idents))
+	}
+
+	for i, tt := range expected {
+		ident := idents[i]
+		got := address{ident.Local, ident.Depth, ident.Slot}
+		if ident.Value != tt.name || got != tt.address {
+			t.Errorf("identifier %d wrong. want=%s %+v, got=%s %+v", i, tt.name, tt.address, ident.Value, got)
+		}
+	}
+}
+
+func TestAnnotateSharedNode(t *testing.T) {
+	program := parser.New(lexer.New("let f = fn(n) { n + fn() { m }() };")).ParseProgram()
+
+	// put the same `n` in the inner function in place of `m`, like splicing a
+	// macro argument twice without copying it would
+	var outer, inner *ast.Identifier
+	ast.Inspect(program, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.InfixExpression:
+			outer = n.Left.(*ast.Identifier)
+		case *ast.ExpressionStatement:
+			if _, ok := n.Expression.(*ast.Identifier); ok {
+				n.Expression = outer
+			}
+		}
+		return true
+	})
+
+	Annotate(program)
+
+	ast.Inspect(program, func(n ast.Node) bool {
+		if stmt, ok := n.(*ast.ExpressionStatement); ok {
+			if ident, ok := stmt.Expression.(*ast.Identifier); ok {
+				inner = ident
+			}
+		}
+		return true
+	})
+
+	if inner == nil || inner == outer {
+		t.Fatalf("the shared identifier was not copied")
+	}
+	if !outer.Local || outer.Depth != 0 || outer.Slot != 0 {
+		t.Errorf("outer n has address %t %d %d, want depth 0 slot 0", outer.Local, outer.Depth, outer.Slot)
+	}
+	if !inner.Local || inner.Depth != 1 || inner.Slot != 0 {
+		t.Errorf("inner n has address %t %d %d, want depth 1 slot 0", inner.Local, inner.Depth, inner.Slot)
+	}
+}
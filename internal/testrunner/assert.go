@@ -0,0 +1,70 @@
+package testrunner
+
+import (
+	"fmt"
+	"strings"
+
+	"monkey-lang.z9fr.xyz/internal/evaluator"
+	"monkey-lang.z9fr.xyz/internal/object"
+)
+
+// Bind adds the assertion builtins to `env`. each one returns an error that
+// ends the test when the assertion does not hold
+//
+//	assert(condition, message...)   condition is truthy
+//	assert_eq(got, want)            both have the same type and `Inspect()`
+//	assert_error(fn, substring...)  calling fn fails, with the substring in
+//	                                its message
+func Bind(env *object.Environment) {
+	evaluator.Bind(env, "assert", assert)
+	evaluator.Bind(env, "assert_eq", assertEq)
+	evaluator.Bind(env, "assert_error", assertError)
+}
+
+func assert(cond object.Object, message ...string) error {
+	if cond != evaluator.FALSE && cond != evaluator.NULL {
+		return nil
+	}
+
+	if len(message) == 0 {
+		return fmt.Errorf("assertion failed")
+	}
+	return fmt.Errorf("assertion failed: %s", strings.Join(message, " "))
+}
+
+func assertEq(got, want object.Object) error {
+	if got.Type() == want.Type() && got.Inspect() == want.Inspect() {
+		return nil
+	}
+
+	if got.Type() != want.Type() {
+		return fmt.Errorf("assert_eq: got %s %s, want %s %s", got.Type(), got.Inspect(), want.Type(), want.Inspect())
+	}
+	return fmt.Errorf("assert_eq: got %s, want %s", got.Inspect(), want.Inspect())
+}
+
+func assertError(fn object.Object, substring ...string) error {
+	switch fn.(type) {
+	case *object.Function, *object.GoFunction:
+	default:
+		return fmt.Errorf("assert_error: got %s, want a function to call", fn.Type())
+	}
+
+	result := evaluator.Call(fn)
+	if result == nil {
+		// an empty function
+		result = evaluator.NULL
+	}
+
+	errObj, ok := result.(*object.Error)
+	if !ok {
+		return fmt.Errorf("assert_error: got %s, want an error", result.Inspect())
+	}
+
+	for _, s := range substring {
+		if !strings.Contains(errObj.Message, s) {
+			return fmt.Errorf("assert_error: got error %q, want one containing %q", errObj.Message, s)
+		}
+	}
+	return nil
+}
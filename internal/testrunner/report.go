@@ -0,0 +1,159 @@
+package testrunner
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// WriteText writes the results the way `go test` does: failed tests with their
+// error and a line for each file. `verbose` lists the tests that passed too
+func WriteText(w io.Writer, files []*File, verbose bool) error {
+	var b strings.Builder
+
+	for _, f := range files {
+		for _, r := range f.Tests {
+			if !r.Passed() {
+				fmt.Fprintf(&b, "--- FAIL: %s (%s)\n", r.Name, seconds(r.Elapsed))
+				fmt.Fprintf(&b, "    %s\n", r.Failure)
+			} else if verbose {
+				fmt.Fprintf(&b, "--- PASS: %s (%s)\n", r.Name, seconds(r.Elapsed))
+			}
+		}
+
+		switch {
+		case f.Err != "":
+			fmt.Fprintf(&b, "FAIL\t%s\t%s\n", f.Name, f.Err)
+		case len(f.Tests) == 0:
+			fmt.Fprintf(&b, "?\t%s\t[no tests to run]\n", f.Name)
+		case !f.Passed():
+			fmt.Fprintf(&b, "FAIL\t%s\t%s\n", f.Name, seconds(f.Elapsed))
+		default:
+			fmt.Fprintf(&b, "ok\t%s\t%s\n", f.Name, seconds(f.Elapsed))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3fs", d.Seconds())
+}
+
+// WriteTAP writes the results in the Test Anything Protocol, version 13. a
+// file that failed to evaluate is one failed test point
+func WriteTAP(w io.Writer, files []*File) error {
+	var b strings.Builder
+	n := 0
+
+	point := func(ok bool, description, failure string, elapsed time.Duration) {
+		n++
+		status := "ok"
+		if !ok {
+			status = "not ok"
+		}
+		fmt.Fprintf(&b, "%s %d - %s\n", status, n, description)
+
+		if !ok {
+			fmt.Fprintf(&b, "  ---\n  message: %q\n  duration_ms: %.3f\n  ...\n", failure, float64(elapsed)/float64(time.Millisecond))
+		}
+	}
+
+	for _, f := range files {
+		for _, r := range f.Tests {
+			point(r.Passed(), f.Name+": "+r.Name, r.Failure, r.Elapsed)
+		}
+		if f.Err != "" {
+			point(false, f.Name, f.Err, f.Elapsed)
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "TAP version 13\n1..%d\n%s", n, b.String())
+	return err
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the results as JUnit XML, a test suite for each file. a
+// file that failed to evaluate gets a test case named `setup` with an error
+func WriteJUnit(w io.Writer, files []*File) error {
+	var suites junitSuites
+	var total time.Duration
+
+	for _, f := range files {
+		suite := junitSuite{Name: f.Name, Time: junitTime(f.Elapsed)}
+
+		for _, r := range f.Tests {
+			c := junitCase{Name: r.Name, Classname: f.Name, Time: junitTime(r.Elapsed)}
+			if !r.Passed() {
+				c.Failure = &junitProblem{Message: r.Failure, Text: r.Failure}
+				suite.Failures++
+			}
+			suite.Cases = append(suite.Cases, c)
+		}
+		if f.Err != "" {
+			suite.Cases = append(suite.Cases, junitCase{
+				Name:      "setup",
+				Classname: f.Name,
+				Time:      junitTime(0),
+				Error:     &junitProblem{Message: f.Err, Text: f.Err},
+			})
+			suite.Errors++
+		}
+		suite.Tests = len(suite.Cases)
+
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+		suites.Suites = append(suites.Suites, suite)
+		total += f.Elapsed
+	}
+	suites.Time = junitTime(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func junitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
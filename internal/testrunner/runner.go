@@ -0,0 +1,148 @@
+package testrunner
+
+/*
+testrunner
+
+runs the tests in monkey test files. a test is a function without parameters
+bound at the top level with a name that starts with `test_`:
+
+	let test_add = fn() {
+		assert_eq(add(1, 2), 3);
+	};
+
+every test gets an environment of its own: the whole file is evaluated in a
+fresh one, then the test function is called. whatever the file does at the top
+level is the setup of each test, and nothing a test changes is seen by the next
+one. a file with no tests to run is evaluated once all the same, so an error
+in it still fails the file.
+
+a test fails when it ends with an error, the assertion builtins `Bind` adds are
+the way to make one
+*/
+
+import (
+	"strings"
+	"time"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/evaluator"
+	"monkey-lang.z9fr.xyz/internal/object"
+)
+
+// Result is how one test went
+type Result struct {
+	Name string
+	// the error the test ended with, "" when it passed
+	Failure string
+	Elapsed time.Duration
+}
+
+func (r Result) Passed() bool { return r.Failure == "" }
+
+// File is how the tests of one file went
+type File struct {
+	Name string
+	// set when the file itself failed to evaluate, the tests after that did
+	// not run
+	Err     string
+	Tests   []Result
+	Elapsed time.Duration
+}
+
+// Passed reports whether the file and every test in it passed
+func (f *File) Passed() bool {
+	if f.Err != "" {
+		return false
+	}
+	for _, r := range f.Tests {
+		if !r.Passed() {
+			return false
+		}
+	}
+	return true
+}
+
+// Tests returns the names of the tests in `program`, in the order they are
+// defined
+func Tests(program *ast.Program) []string {
+	var names []string
+	seen := map[string]bool{}
+
+	for _, stmt := range program.Statements {
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok || !strings.HasPrefix(let.Name.Value, "test_") || seen[let.Name.Value] {
+			continue
+		}
+		if _, ok := let.Value.(*ast.FunctionLiteral); ok {
+			names = append(names, let.Name.Value)
+			seen[let.Name.Value] = true
+		}
+	}
+
+	return names
+}
+
+// Run runs the tests of `program`, from the file `name`, that `match`
+// accepts. `newEnv` makes the environment for each test, the assertions are
+// added to it. the program should have been through macro expansion and
+// `resolver.Annotate`, like any program about to be evaluated
+func Run(name string, program *ast.Program, newEnv func() *object.Environment, match func(test string) bool) *File {
+	start := time.Now()
+	f := &File{Name: name}
+
+	setup := func() (*object.Environment, bool) {
+		env := newEnv()
+		Bind(env)
+
+		if errObj, ok := evaluator.Eval(program, env).(*object.Error); ok {
+			f.Err = errObj.Message
+			return nil, false
+		}
+		return env, true
+	}
+
+	ran := false
+	for _, test := range Tests(program) {
+		if match != nil && !match(test) {
+			continue
+		}
+
+		// the setup is the same for every test, when it fails once the
+		// rest would fail the same way
+		ran = true
+		env, ok := setup()
+		if !ok {
+			break
+		}
+
+		f.Tests = append(f.Tests, runTest(test, env))
+	}
+
+	// a file without tests to run is still evaluated once, so that a broken
+	// one does not pass for having nothing in it
+	if !ran {
+		setup()
+	}
+
+	f.Elapsed = time.Since(start)
+	return f
+}
+
+func runTest(name string, env *object.Environment) Result {
+	r := Result{Name: name}
+
+	fn, ok := env.Get(name)
+	if f, isFn := fn.(*object.Function); !ok || !isFn || len(f.Parameters) != 0 {
+		r.Failure = name + " is not a function without parameters"
+		return r
+	}
+
+	start := time.Now()
+	result := evaluator.Call(fn)
+	r.Elapsed = time.Since(start)
+
+	if errObj, ok := result.(*object.Error); ok {
+		r.Failure = errObj.Message
+	}
+	return r
+}
@@ -0,0 +1,268 @@
+package testrunner
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/lexer"
+	"monkey-lang.z9fr.xyz/internal/object"
+	"monkey-lang.z9fr.xyz/internal/parser"
+	"monkey-lang.z9fr.xyz/internal/resolver"
+)
+
+func parse(t *testing.T, src string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	resolver.Annotate(program)
+	return program
+}
+
+// failures runs the tests in `src` and returns the failure of each, "" for
+// the ones that passed
+func failures(t *testing.T, src string) (map[string]string, *File) {
+	t.Helper()
+
+	f := Run("test.mk", parse(t, src), object.NewEnvironment, nil)
+	got := map[string]string{}
+	for _, r := range f.Tests {
+		got[r.Name] = r.Failure
+	}
+	return got, f
+}
+
+func TestAssertions(t *testing.T) {
+	src := `
+let add = fn(a, b) { a + b };
+let test_assert = fn() { assert(add(1, 1) == 2) };
+let test_assert_fails = fn() { assert(1 > 2, "one is not more than two") };
+let test_assert_null = fn() { assert(if (false) { 1 }) };
+let test_eq = fn() { assert_eq(add(1, 2), 3); assert_eq("a" + "b", "ab") };
+let test_eq_fails = fn() { assert_eq(add(1, 2), 4) };
+let test_eq_types = fn() { assert_eq(1, "1") };
+let test_error = fn() { assert_error(fn() { 1 / 0 }, "division") };
+let test_error_none = fn() { assert_error(fn() { 1 }) };
+let test_error_message = fn() { assert_error(fn() { 1 / 0 }, "overflow") };
+let test_error_not_fn = fn() { assert_error(1) };
+let test_runtime_error = fn() { 1 + true };
+let test_stops = fn() { assert(false); assert(true) };
+let test_empty = fn() { };
+let nothing = fn() { };
+let test_eq_nothing = fn() { assert_eq(nothing(), 1) };
+let test_eq_nothing_both = fn() { assert_eq(nothing(), nothing()) };
+let test_assert_nothing = fn() { assert(nothing()) };
+`
+	expected := map[string]string{
+		"test_assert":        "",
+		"test_assert_fails":  "assertion failed: one is not more than two",
+		"test_assert_null":   "assertion failed",
+		"test_eq":            "",
+		"test_eq_fails":      "assert_eq: got 3, want 4",
+		"test_eq_types":      "assert_eq: got INTEGER 1, want STRING 1",
+		"test_error":         "",
+		"test_error_none":    "assert_error: got 1, want an error",
+		"test_error_message": `assert_error: got error "division by zero", want one containing "overflow"`,
+		"test_error_not_fn":  "assert_error: got INTEGER, want a function to call",
+		"test_runtime_error": "type mismatch: INTEGER + BOOLEAN",
+		"test_stops":         "assertion failed",
+		"test_empty":         "",
+		// a function without a body returns null, not nothing
+		"test_eq_nothing":      "assert_eq: got NULL null, want INTEGER 1",
+		"test_eq_nothing_both": "",
+		"test_assert_nothing":  "assertion failed",
+	}
+
+	got, _ := failures(t, src)
+	if len(got) != len(expected) {
+		t.Errorf("ran %d tests, want %d: %v", len(got), len(expected), got)
+	}
+	for name, want := range expected {
+		if got[name] != want {
+			t.Errorf("%s: got %q, want %q", name, got[name], want)
+		}
+	}
+}
+
+func TestTests(t *testing.T) {
+	program := parse(t, `
+let test_b = fn() { 1 };
+let helper = fn() { 1 };
+let test_a = fn() { 1 };
+let test_value = 5;
+let f = fn() { let test_inner = fn() { 1 }; };
+let test_b = fn() { 2 };
+`)
+
+	got := Tests(program)
+	expected := []string{"test_b", "test_a"}
+	if len(got) != len(expected) || got[0] != expected[0] || got[1] != expected[1] {
+		t.Errorf("got %v, want %v", got, expected)
+	}
+}
+
+func TestIsolation(t *testing.T) {
+	// every test starts from the top level of the file again
+	got, _ := failures(t, `
+let counter = fn() { 0 };
+let test_first = fn() { let counter = 1; assert_eq(counter, 1) };
+let test_second = fn() { assert_eq(counter(), 0) };
+`)
+	for name, failure := range got {
+		if failure != "" {
+			t.Errorf("%s: %s", name, failure)
+		}
+	}
+
+	// each test gets its own environment
+	var envs []*object.Environment
+	newEnv := func() *object.Environment {
+		env := object.NewEnvironment()
+		envs = append(envs, env)
+		return env
+	}
+	Run("test.mk", parse(t, "let test_a = fn() { 1 }; let test_b = fn() { 2 };"), newEnv, nil)
+	if len(envs) != 2 || envs[0] == envs[1] {
+		t.Errorf("tests did not get an environment each: %v", envs)
+	}
+}
+
+func TestSetupError(t *testing.T) {
+	got, f := failures(t, "let test_a = fn() { 1 }; let x = 1 / 0;")
+
+	if f.Err != "division by zero" || len(got) != 0 || f.Passed() {
+		t.Errorf("got error %q and tests %v, want the setup to fail", f.Err, got)
+	}
+}
+
+func TestSetupWithoutTests(t *testing.T) {
+	tests := []struct {
+		src   string
+		match func(string) bool
+		err   string
+	}{
+		{"let x = 1 / 0;", nil, "division by zero"},
+		{"let x = 1;", nil, ""},
+		// no test matches, the file still has to evaluate
+		{"let test_a = fn() { 1 }; let x = y;", func(string) bool { return false }, "identifier not found: y"},
+	}
+
+	for _, tt := range tests {
+		f := Run("test.mk", parse(t, tt.src), object.NewEnvironment, tt.match)
+
+		if f.Err != tt.err || len(f.Tests) != 0 || f.Passed() != (tt.err == "") {
+			t.Errorf("%q: got error %q and tests %v, want error %q", tt.src, f.Err, f.Tests, tt.err)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	match := func(name string) bool { return name == "test_b" }
+	f := Run("test.mk", parse(t, "let test_a = fn() { 1 }; let test_b = fn() { 2 };"), object.NewEnvironment, match)
+
+	if len(f.Tests) != 1 || f.Tests[0].Name != "test_b" {
+		t.Errorf("got %v, want only test_b", f.Tests)
+	}
+}
+
+// results are made by hand so the times are known
+var results = []*File{
+	{
+		Name:    "math_test.mk",
+		Elapsed: 3 * time.Millisecond,
+		Tests: []Result{
+			{Name: "test_add", Elapsed: time.Millisecond},
+			{Name: "test_sub", Failure: "assert_eq: got 1, want 2", Elapsed: 2 * time.Millisecond},
+		},
+	},
+	{Name: "broken_test.mk", Err: "identifier not found: x", Elapsed: time.Millisecond},
+	{Name: "empty_test.mk"},
+}
+
+func TestWriteText(t *testing.T) {
+	tests := []struct {
+		verbose  bool
+		expected string
+	}{
+		{false, `--- FAIL: test_sub (0.002s)
+    assert_eq: got 1, want 2
+FAIL	math_test.mk	0.003s
+FAIL	broken_test.mk	identifier not found: x
+?	empty_test.mk	[no tests to run]
+`},
+		{true, `--- PASS: test_add (0.001s)
+--- FAIL: test_sub (0.002s)
+    assert_eq: got 1, want 2
+FAIL	math_test.mk	0.003s
+FAIL	broken_test.mk	identifier not found: x
+?	empty_test.mk	[no tests to run]
+`},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if err := WriteText(&out, results, tt.verbose); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != tt.expected {
+			t.Errorf("verbose=%t: got\n%s\nwant\n%s", tt.verbose, out.String(), tt.expected)
+		}
+	}
+}
+
+func TestWriteTAP(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteTAP(&out, results); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `TAP version 13
+1..3
+ok 1 - math_test.mk: test_add
+not ok 2 - math_test.mk: test_sub
+  ---
+  message: "assert_eq: got 1, want 2"
+  duration_ms: 2.000
+  ...
+not ok 3 - broken_test.mk
+  ---
+  message: "identifier not found: x"
+  duration_ms: 1.000
+  ...
+`
+	if out.String() != expected {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), expected)
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteJUnit(&out, results); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="3" failures="1" errors="1" time="0.004">
+  <testsuite name="math_test.mk" tests="2" failures="1" errors="0" time="0.003">
+    <testcase name="test_add" classname="math_test.mk" time="0.001"></testcase>
+    <testcase name="test_sub" classname="math_test.mk" time="0.002">
+      <failure message="assert_eq: got 1, want 2">assert_eq: got 1, want 2</failure>
+    </testcase>
+  </testsuite>
+  <testsuite name="broken_test.mk" tests="1" failures="0" errors="1" time="0.001">
+    <testcase name="setup" classname="broken_test.mk" time="0.000">
+      <error message="identifier not found: x">identifier not found: x</error>
+    </testcase>
+  </testsuite>
+  <testsuite name="empty_test.mk" tests="0" failures="0" errors="0" time="0.000"></testsuite>
+</testsuites>
+`
+	if out.String() != expected {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), expected)
+	}
+}
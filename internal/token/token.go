@@ -1,5 +1,10 @@
 package token
 
+import (
+	"fmt"
+	"sort"
+)
+
 type TokenType string
 
 const (
@@ -7,8 +12,9 @@ const (
 	EOF     = "EOF"     // end of file
 	// Identifiers + literals
 
-	IDENT = "IDENT" // add, foobar, x, y, ...
-	INT   = "INT"   // 1343456
+	IDENT  = "IDENT"  // add, foobar, x, y, ...
+	INT    = "INT"    // 1343456
+	STRING = "STRING" // "foobar"
 
 	// Operators
 	ASSIGN   = "="
@@ -25,6 +31,7 @@ const (
 
 	// Delimiters
 	COMMA     = ","
+	DOT       = "."
 	SEMICOLON = ";"
 	LPAREN    = "("
 	RPAREN    = ")"
@@ -39,11 +46,53 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	MACRO    = "MACRO"
 )
 
 type Token struct {
-	Type    TokenType
-	Literal string
+	Type    TokenType `json:"type"`
+	Literal string    `json:"literal"`
+	Pos     Position  `json:"pos"`
+}
+
+// Position is where a token starts in the source. `Line` and `Column` count from
+// 1, `Column` and `Offset` are in bytes. the zero value means "unknown", used by
+// tokens that don't come from the lexer
+type Position struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// End is the position just after the token. string literals don't include their
+// quotes in `Literal`, so those are added back
+func (t Token) End() Position {
+	if !t.Pos.IsValid() {
+		return t.Pos
+	}
+
+	text := t.Literal
+	if t.Type == STRING {
+		text = `"` + text + `"`
+	}
+
+	end := t.Pos
+	for i := 0; i < len(text); i++ {
+		end.Offset++
+		if text[i] == '\n' {
+			end.Line++
+			end.Column = 1
+		} else {
+			end.Column++
+		}
+	}
+	return end
 }
 
 // we need to identify user-defined functions apart from language keywords
@@ -55,6 +104,7 @@ var keywords = map[string]TokenType{
 	"if":     IF,
 	"else":   ELSE,
 	"return": RETURN,
+	"macro":  MACRO,
 }
 
 // checks if keyword is a given identifier and is a keyword.
@@ -65,3 +115,13 @@ func LookupIdent(ident string) TokenType {
 	}
 	return IDENT
 }
+
+// Keywords returns all keywords of the language, sorted
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}
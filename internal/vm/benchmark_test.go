@@ -0,0 +1,32 @@
+package vm
+
+import (
+	"testing"
+
+	"monkey-lang.z9fr.xyz/internal/compiler"
+	"monkey-lang.z9fr.xyz/internal/lexer"
+	"monkey-lang.z9fr.xyz/internal/parser"
+)
+
+// the same program as the evaluator's BenchmarkFibonacci, to compare the engines
+func BenchmarkFibonacci(b *testing.B) {
+	program := parser.New(lexer.New(`
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+fib(20);
+`)).ParseProgram()
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		b.Fatal(err)
+	}
+	bytecode := comp.Bytecode()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		machine := New(bytecode, nil)
+		if err := machine.Run(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package vm
+
+import (
+	"monkey-lang.z9fr.xyz/internal/code"
+	"monkey-lang.z9fr.xyz/internal/object"
+)
+
+// Frame is a call in progress: the closure being run, where in its instructions
+// we are, where its part of the stack starts and the environment with its locals
+type Frame struct {
+	cl          *object.Closure
+	ip          int
+	basePointer int
+	env         *object.Environment
+}
+
+func NewFrame(cl *object.Closure, basePointer int, env *object.Environment) Frame {
+	return Frame{
+		cl:          cl,
+		ip:          -1,
+		basePointer: basePointer,
+		env:         env,
+	}
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
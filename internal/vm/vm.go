@@ -0,0 +1,435 @@
+package vm
+
+/*
+vm
+
+runs the bytecode from the compiler on a stack. values are the same objects the
+evaluator uses, `NULL`, `TRUE` and `FALSE` included, and so are the runtime
+errors: `Run` returns an error with the message the evaluator would put in its
+`*object.Error`
+*/
+
+import (
+	"errors"
+	"fmt"
+
+	"monkey-lang.z9fr.xyz/internal/code"
+	"monkey-lang.z9fr.xyz/internal/compiler"
+	"monkey-lang.z9fr.xyz/internal/evaluator"
+	"monkey-lang.z9fr.xyz/internal/object"
+)
+
+const StackSize = 2048
+const MaxFrames = 1024
+
+var (
+	NULL  = evaluator.NULL
+	TRUE  = evaluator.TRUE
+	FALSE = evaluator.FALSE
+)
+
+type VM struct {
+	constants []object.Object
+	globals   *object.Environment
+
+	stack []object.Object
+	sp    int // always points to the next free slot. top of the stack is `stack[sp-1]`
+
+	frames      []Frame
+	framesIndex int
+
+	result object.Object
+}
+
+// New prepares `bytecode` to run. globals the program uses without defining
+// them are taken from `predeclared`, which may be nil
+func New(bytecode *compiler.Bytecode, predeclared *object.Environment) *VM {
+	globals := object.NewFrame(nil, bytecode.Globals)
+	if predeclared != nil {
+		for i, name := range bytecode.Globals {
+			if val, ok := predeclared.Get(name); ok {
+				globals.SetSlot(i, val)
+			}
+		}
+	}
+
+	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions}
+	mainClosure := &object.Closure{Fn: mainFn, Env: globals}
+
+	frames := make([]Frame, MaxFrames)
+	frames[0] = NewFrame(mainClosure, 0, globals)
+
+	return &VM{
+		constants:   bytecode.Constants,
+		globals:     globals,
+		stack:       make([]object.Object, StackSize),
+		frames:      frames,
+		framesIndex: 1,
+	}
+}
+
+// Result is the value of the program once `Run` is done: the value of its last
+// statement if that is an expression, or of a top level `return`. it is nil
+// otherwise, like the result of `evaluator.Eval`
+func (vm *VM) Result() object.Object {
+	return vm.result
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return &vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f Frame) error {
+	if vm.framesIndex >= MaxFrames {
+		return errors.New("stack overflow")
+	}
+	vm.frames[vm.framesIndex] = f
+	vm.framesIndex++
+	return nil
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return &vm.frames[vm.framesIndex]
+}
+
+func (vm *VM) Run() error {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+
+		ip = vm.currentFrame().ip
+		ins = vm.currentFrame().Instructions()
+		op = code.Opcode(ins[ip])
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			if err := vm.push(vm.constants[constIndex]); err != nil {
+				return err
+			}
+
+		case code.OpPop:
+			vm.pop()
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan:
+			if err := vm.executeBinaryOperation(op); err != nil {
+				return err
+			}
+
+		case code.OpTrue:
+			if err := vm.push(TRUE); err != nil {
+				return err
+			}
+
+		case code.OpFalse:
+			if err := vm.push(FALSE); err != nil {
+				return err
+			}
+
+		case code.OpNull:
+			if err := vm.push(NULL); err != nil {
+				return err
+			}
+
+		case code.OpBang:
+			if err := vm.push(nativeBoolToBooleanObject(!isTruthy(vm.pop()))); err != nil {
+				return err
+			}
+
+		case code.OpMinus:
+			operand := vm.pop()
+			integer, ok := operand.(*object.Integer)
+			if !ok {
+				return fmt.Errorf("unknown operator: -%s", operand.Type())
+			}
+			if err := vm.push(newInteger(-integer.Value)); err != nil {
+				return err
+			}
+
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip = pos - 1
+
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if !isTruthy(vm.pop()) {
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			vm.globals.SetSlot(int(globalIndex), vm.pop())
+
+		case code.OpGetGlobal:
+			globalIndex := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			val, ok := vm.globals.Slot(0, globalIndex)
+			if !ok {
+				return fmt.Errorf("identifier not found: %s", vm.globals.SlotName(0, globalIndex))
+			}
+			if err := vm.push(val); err != nil {
+				return err
+			}
+
+		case code.OpSetLocal:
+			slot := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			vm.currentFrame().env.SetSlot(int(slot), vm.pop())
+
+		case code.OpGetLocal:
+			depth := int(code.ReadUint8(ins[ip+1:]))
+			slot := int(code.ReadUint8(ins[ip+2:]))
+			vm.currentFrame().ip += 2
+
+			env := vm.currentFrame().env
+			val, ok := env.Slot(depth, slot)
+			if !ok {
+				// not bound yet, the name may be bound further out. this is
+				// what the evaluator does too
+				name := env.SlotName(depth, slot)
+				if val, ok = env.Get(name); !ok {
+					return fmt.Errorf("identifier not found: %s", name)
+				}
+			}
+			if err := vm.push(val); err != nil {
+				return err
+			}
+
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			fn := vm.constants[constIndex].(*object.CompiledFunction)
+			closure := &object.Closure{Fn: fn, Env: vm.currentFrame().env}
+			if err := vm.push(closure); err != nil {
+				return err
+			}
+
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			if err := vm.executeCall(int(numArgs)); err != nil {
+				return err
+			}
+
+		case code.OpReturnValue, code.OpReturn:
+			returnValue := object.Object(NULL)
+			if op == code.OpReturnValue {
+				returnValue = vm.pop()
+			}
+
+			frame := vm.popFrame()
+			if vm.framesIndex == 0 {
+				// the program itself is done
+				if op == code.OpReturnValue {
+					vm.result = returnValue
+				}
+				return nil
+			}
+
+			vm.sp = frame.basePointer - 1
+			if err := vm.push(returnValue); err != nil {
+				return err
+			}
+
+		case code.OpField:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			name := vm.constants[constIndex].(*object.String).Value
+			result := evaluator.EvalFieldExpression(vm.pop(), name)
+			if errObj, ok := result.(*object.Error); ok {
+				return errors.New(errObj.Message)
+			}
+			if err := vm.push(result); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (vm *VM) executeCall(numArgs int) error {
+	callee := vm.stack[vm.sp-1-numArgs]
+
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.GoFunction:
+		args := make([]object.Object, numArgs)
+		copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+
+		result := evaluator.ApplyGoFunction(callee, args)
+		if errObj, ok := result.(*object.Error); ok {
+			return errors.New(errObj.Message)
+		}
+
+		vm.sp = vm.sp - numArgs - 1
+		return vm.push(result)
+	default:
+		return fmt.Errorf("not a function: %s", callee.Type())
+	}
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
+	fn := cl.Fn
+	if numArgs != len(fn.Parameters) {
+		return fmt.Errorf("wrong number of arguments: want %d, got %d", len(fn.Parameters), numArgs)
+	}
+
+	basePointer := vm.sp - numArgs
+	env := object.NewFrame(cl.Env, fn.Locals)
+	for i, slot := range fn.Parameters {
+		env.SetSlot(slot, vm.stack[basePointer+i])
+	}
+
+	if err := vm.pushFrame(NewFrame(cl, basePointer, env)); err != nil {
+		return err
+	}
+
+	// the arguments live in the environment now
+	vm.sp = basePointer
+	return nil
+}
+
+func (vm *VM) executeBinaryOperation(op code.Opcode) error {
+	right := vm.pop()
+	left := vm.pop()
+
+	// the same order of checks as `evalInfixExpression`, which decides which
+	// error a mix of types gets
+	switch {
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		return vm.executeIntegerOperation(op, left.(*object.Integer).Value, right.(*object.Integer).Value)
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return vm.executeStringOperation(op, left, right)
+	case op == code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(left == right))
+	case op == code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(left != right))
+	case left.Type() != right.Type():
+		return fmt.Errorf("type mismatch: %s %s %s", left.Type(), operators[op], right.Type())
+	default:
+		return fmt.Errorf("unknown operator: %s %s %s", left.Type(), operators[op], right.Type())
+	}
+}
+
+var operators = map[code.Opcode]string{
+	code.OpAdd:         "+",
+	code.OpSub:         "-",
+	code.OpMul:         "*",
+	code.OpDiv:         "/",
+	code.OpEqual:       "==",
+	code.OpNotEqual:    "!=",
+	code.OpGreaterThan: ">",
+	code.OpLessThan:    "<",
+}
+
+func (vm *VM) executeIntegerOperation(op code.Opcode, left, right int64) error {
+	switch op {
+	case code.OpAdd:
+		return vm.push(newInteger(left + right))
+	case code.OpSub:
+		return vm.push(newInteger(left - right))
+	case code.OpMul:
+		return vm.push(newInteger(left * right))
+	case code.OpDiv:
+		if right == 0 {
+			return errors.New("division by zero")
+		}
+		return vm.push(newInteger(left / right))
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(left == right))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(left != right))
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(left > right))
+	default:
+		return vm.push(nativeBoolToBooleanObject(left < right))
+	}
+}
+
+func (vm *VM) executeStringOperation(op code.Opcode, left, right object.Object) error {
+	leftValue := left.(*object.String).Value
+	rightValue := right.(*object.String).Value
+
+	switch op {
+	case code.OpAdd:
+		return vm.push(&object.String{Value: leftValue + rightValue})
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue == rightValue))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(leftValue != rightValue))
+	default:
+		return fmt.Errorf("unknown operator: %s %s %s", left.Type(), operators[op], right.Type())
+	}
+}
+
+func (vm *VM) push(o object.Object) error {
+	if vm.sp >= StackSize {
+		return errors.New("stack overflow")
+	}
+
+	vm.stack[vm.sp] = o
+	vm.sp++
+
+	return nil
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case NULL:
+		return false
+	case TRUE:
+		return true
+	case FALSE:
+		return false
+	default:
+		return true
+	}
+}
+
+// integers are never changed once made, so the small ones are shared instead of
+// allocating one for every intermediate result
+var smallIntegers = func() [1024]*object.Integer {
+	var cache [1024]*object.Integer
+	for i := range cache {
+		cache[i] = &object.Integer{Value: int64(i)}
+	}
+	return cache
+}()
+
+func newInteger(value int64) *object.Integer {
+	if value >= 0 && value < int64(len(smallIntegers)) {
+		return smallIntegers[value]
+	}
+	return &object.Integer{Value: value}
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return TRUE
+	}
+	return FALSE
+}
@@ -0,0 +1,139 @@
+package vm
+
+import (
+	"strings"
+	"testing"
+
+	"monkey-lang.z9fr.xyz/internal/compiler"
+	"monkey-lang.z9fr.xyz/internal/conformance"
+	"monkey-lang.z9fr.xyz/internal/evaluator"
+	"monkey-lang.z9fr.xyz/internal/lexer"
+	"monkey-lang.z9fr.xyz/internal/object"
+	"monkey-lang.z9fr.xyz/internal/parser"
+)
+
+// run compiles and runs `input`, errors are turned in to `*object.Error` like
+// the evaluator returns them
+func run(t *testing.T, input string, predeclared *object.Environment) object.Object {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error for %q: %s", input, err)
+	}
+
+	machine := New(comp.Bytecode(), predeclared)
+	if err := machine.Run(); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+
+	return machine.Result()
+}
+
+func TestConformance(t *testing.T) {
+	cases, err := conformance.Cases()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range cases {
+		if err := c.Check(run(t, c.Input, nil)); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestResult(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // "" for no value
+	}{
+		{"1; 2", "2"},
+		{"let a = 1;", ""},
+		{"1; let a = 1;", ""},
+		{"return 3; 4", "3"},
+		{"if (false) { 1 }", "null"},
+		{"let f = fn() { let a = 1; }; f();", "null"},
+		{"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(1022);", "1022"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		result := run(t, tt.input, nil)
+
+		got := ""
+		if result != nil {
+			got = result.Inspect()
+		}
+		if got != tt.expected {
+			t.Errorf("%q: wrong result. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"5 / 0", "division by zero"},
+		{"5(1)", "not a function: INTEGER"},
+		{"let f = fn(a, b) { a }; f(1);", "wrong number of arguments: want 2, got 1"},
+		{"let f = fn() { f() }; f();", "stack overflow"},
+		// the frame of the program is one of `MaxFrames`
+		{"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(1023);", "stack overflow"},
+		{`"a" - "b"`, "unknown operator: STRING - STRING"},
+		{"true < 1", "type mismatch: BOOLEAN < INTEGER"},
+		{"5.x", "field access on non-struct: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		result := run(t, tt.input, nil)
+
+		errObj, ok := result.(*object.Error)
+		if !ok {
+			t.Errorf("%q: no error object returned. got=%T(%+v)", tt.input, result, result)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("%q: wrong error message. want=%q, got=%q", tt.input, tt.expected, errObj.Message)
+		}
+	}
+}
+
+type point struct{ X, Y int }
+
+func (p point) Sum() int { return p.X + p.Y }
+
+func TestGoInterop(t *testing.T) {
+	var out strings.Builder
+
+	env := object.NewEnvironment()
+	evaluator.Bind(env, "puts", func(args ...object.Object) {
+		for _, arg := range args {
+			out.WriteString(arg.Inspect() + "\n")
+		}
+	})
+	evaluator.Bind(env, "add", func(a, b int) int { return a + b })
+	evaluator.Bind(env, "p", point{X: 3, Y: 4})
+
+	result := run(t, `puts("hi", add(1, 2)); p.X + p.Sum();`, env)
+
+	if result == nil || result.Inspect() != "10" {
+		t.Errorf("wrong result. want=10, got=%v", result)
+	}
+	if out.String() != "hi\n3\n" {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+
+	result = run(t, `add(1)`, env)
+	if errObj, ok := result.(*object.Error); !ok || errObj.Message != "wrong number of arguments to add: want 2, got 1" {
+		t.Errorf("wrong result for a bad Go call. got=%v", result)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"monkey-lang.z9fr.xyz/internal/lsp"
+)
+
+func lspCommand(args []string) int {
+	fs := flag.NewFlagSet("lsp", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: monkey lsp")
+		fmt.Fprintln(fs.Output(), "serves the Language Server Protocol on stdin and stdout, for editors")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	// the same names `monkey run` binds, so using them is not an error
+	if err := lsp.Serve(os.Stdin, os.Stdout, newEnvironment(io.Discard).Names()); err != nil {
+		fmt.Fprintf(os.Stderr, "monkey lsp: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
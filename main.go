@@ -1,21 +1,102 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/user"
 
+	"monkey-lang.z9fr.xyz/internal/readline"
 	"monkey-lang.z9fr.xyz/internal/repl"
 )
 
+const usage = `usage:
+  monkey                        start the REPL (or run stdin when it is not a terminal)
+  monkey run [-check] [-O] [-engine=eval|vm] [-profile out.pb] <file>
+                                evaluate a file, tree walking or on the bytecode vm
+  monkey check [files...]       report undefined, unused and shadowed names
+  monkey test [-v] [-run regexp] [-format text|tap|junit] [-cover] [paths...]
+                                run the test_ functions in *_test.mk files
+  monkey debug <file>           step through a file with breakpoints
+  monkey dap                    serve the Debug Adapter Protocol on stdio, for editors
+  monkey lsp                    serve the Language Server Protocol on stdio, for editors
+  monkey build [-o out] <file>  parse a file once and save it as a binary .mkb program
+  monkey dump [-source] <file>  print a .mkb program
+  monkey fmt [-w] [files...]    format source files
+  monkey ast [-json|-dot] [file]
+                                print the parsed AST, as JSON or a Graphviz graph
+  monkey [-engine=vm] -e '<expr>'
+                                evaluate a one-liner
+`
+
+// commands maps a subcommand name to its entry point. each one gets the
+// arguments after the subcommand name and returns the process exit code
+var commands = map[string]func(args []string) int{
+	"run":   runCommand,
+	"check": checkCommand,
+	"test":  testCommand,
+	"debug": debugCommand,
+	"dap":   dapCommand,
+	"lsp":   lspCommand,
+	"build": buildCommand,
+	"dump":  dumpCommand,
+	"fmt":   fmtCommand,
+	"ast":   astCommand,
+}
+
 func main() {
-	user, err := user.Current()
+	os.Exit(monkey(os.Args[1:]))
+}
+
+func monkey(args []string) int {
+	if len(args) > 0 {
+		if cmd, ok := commands[args[0]]; ok {
+			return cmd(args[1:])
+		}
+	}
+
+	fs := flag.NewFlagSet("monkey", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(fs.Output(), usage) }
+	expr := fs.String("e", "", "evaluate `expr` and print the result")
+	engineName := fs.String("engine", "eval", "run with the tree walking `eval`uator or the bytecode `vm`")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
 
-	if err != nil {
-		panic(err)
+	run, ok := engines[*engineName]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "monkey: unknown engine %q\n", *engineName)
+		return 2
 	}
 
-	fmt.Printf("Hello %s, This is Monkey programming language!\n", user.Username)
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "monkey: unknown command %q\n", fs.Arg(0))
+		fs.Usage()
+		return 2
+	}
+
+	if *expr != "" {
+		return execute("-e", *expr, os.Stdout, os.Stderr, true, run)
+	}
+
+	// when input is piped in there is nobody to greet, so we run it as a script.
+	// a character device like /dev/null is not someone typing either
+	if !readline.IsTerminal(int(os.Stdin.Fd())) {
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "monkey: %s\n", err)
+			return 1
+		}
+		return execute("<stdin>", string(src), os.Stdout, os.Stderr, false, run)
+	}
+
+	if u, err := user.Current(); err == nil {
+		fmt.Printf("Hello %s, This is Monkey programming language!\n", u.Username)
+	}
 	fmt.Printf("Feel free to type in commands\n")
 	repl.Start(os.Stdin, os.Stdout)
+
+	return 0
 }
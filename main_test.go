@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runMonkey runs the command line with `args` the way a shell would, with
+// `stdin` as its input. it returns the exit code and what was written to
+// stdout and stderr
+func runMonkey(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+
+	in, err := os.Create(filepath.Join(t.TempDir(), "stdin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	if _, err := in.WriteString(stdin); err != nil {
+		t.Fatal(err)
+	}
+	in.Seek(0, io.SeekStart)
+
+	return runMonkeyFrom(t, in, args...)
+}
+
+// runMonkeyFrom is `runMonkey` with the file `in` as standard input
+func runMonkeyFrom(t *testing.T, in *os.File, args ...string) (int, string, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	out, err := os.Create(filepath.Join(dir, "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errOut, err := os.Create(filepath.Join(dir, "stderr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	defer errOut.Close()
+
+	oldIn, oldOut, oldErr := os.Stdin, os.Stdout, os.Stderr
+	os.Stdin, os.Stdout, os.Stderr = in, out, errOut
+	defer func() { os.Stdin, os.Stdout, os.Stderr = oldIn, oldOut, oldErr }()
+
+	code := monkey(args)
+
+	stdout, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr, err := os.ReadFile(errOut.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return code, string(stdout), string(stderr)
+}
+
+func writeFile(t *testing.T, dir, name, src string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// frame wraps a JSON message in the header the language server and the debug
+// adapter expect
+func frame(msgs ...string) string {
+	var b strings.Builder
+	for _, msg := range msgs {
+		fmt.Fprintf(&b, "Content-Length: %d\r\n\r\n%s", len(msg), msg)
+	}
+	return b.String()
+}
+
+func TestCommands(t *testing.T) {
+	dir := t.TempDir()
+	hello := writeFile(t, dir, "hello.monkey", "let add = fn(a, b) { a + b };\nputs(add(1, 2));\n")
+	arity := writeFile(t, dir, "arity.monkey", "let f = fn(a, b) { a };\nf(1);\n")
+	empty := writeFile(t, dir, "empty.monkey", "let f = fn() { };\nputs(f());\n")
+	undefined := writeFile(t, dir, "undefined.monkey", "let x = 1;\nputs(y);\n")
+	broken := writeFile(t, dir, "broken.monkey", "let = 1;\n")
+	tests := filepath.Join(dir, "tests")
+	os.Mkdir(tests, 0o755)
+	writeFile(t, tests, "math_test.mk", `let test_add = fn() { assert_eq(1 + 1, 2) };
+let test_bad = fn() { assert_eq(1, 2) };
+`)
+	setup := filepath.Join(dir, "setup")
+	os.Mkdir(setup, 0o755)
+	writeFile(t, setup, "setup_test.mk", "let x = 1 / 0;\n")
+	built := filepath.Join(dir, "hello.mkb")
+	missing := filepath.Join(dir, "missing.monkey")
+
+	cases := []struct {
+		args   []string
+		stdin  string
+		code   int
+		stdout string
+		stderr string
+	}{
+		// piped input runs as a script instead of starting the REPL
+		{nil, "puts(1 + 2);", 0, "3\n", ""},
+		{[]string{"-e", "1 + 2"}, "", 0, "3\n", ""},
+		{[]string{"-engine=vm", "-e", "2 * 3"}, "", 0, "6\n", ""},
+		{[]string{"-e", "1 + true"}, "", 1, "", "-e: ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{[]string{"-engine=x", "-e", "1"}, "", 2, "", `unknown engine "x"`},
+		{[]string{"nope"}, "", 2, "", `unknown command "nope"`},
+
+		{[]string{"run", hello}, "", 0, "3\n", ""},
+		{[]string{"run", "-engine=vm", hello}, "", 0, "3\n", ""},
+		{[]string{"run", "-O", hello}, "", 0, "3\n", ""},
+		{[]string{"run", empty}, "", 0, "null\n", ""},
+		{[]string{"run", arity}, "", 1, "", "ERROR: wrong number of arguments: want 2, got 1"},
+		{[]string{"run", "-check", undefined}, "", 1, "", "2:6: error: undefined: y"},
+		{[]string{"run", broken}, "", 1, "", "parser errors:"},
+		{[]string{"-e", "let bad = macro(x) { 1 / 0 }; bad(1)"}, "", 1, "", "-e: macro bad: division by zero"},
+		{[]string{"run", missing}, "", 1, "", "no such file or directory"},
+		{[]string{"run"}, "", 2, "", "usage: monkey run"},
+		{[]string{"run", "-engine=vm", "-profile", filepath.Join(dir, "out.pb"), hello}, "", 2, "", "-profile works with the eval engine only"},
+
+		{[]string{"check", hello}, "", 0, "", ""},
+		{[]string{"check", undefined}, "", 1, "", "undefined.monkey:2:6: error: undefined: y"},
+		{[]string{"check"}, "puts(z);", 1, "", "<stdin>:1:6: error: undefined: z"},
+
+		{[]string{"fmt"}, "let x=1", 0, "let x = 1;\n", ""},
+		{[]string{"fmt", broken}, "", 1, "", "parser errors:"},
+		{[]string{"fmt", "-w"}, "", 2, "", "cannot use -w with standard input"},
+
+		// the program built here is run by the case after it
+		{[]string{"build", "-o", built, hello}, "", 0, "", ""},
+		{[]string{"run", built}, "", 0, "3\n", ""},
+		{[]string{"dump", "-source", built}, "", 0, "puts(add(1, 2));", ""},
+		{[]string{"build", broken}, "", 1, "", "parser errors:"},
+		{[]string{"build"}, "", 2, "", "usage: monkey build"},
+
+		{[]string{"ast"}, "1 + 2", 0, "InfixExpression", ""},
+
+		{[]string{"test", tests}, "", 1, "--- FAIL: test_bad", ""},
+		{[]string{"test", "-run", "add", tests}, "", 0, "ok", ""},
+		{[]string{"test", "-format", "xml", tests}, "", 2, "", "unknown format"},
+		{[]string{"test", setup}, "", 1, "FAIL\t" + filepath.Join(setup, "setup_test.mk") + "\tdivision by zero", ""},
+
+		{[]string{"debug", hello}, "next\nprint add(2, 3)\ncontinue\n", 0, "(mdb) 5\n", ""},
+		{[]string{"debug"}, "", 2, "", "usage: monkey debug"},
+
+		{[]string{"dap"}, frame(
+			`{"seq":1,"type":"request","command":"initialize","arguments":{}}`,
+			`{"seq":2,"type":"request","command":"disconnect"}`,
+		), 0, `"supportsConfigurationDoneRequest":true`, ""},
+		{[]string{"dap", "extra"}, "", 2, "", "usage: monkey dap"},
+
+		{[]string{"lsp"}, frame(
+			`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+			`{"jsonrpc":"2.0","id":2,"method":"shutdown"}`,
+			`{"jsonrpc":"2.0","method":"exit"}`,
+		), 0, `"capabilities"`, ""},
+		{[]string{"lsp", "extra"}, "", 2, "", "usage: monkey lsp"},
+	}
+
+	for _, tt := range cases {
+		code, stdout, stderr := runMonkey(t, tt.stdin, tt.args...)
+
+		if code != tt.code {
+			t.Errorf("monkey %s: exit code %d, want %d. stderr=%q", strings.Join(tt.args, " "), code, tt.code, stderr)
+		}
+		if !strings.Contains(stdout, tt.stdout) {
+			t.Errorf("monkey %s: stdout %q does not contain %q", strings.Join(tt.args, " "), stdout, tt.stdout)
+		}
+		if !strings.Contains(stderr, tt.stderr) {
+			t.Errorf("monkey %s: stderr %q does not contain %q", strings.Join(tt.args, " "), stderr, tt.stderr)
+		}
+	}
+}
+
+func TestNullStdin(t *testing.T) {
+	null, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer null.Close()
+
+	// /dev/null is a character device, but there is nobody to greet. it runs
+	// as an empty script instead of starting the REPL
+	code, stdout, stderr := runMonkeyFrom(t, null)
+	if code != 0 || stdout != "" || stderr != "" {
+		t.Errorf("got exit code %d, stdout %q and stderr %q, want an empty script to run", code, stdout, stderr)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/ast/astbin"
+	"monkey-lang.z9fr.xyz/internal/compiler"
+	"monkey-lang.z9fr.xyz/internal/evaluator"
+	"monkey-lang.z9fr.xyz/internal/lexer"
+	"monkey-lang.z9fr.xyz/internal/object"
+	"monkey-lang.z9fr.xyz/internal/optimizer"
+	"monkey-lang.z9fr.xyz/internal/parser"
+	"monkey-lang.z9fr.xyz/internal/profiler"
+	"monkey-lang.z9fr.xyz/internal/resolver"
+	"monkey-lang.z9fr.xyz/internal/vm"
+)
+
+func runCommand(args []string) int {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: monkey run [-check] [-O] [-engine=eval|vm] [-profile out.pb] <file>")
+		fs.PrintDefaults()
+	}
+	checkFirst := fs.Bool("check", false, "resolve names first and refuse to run when any are undefined")
+	optimize := fs.Bool("O", false, "fold constants and drop branches that never run before running")
+	engineName := fs.String("engine", "eval", "run with the tree walking `eval`uator or the bytecode `vm`")
+	profile := fs.String("profile", "", "measure the time spent in each function and write a pprof profile to `file`")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	run, ok := engines[*engineName]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "monkey run: unknown engine %q\n", *engineName)
+		return 2
+	}
+	if *profile != "" && *engineName != "eval" {
+		fmt.Fprintln(os.Stderr, "monkey run: -profile works with the eval engine only")
+		return 2
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	filename := fs.Arg(0)
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey: %s\n", err)
+		return 1
+	}
+
+	if *checkFirst {
+		if code := check(filename, string(src), os.Stderr); code != 0 {
+			return code
+		}
+	}
+
+	// the profile is created first, a run is not wasted on a path that can't
+	// be written
+	var prof *profiler.Profiler
+	var profOut *os.File
+	if *profile != "" {
+		profOut, err = os.Create(*profile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "monkey: %s\n", err)
+			return 1
+		}
+		defer profOut.Close()
+
+		run = func(program *ast.Program, env *object.Environment) object.Object {
+			resolver.Annotate(program)
+			prof = profiler.New(filename, program)
+			return prof.Run(env)
+		}
+	}
+	if *optimize {
+		run = optimized(run)
+	}
+
+	code := execute(filename, string(src), os.Stdout, os.Stderr, false, run)
+
+	// a program that failed is still worth a look
+	if prof != nil {
+		if err := prof.WritePprof(profOut); err != nil {
+			fmt.Fprintf(os.Stderr, "monkey: %s\n", err)
+			return 1
+		}
+	}
+
+	return code
+}
+
+// an engine runs a program whose macros have been expanded. it returns the
+// value of the program, runtime errors come back as an `*object.Error`
+type engine func(program *ast.Program, env *object.Environment) object.Object
+
+var engines = map[string]engine{
+	"eval": evalEngine,
+	"vm":   vmEngine,
+}
+
+// optimized runs `optimizer.Optimize` on the program before handing it to `run`
+func optimized(run engine) engine {
+	return func(program *ast.Program, env *object.Environment) object.Object {
+		return run(optimizer.Optimize(program), env)
+	}
+}
+
+func evalEngine(program *ast.Program, env *object.Environment) object.Object {
+	resolver.Annotate(program)
+	return evaluator.Eval(program, env)
+}
+
+// vmEngine compiles the program and runs the bytecode. names the program does
+// not define, like `puts`, are taken from `env`
+func vmEngine(program *ast.Program, env *object.Environment) object.Object {
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+
+	machine := vm.New(comp.Bytecode(), env)
+	if err := machine.Run(); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+
+	return machine.Result()
+}
+
+// execute parses `src` and runs it with `run` in a fresh environment. parser
+// errors and runtime errors are written to `errOut` and turn in to a non-zero
+// exit code. `printResult` is used for one-liners where the value is the whole
+// point
+func execute(name, src string, out, errOut io.Writer, printResult bool, run engine) int {
+	program, ok := loadProgram(name, src, errOut)
+	if !ok {
+		return 1
+	}
+
+	macroEnv := object.NewEnvironment()
+	evaluator.DefineMacros(program, macroEnv)
+	expanded, err := evaluator.ExpandMacros(program, macroEnv)
+	if err != nil {
+		fmt.Fprintf(errOut, "%s: %s\n", name, err)
+		return 1
+	}
+
+	result := run(expanded.(*ast.Program), newEnvironment(out))
+
+	if errObj, ok := result.(*object.Error); ok {
+		fmt.Fprintf(errOut, "%s: %s\n", name, errObj.Inspect())
+		return 1
+	}
+
+	if printResult && result != nil {
+		fmt.Fprintln(out, result.Inspect())
+	}
+
+	return 0
+}
+
+// loadProgram parses `src`, or decodes it when it is a program written by
+// `monkey build`. problems are written to `errOut`
+func loadProgram(name, src string, errOut io.Writer) (*ast.Program, bool) {
+	if astbin.IsProgramFile([]byte(src)) {
+		program, err := astbin.Unmarshal([]byte(src))
+		if err != nil {
+			fmt.Fprintf(errOut, "%s: %s\n", name, err)
+			return nil, false
+		}
+		return program, true
+	}
+
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		printParserErrors(errOut, name, p.Errors())
+		return nil, false
+	}
+
+	return program, true
+}
+
+// newEnvironment returns the top level environment scripts run in. it has a
+// `puts` function so scripts have a way to produce output
+func newEnvironment(out io.Writer) *object.Environment {
+	env := object.NewEnvironment()
+
+	evaluator.Bind(env, "puts", func(args ...object.Object) {
+		for _, arg := range args {
+			fmt.Fprintln(out, arg.Inspect())
+		}
+	})
+
+	return env
+}
+
+func printParserErrors(out io.Writer, name string, errors []string) {
+	fmt.Fprintf(out, "%s: parser errors:\n", name)
+	for _, msg := range errors {
+		fmt.Fprintf(out, "\t%s\n", msg)
+	}
+}
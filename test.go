@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"monkey-lang.z9fr.xyz/internal/ast"
+	"monkey-lang.z9fr.xyz/internal/coverage"
+	"monkey-lang.z9fr.xyz/internal/evaluator"
+	"monkey-lang.z9fr.xyz/internal/object"
+	"monkey-lang.z9fr.xyz/internal/resolver"
+	"monkey-lang.z9fr.xyz/internal/testrunner"
+)
+
+func testCommand(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: monkey test [-v] [-run regexp] [-format text|tap|junit] [-cover] [-coverhtml file] [-lcov file] [files or directories...]")
+		fmt.Fprintln(flags.Output(), "runs the `let test_... = fn() {...}` functions in *_test.mk files, under the current directory by default")
+		flags.PrintDefaults()
+	}
+	verbose := flags.Bool("v", false, "list the tests that passed too")
+	run := flags.String("run", "", "only run the tests whose name matches `regexp`")
+	format := flags.String("format", "text", "write the results as `text`, tap or junit. with tap and junit output from the tests goes to stderr")
+	cover := flags.Bool("cover", false, "record which statements and branches ran and print the percentages")
+	coverHTML := flags.String("coverhtml", "", "write the coverage as an HTML page with the source to `file`, implies -cover")
+	lcov := flags.String("lcov", "", "write the coverage as an LCOV tracefile to `file`, implies -cover")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	*cover = *cover || *coverHTML != "" || *lcov != ""
+
+	write, ok := testFormats[*format]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "monkey test: unknown format %q\n", *format)
+		return 2
+	}
+
+	var match func(string) bool
+	if *run != "" {
+		re, err := regexp.Compile(*run)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "monkey test: -run: %s\n", err)
+			return 2
+		}
+		match = re.MatchString
+	}
+
+	filenames, err := testFiles(flags.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey test: %s\n", err)
+		return 1
+	}
+	if len(filenames) == 0 {
+		fmt.Fprintln(os.Stderr, "monkey test: no *_test.mk files")
+		return 1
+	}
+
+	// tap and junit are read by programs, stdout is only for them
+	out := io.Writer(os.Stdout)
+	if *format != "text" {
+		out = os.Stderr
+	}
+
+	var results []*testrunner.File
+	var covered []*coverage.File
+
+	// text is written as each file is done, between what its tests print.
+	// the other formats are written once everything ran
+	done := func(r *testrunner.File) {
+		results = append(results, r)
+		if *format == "text" {
+			write(os.Stdout, []*testrunner.File{r}, *verbose)
+		}
+	}
+
+	for _, filename := range filenames {
+		var problems bytes.Buffer
+		program, src, ok := loadTestProgram(filename, &problems)
+		if !ok {
+			done(&testrunner.File{Name: filename, Err: strings.TrimSpace(problems.String())})
+			continue
+		}
+
+		var hooks object.Hooks
+		if *cover {
+			f := coverage.New(filename, src, program)
+			covered = append(covered, f)
+			hooks = f
+		}
+
+		newEnv := func() *object.Environment {
+			env := newEnvironment(out)
+			if hooks != nil {
+				env.SetHooks(hooks)
+			}
+			return env
+		}
+		done(testrunner.Run(filename, program, newEnv, match))
+	}
+
+	status := 0
+	for _, r := range results {
+		if !r.Passed() {
+			status = 1
+		}
+	}
+
+	if *format != "text" {
+		if err := write(os.Stdout, results, *verbose); err != nil {
+			fmt.Fprintf(os.Stderr, "monkey test: %s\n", err)
+			return 1
+		}
+	}
+
+	if !*cover {
+		return status
+	}
+
+	coverage.WriteSummary(out, covered)
+
+	reports := []struct {
+		path  string
+		write func(io.Writer, []*coverage.File) error
+	}{
+		{*coverHTML, coverage.WriteHTML},
+		{*lcov, coverage.WriteLCOV},
+	}
+	for _, r := range reports {
+		if r.path == "" {
+			continue
+		}
+		if err := writeReport(r.path, covered, r.write); err != nil {
+			fmt.Fprintf(os.Stderr, "monkey test: %s\n", err)
+			status = 1
+		}
+	}
+
+	return status
+}
+
+var testFormats = map[string]func(w io.Writer, files []*testrunner.File, verbose bool) error{
+	"text": testrunner.WriteText,
+	"tap": func(w io.Writer, files []*testrunner.File, verbose bool) error {
+		return testrunner.WriteTAP(w, files)
+	},
+	"junit": func(w io.Writer, files []*testrunner.File, verbose bool) error {
+		return testrunner.WriteJUnit(w, files)
+	},
+}
+
+// testFiles returns the test files `args` name. files are taken as they are,
+// directories are searched for *_test.mk files. no arguments is the current
+// directory
+func testFiles(args []string) ([]string, error) {
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+
+		err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			// hidden directories like .git are not searched
+			if d.IsDir() && path != arg && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			if !d.IsDir() && strings.HasSuffix(d.Name(), "_test.mk") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// loadTestProgram reads, expands and annotates the program in `filename`.
+// problems are written to `errOut`
+func loadTestProgram(filename string, errOut io.Writer) (*ast.Program, string, bool) {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(errOut, "%s\n", err)
+		return nil, "", false
+	}
+
+	program, ok := loadProgram(filename, string(src), errOut)
+	if !ok {
+		return nil, "", false
+	}
+
+	macroEnv := object.NewEnvironment()
+	evaluator.DefineMacros(program, macroEnv)
+	expanded, err := evaluator.ExpandMacros(program, macroEnv)
+	if err != nil {
+		fmt.Fprintf(errOut, "%s: %s\n", filename, err)
+		return nil, "", false
+	}
+	program = expanded.(*ast.Program)
+	resolver.Annotate(program)
+
+	return program, string(src), true
+}
+
+func writeReport(path string, files []*coverage.File, write func(io.Writer, []*coverage.File) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := write(f, files); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}